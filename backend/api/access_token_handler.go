@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type AccessTokenHandler struct {
+	store store.AccessTokenStorer
+}
+
+func NewAccessTokenHandler(accessTokenStore store.AccessTokenStorer) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		store: accessTokenStore,
+	}
+}
+
+// @Summary		Get all personal access tokens.
+// @Description	fetch every personal access token of the authenticated user, the tokens themselves are only shown when they are created.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.AccessTokenGetAllResponse
+// @Failure		403	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/user/tokens [get]
+// @Security	ApiKeyAuth
+func (h *AccessTokenHandler) HandleGetAccessTokens(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	tokens, err := h.store.GetAccessTokens(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAccessTokenGetAllResponse(tokens))
+}
+
+// @Summary		Create a personal access token.
+// @Description	create a token for scripts that is sent as "Bearer pat_...", the read scope allows GET requests and the write scope every request.
+// @Description	The token is only part of this response.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.AccessTokenParams	true	"Token metadata"
+// @Produce		json
+// @Success		200	{object}	types.AccessTokenCreatedResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Router		/api/v1/user/tokens [post]
+// @Security	ApiKeyAuth
+func (h *AccessTokenHandler) HandleInsertAccessToken(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+
+	var params types.AccessTokenParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	accessToken, token, err := types.NewAccessToken(params, user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	insertedToken, err := h.store.InsertAccessToken(r.Context(), accessToken)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	return utils.ResponseWriteJSON(w, types.AccessTokenCreatedResponse{
+		AccessToken: insertedToken,
+		Token:       token,
+	})
+}
+
+// @Summary		Delete a personal access token.
+// @Description	deletes a token, it stops working right away.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Token ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/user/tokens/{id} [delete]
+// @Security	ApiKeyAuth
+func (h *AccessTokenHandler) HandleDeleteAccessToken(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.DeleteAccessToken(r.Context(), int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("token ID: %d", id), http.StatusOK))
+}
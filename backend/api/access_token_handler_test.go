@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestAccessTokens(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	user, token := testSuite.createUser(t)
+
+	create := func(params types.AccessTokenParams) types.AccessTokenCreatedResponse {
+		rr := do(t, r, http.MethodPost, "/user/tokens", params, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.AccessTokenCreatedResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(resp.Token, types.AccessTokenPrefix) || !strings.HasPrefix(resp.Token, resp.Prefix) {
+			t.Fatalf("expected a token starting with %q, got %+v", resp.Prefix, resp)
+		}
+		return resp
+	}
+
+	read := create(types.AccessTokenParams{Name: "backup", Scopes: []string{types.ScopeRead}})
+	write := create(types.AccessTokenParams{Name: "CI", Scopes: []string{types.ScopeWrite}})
+	todo := types.InsertTodoParams{Title: "From a script", Content: "created with a token"}
+
+	t.Run("Scopes", func(t *testing.T) {
+		if rr := do(t, r, http.MethodGet, "/todos", nil, read.Token); rr.Code != http.StatusOK {
+			t.Errorf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if rr := do(t, r, http.MethodPost, "/todos", todo, read.Token); rr.Code != http.StatusForbidden {
+			t.Errorf("expected http status code %v got %v", http.StatusForbidden, rr.Code)
+		}
+		rr := do(t, r, http.MethodPost, "/todos", todo, write.Token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var inserted types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&inserted); err != nil {
+			t.Fatal(err)
+		}
+		if inserted.CreatedBy != user.ID {
+			t.Errorf("expected the todo to be created by %d, got %d", user.ID, inserted.CreatedBy)
+		}
+		if rr := do(t, r, http.MethodGet, "/todos", nil, write.Token); rr.Code != http.StatusOK {
+			t.Errorf("expected http status code %v got %v", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		// Tokens can't manage tokens, sessions or the password.
+		for _, target := range []string{"/user/tokens", "/user/sessions"} {
+			if rr := do(t, r, http.MethodGet, target, nil, write.Token); rr.Code != http.StatusForbidden {
+				t.Errorf("%s: expected http status code %v got %v", target, http.StatusForbidden, rr.Code)
+			}
+		}
+		if rr := do(t, r, http.MethodPost, "/user/tokens", types.AccessTokenParams{Name: "more", Scopes: []string{types.ScopeWrite}}, write.Token); rr.Code != http.StatusForbidden {
+			t.Errorf("expected http status code %v got %v", http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		rr := do(t, r, http.MethodGet, "/user/tokens", nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if strings.Contains(rr.Body.String(), read.Token) || strings.Contains(rr.Body.String(), `"token"`) {
+			t.Errorf("expected the tokens to be hidden, got %s", rr.Body.String())
+		}
+		var resp types.AccessTokenGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Count != 2 || resp.Result[0].Name != "backup" || resp.Result[0].LastUsed == nil {
+			t.Errorf("expected the used backup and CI tokens, got %+v", resp.Result)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour)
+		for _, params := range []types.AccessTokenParams{
+			{Name: "", Scopes: []string{types.ScopeRead}},
+			{Name: "no scopes"},
+			{Name: "admin", Scopes: []string{"admin"}},
+			{Name: "expired", Scopes: []string{types.ScopeRead}, ExpiresAt: &past},
+		} {
+			if rr := do(t, r, http.MethodPost, "/user/tokens", params, token); rr.Code != http.StatusBadRequest {
+				t.Errorf("%+v: expected http status code %v got %v", params, http.StatusBadRequest, rr.Code)
+			}
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		accessToken, expired, err := types.NewAccessToken(types.AccessTokenParams{Name: "old", Scopes: []string{types.ScopeRead}}, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		past := time.Now().Add(-time.Minute)
+		accessToken.ExpiresAt = &past
+		if _, err := testSuite.accessTokenStore.InsertAccessToken(context.TODO(), accessToken); err != nil {
+			t.Fatal(err)
+		}
+		if rr := do(t, r, http.MethodGet, "/todos", nil, expired); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected http status code %v got %v", http.StatusUnauthorized, rr.Code)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, otherToken := testSuite.createUser(t)
+		if rr := do(t, r, http.MethodDelete, fmt.Sprintf("/user/tokens/%d", read.ID), nil, otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("expected http status code %v got %v", http.StatusNotFound, rr.Code)
+		}
+		if rr := do(t, r, http.MethodDelete, fmt.Sprintf("/user/tokens/%d", read.ID), nil, token); rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if rr := do(t, r, http.MethodGet, "/todos", nil, read.Token); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected http status code %v got %v", http.StatusUnauthorized, rr.Code)
+		}
+	})
+}
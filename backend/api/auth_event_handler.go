@@ -0,0 +1,90 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type AuthEventHandler struct {
+	store store.AuthEventStorer
+}
+
+func NewAuthEventHandler(authEventStore store.AuthEventStorer) *AuthEventHandler {
+	return &AuthEventHandler{
+		store: authEventStore,
+	}
+}
+
+// @Summary		Get the auth event log.
+// @Description	fetch the most recent logins, failed logins and two-factor codes of the authenticated user, newest first.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.AuthEventGetAllResponse
+// @Failure		403	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/user/events [get]
+// @Security	ApiKeyAuth
+func (h *AuthEventHandler) HandleGetAuthEvents(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	events, err := h.store.GetAuthEvents(r.Context(), user.ID, types.AuthEventLimit)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAuthEventGetAllResponse(events))
+}
+
+// checkLocked returns an error if the account or the IP address of the request
+// failed too often recently, the rejected login is recorded.
+func (h *AuthEventHandler) checkLocked(w http.ResponseWriter, r *http.Request, email string, user *types.User) *types.APIError {
+	now := time.Now()
+	since := now.Add(-types.LoginFailureWindow)
+	account, err := h.store.GetAccountFailures(r.Context(), email, since)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	ip, err := h.store.GetIPFailures(r.Context(), clientIP(r), since)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	lockedUntil := account.LockedUntil(types.AccountFailureLimit)
+	if ipLockedUntil := ip.LockedUntil(types.IPFailureLimit); ipLockedUntil.After(lockedUntil) {
+		lockedUntil = ipLockedUntil
+	}
+	if !lockedUntil.After(now) {
+		return nil
+	}
+
+	if apiErr := h.record(r, types.AuthEventLoginLocked, email, user); apiErr != nil {
+		return apiErr
+	}
+	wait := lockedUntil.Sub(now).Round(time.Second) + time.Second
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
+	return types.NewAPIError(false, fmt.Errorf("Too many failed logins, try again in %s", wait), http.StatusTooManyRequests)
+}
+
+// record adds an event to the auth event log, user is nil for unknown email addresses.
+func (h *AuthEventHandler) record(r *http.Request, event, email string, user *types.User) *types.APIError {
+	var userID *int
+	if user != nil {
+		userID = &user.ID
+	}
+	if _, err := h.store.InsertAuthEvent(r.Context(), types.NewAuthEvent(event, email, userID, clientIP(r), r.UserAgent())); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	return nil
+}
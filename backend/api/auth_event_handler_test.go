@@ -0,0 +1,161 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+func TestLoginThrottling(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	public := mux.NewRouter()
+	public.Use(testSuite.proxy.Middleware)
+	public.HandleFunc("/login", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
+
+	// send sends the credentials from the remote address, with the X-Forwarded-For header unless it's empty.
+	send := func(t *testing.T, remote, forwarded, email, password string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		body, err := json.Marshal(types.UserParams{Email: email, Password: password})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		req.RemoteAddr = remote
+		req.Header.Add("Content-Type", "application/json")
+		if forwarded != "" {
+			req.Header.Add("X-Forwarded-For", forwarded)
+		}
+		rr := httptest.NewRecorder()
+		public.ServeHTTP(rr, req)
+		if rr.Code != status {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", status, rr.Code, rr.Body.String())
+		}
+		return rr
+	}
+	// login sends the credentials on behalf of a browser at the IP address, like the frontend does.
+	login := func(t *testing.T, ip, email, password string, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		return send(t, "192.0.2.1:1234", ip, email, password, status)
+	}
+	// The failures of an IP address outlive the test when it runs against PostgreSQL.
+	randomIP := func() string {
+		return fmt.Sprintf("10.%d.%d.%d", rand.Intn(256), rand.Intn(256), rand.Intn(256))
+	}
+	message := func(t *testing.T, rr *httptest.ResponseRecorder) string {
+		t.Helper()
+		var resp types.APIError
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Message
+	}
+
+	t.Run("Uniform", func(t *testing.T) {
+		user, _ := testSuite.createUser(t)
+		ip := randomIP()
+		unknown := message(t, login(t, ip, "unknown@unknown.com", "secret-password", http.StatusUnauthorized))
+		wrong := message(t, login(t, ip, user.Email, "wrong-password", http.StatusUnauthorized))
+		if unknown != wrong {
+			t.Errorf("expected unknown emails and wrong passwords to fail alike, got %q and %q", unknown, wrong)
+		}
+	})
+
+	t.Run("Account", func(t *testing.T) {
+		user, token := testSuite.createUser(t)
+		ip, other := randomIP(), randomIP()
+		for i := 0; i < types.AccountFailureLimit; i++ {
+			login(t, ip, user.Email, "wrong-password", http.StatusUnauthorized)
+		}
+
+		// The account is locked for every IP address, even with the right password.
+		rr := login(t, other, user.Email, "secret-password", http.StatusTooManyRequests)
+		if rr.Header().Get("Retry-After") == "" {
+			t.Errorf("expected a Retry-After header")
+		}
+		login(t, other, "unknown@unknown.com", "secret-password", http.StatusUnauthorized)
+
+		rr = do(t, r, http.MethodGet, "/user/events", nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var events types.AuthEventGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&events); err != nil {
+			t.Fatal(err)
+		}
+		if events.Count != types.AccountFailureLimit+1 || events.Result[0].Event != types.AuthEventLoginLocked || events.Result[1].Event != types.AuthEventLoginFailed {
+			t.Fatalf("expected %d failed logins and a locked login, got %+v", types.AccountFailureLimit, events.Result)
+		}
+		if events.Result[0].IP != other || events.Result[0].Email != user.Email {
+			t.Errorf("expected the event to record the IP and email address, got %+v", events.Result[0])
+		}
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		user, _ := testSuite.createUser(t)
+		ip := randomIP()
+		for round := 0; round < 2; round++ {
+			for i := 0; i < types.AccountFailureLimit-1; i++ {
+				login(t, ip, user.Email, "wrong-password", http.StatusUnauthorized)
+			}
+			// A successful login clears the failures of the account.
+			login(t, ip, user.Email, "secret-password", http.StatusOK)
+		}
+	})
+
+	t.Run("IP", func(t *testing.T) {
+		user, _ := testSuite.createUser(t)
+		ip := randomIP()
+		for i := 0; i < types.IPFailureLimit; i++ {
+			login(t, ip, fmt.Sprintf("unknown%d@unknown.com", i), "secret-password", http.StatusUnauthorized)
+		}
+		login(t, ip, user.Email, "secret-password", http.StatusTooManyRequests)
+		login(t, randomIP(), user.Email, "secret-password", http.StatusOK)
+	})
+
+	t.Run("Spoofed", func(t *testing.T) {
+		user, _ := testSuite.createUser(t)
+		remote := randomIP() + ":4321"
+		for i := 0; i < types.IPFailureLimit; i++ {
+			send(t, remote, randomIP(), fmt.Sprintf("unknown%d@unknown.com", i), "secret-password", http.StatusUnauthorized)
+		}
+		// The header of a client that isn't a trusted proxy doesn't change its address.
+		send(t, remote, randomIP(), user.Email, "secret-password", http.StatusTooManyRequests)
+		send(t, remote, "", user.Email, "secret-password", http.StatusTooManyRequests)
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		last := time.Now()
+		tests := []struct {
+			count int
+			wait  time.Duration
+		}{
+			{types.AccountFailureLimit - 1, 0},
+			{types.AccountFailureLimit, 30 * time.Second},
+			{types.AccountFailureLimit + 1, time.Minute},
+			{types.AccountFailureLimit + 3, 4 * time.Minute},
+			{types.AccountFailureLimit + 100, 15 * time.Minute},
+		}
+		for _, tt := range tests {
+			failures := types.LoginFailures{Count: tt.count, Last: last}
+			lockedUntil := failures.LockedUntil(types.AccountFailureLimit)
+			if tt.wait == 0 && !lockedUntil.IsZero() {
+				t.Errorf("expected no lock after %d failures, got %s", tt.count, lockedUntil)
+			}
+			if tt.wait != 0 && !lockedUntil.Equal(last.Add(tt.wait)) {
+				t.Errorf("expected a %s lock after %d failures, got %s", tt.wait, tt.count, lockedUntil.Sub(last))
+			}
+		}
+	})
+}
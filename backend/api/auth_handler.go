@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/thimc/go-svelte-todo/backend/api/middleware"
 	"github.com/thimc/go-svelte-todo/backend/store"
@@ -12,17 +15,31 @@ import (
 )
 
 type AuthHandler struct {
-	store store.UserStorer
+	store        store.UserStorer
+	listStore    store.ListStorer
+	tokenStore   store.TokenStorer
+	sessionStore store.SessionStorer
+	mfaStore     store.MFAStorer
+	verification *VerificationHandler
+	events       *AuthEventHandler
+	keys         *middleware.KeySet
 }
 
-func NewAuthHandler(store store.UserStorer) *AuthHandler {
+func NewAuthHandler(store store.UserStorer, listStore store.ListStorer, tokenStore store.TokenStorer, sessionStore store.SessionStorer, mfaStore store.MFAStorer, verification *VerificationHandler, events *AuthEventHandler, keys *middleware.KeySet) *AuthHandler {
 	return &AuthHandler{
-		store: store,
+		store:        store,
+		listStore:    listStore,
+		tokenStore:   tokenStore,
+		sessionStore: sessionStore,
+		mfaStore:     mfaStore,
+		verification: verification,
+		events:       events,
+		keys:         keys,
 	}
 }
 
 // @Summary		Register a user.
-// @Description	register a regular user.
+// @Description	register a regular user with an empty inbox list and send a link to verify the email address.
 // @Tags		auth
 // @Accept		json
 // @Param		params	body	types.UserParams	true	"User credentials"
@@ -46,23 +63,33 @@ func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) *ty
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
 
-	insertedUser, err := h.store.CreateUser(r.Context(), user)
-	if err != nil {
-		return types.NewAPIError(false, err, http.StatusBadRequest)
+	insertedUser, apiErr := h.createUser(r.Context(), user)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	// The link can be sent again, so the registration doesn't fail with the mail.
+	if err := h.verification.SendVerificationMail(r.Context(), insertedUser); err != nil {
+		log.Printf("error when sending the verification mail: %s", err)
 	}
 
 	return utils.ResponseWriteJSON(w, insertedUser)
 }
 
 // @Summary		Login a user.
-// @Description	generates a JWT token that is valid for 6 hours.
+// @Description	generates a JWT token that is valid for 15 minutes and a refresh token that is valid for 30 days.
+// @Description	Users with two-factor authentication get an MFAChallengeResponse instead, which is exchanged at /api/login/mfa.
+// @Description	Accounts and IP addresses with too many failed logins have to wait before they can try again.
 // @Tags		auth
 // @Accept		json
 // @Param		params	body	types.UserParams	true	"User credentials."
 // @Produce		json
 // @Success		200	{object}	types.LoginResponse
+// @Success		200	{object}	types.MFAChallengeResponse
 // @Failure		400	{object}	types.APIError
 // @Failure		401	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		429	{object}	types.APIError
 // @Router		/api/login [post]
 func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) *types.APIError {
 	var params types.UserParams
@@ -75,24 +102,270 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) *types
 	}
 
 	user, err := h.store.GetUserByEmail(r.Context(), params.Email)
+	if err != nil {
+		user = nil
+	}
+	if apiErr := h.events.checkLocked(w, r, params.Email, user); apiErr != nil {
+		return apiErr
+	}
+	// Unknown email addresses fail like wrong passwords, and take as long.
+	if user == nil {
+		types.CheckDummyPassword(params.Password)
+	}
+	if user == nil || types.ValidPassword(user.EncryptedPassword, params.Password) != nil {
+		if apiErr := h.events.record(r, types.AuthEventLoginFailed, params.Email, user); apiErr != nil {
+			return apiErr
+		}
+		return types.NewAPIError(false, fmt.Errorf("Invalid credentials"), http.StatusUnauthorized)
+	}
+	if apiErr := h.verification.checkLogin(user); apiErr != nil {
+		return apiErr
+	}
+
+	return h.authenticated(w, r, user)
+}
+
+// createUser inserts the user together with an empty inbox list.
+func (h *AuthHandler) createUser(ctx context.Context, user *types.User) (*types.User, *types.APIError) {
+	insertedUser, err := h.store.CreateUser(ctx, user)
+	if err != nil {
+		return nil, types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	// A user without an inbox would be half registered, so the user is removed again.
+	if _, err := h.listStore.InsertList(ctx, types.NewInboxList(insertedUser.ID)); err != nil {
+		if err := h.store.DeleteUserByID(ctx, int64(insertedUser.ID)); err != nil {
+			return nil, types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		return nil, types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return insertedUser, nil
+}
+
+// authenticated finishes the login of a user who proved who they are, users with
+// two-factor authentication get an MFA challenge instead of a LoginResponse.
+func (h *AuthHandler) authenticated(w http.ResponseWriter, r *http.Request, user *types.User) *types.APIError {
+	if user.Disabled {
+		return types.NewAPIError(false, fmt.Errorf("the account has been disabled"), http.StatusForbidden)
+	}
+	mfa, err := h.mfaStore.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if mfa.Enabled {
+		// The login only succeeds with the code, so the failures of the account are kept.
+		if apiErr := h.events.record(r, types.AuthEventMFAChallenged, user.Email, user); apiErr != nil {
+			return apiErr
+		}
+		claims, token, err := h.keys.CreateMFAChallenge(user)
+		if err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		return utils.ResponseWriteJSON(w, types.MFAChallengeResponse{
+			MFARequired:    true,
+			ChallengeToken: token,
+			ExpiresAt:      claims["exp"].(int64),
+		})
+	}
+
+	return h.login(w, r, user)
+}
+
+// @Summary		Complete a two-factor login.
+// @Description	exchanges the challenge token of the login and a code from the authenticator app, or a recovery code, for a LoginResponse.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.MFALoginParams	true	"The challenge token and code"
+// @Produce		json
+// @Success		200	{object}	types.LoginResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		401	{object}	types.APIError
+// @Failure		429	{object}	types.APIError
+// @Router		/api/login/mfa [post]
+func (h *AuthHandler) HandleLoginMFA(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.MFALoginParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	userID, err := h.keys.ValidateMFAChallenge(params.ChallengeToken)
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusUnauthorized)
 	}
-	if err := types.ValidPassword(user.EncryptedPassword, params.Password); err != nil {
-		return types.NewAPIError(false, fmt.Errorf("Access denied"), http.StatusUnauthorized)
+	user, err := h.store.GetUserByID(r.Context(), int64(userID))
+	if err != nil || user.Disabled {
+		return types.NewAPIError(false, fmt.Errorf("Invalid challenge token"), http.StatusUnauthorized)
+	}
+	mfa, err := h.mfaStore.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !mfa.Enabled {
+		return types.NewAPIError(false, fmt.Errorf("Invalid challenge token"), http.StatusUnauthorized)
+	}
+	if apiErr := h.events.checkLocked(w, r, user.Email, user); apiErr != nil {
+		return apiErr
+	}
+	ok, err := checkMFACode(r.Context(), h.mfaStore, mfa, params.Code)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !ok {
+		if apiErr := h.events.record(r, types.AuthEventMFAFailed, user.Email, user); apiErr != nil {
+			return apiErr
+		}
+		return types.NewAPIError(false, fmt.Errorf("Invalid code"), http.StatusUnauthorized)
+	}
+
+	return h.login(w, r, user)
+}
+
+// login records the successful login, which clears the failures of the account,
+// cancels a pending deletion and writes a LoginResponse with a new session.
+func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request, user *types.User) *types.APIError {
+	// Logging in before a deleted account is purged restores it.
+	if user.DeleteAt != nil {
+		if err := h.store.CancelUserDeletion(r.Context(), int64(user.ID)); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		user.DeleteAt = nil
+	}
+	if apiErr := h.events.record(r, types.AuthEventLoginSucceeded, user.Email, user); apiErr != nil {
+		return apiErr
+	}
+	resp, err := h.loginResponse(r, user, 0)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, resp)
+}
+
+// @Summary		Refresh the token.
+// @Description	exchanges a refresh token for a new JWT token and refresh token, the old refresh token can't be used again.
+// @Description	Reusing a refresh token signs out the session that it belongs to.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.RefreshTokenParams	true	"The refresh token"
+// @Produce		json
+// @Success		200	{object}	types.LoginResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		401	{object}	types.APIError
+// @Router		/api/token/refresh [post]
+func (h *AuthHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.RefreshTokenParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	refreshToken, err := h.tokenStore.GetRefreshTokenByHash(r.Context(), types.HashToken(params.RefreshToken))
+	if err != nil || refreshToken.RevokedAt != nil || time.Now().After(refreshToken.ExpiresAt) {
+		return types.NewAPIError(false, fmt.Errorf("Invalid refresh token"), http.StatusUnauthorized)
+	}
+
+	// A refresh token that was exchanged already has been stolen or replayed,
+	// nothing issued since the login can be trusted anymore.
+	used, err := h.tokenStore.UseRefreshToken(r.Context(), refreshToken.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !used {
+		if err := h.sessionStore.RevokeSession(r.Context(), refreshToken.SessionID, refreshToken.UserID); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		return types.NewAPIError(false, fmt.Errorf("Invalid refresh token"), http.StatusUnauthorized)
+	}
+
+	user, err := h.store.GetUserByID(r.Context(), int64(refreshToken.UserID))
+	if err != nil || user.Disabled {
+		return types.NewAPIError(false, fmt.Errorf("Invalid refresh token"), http.StatusUnauthorized)
 	}
 
-	claims, token, err := middleware.CreateJWT(user)
+	if err := h.sessionStore.TouchSession(r.Context(), refreshToken.SessionID, time.Now()); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	resp, err := h.loginResponse(r, user, refreshToken.SessionID)
 	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, resp)
+}
+
+// @Summary		Logout a user.
+// @Description	signs out the session of the refresh token, its tokens can't be used anymore.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.RefreshTokenParams	true	"The refresh token"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Router		/api/logout [post]
+func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.RefreshTokenParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
 
-	return utils.ResponseWriteJSON(w, types.LoginResponse{
-		ID:        user.ID,
-		Email:     user.Email,
-		Token:     token,
-		ExpiresAt: claims["expiresAt"].(int64),
-	})
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	loggedOut := types.NewAPIError(true, fmt.Errorf("the session has been signed out"), http.StatusOK)
+
+	// Unknown tokens are logged out already.
+	refreshToken, err := h.tokenStore.GetRefreshTokenByHash(r.Context(), types.HashToken(params.RefreshToken))
+	if err != nil {
+		return utils.ResponseWriteJSON(w, loggedOut)
+	}
+	// The session can be signed out already, which is fine as well.
+	_ = h.sessionStore.RevokeSession(r.Context(), refreshToken.SessionID, refreshToken.UserID)
+
+	return utils.ResponseWriteJSON(w, loggedOut)
+}
+
+// loginResponse creates a JWT token and a refresh token for the user in the
+// session, a new session is created unless sessionID is set.
+func (h *AuthHandler) loginResponse(r *http.Request, user *types.User, sessionID int64) (*types.LoginResponse, error) {
+	if sessionID == 0 {
+		session, err := h.sessionStore.CreateSession(r.Context(), types.NewSession(user.ID, r.UserAgent(), clientIP(r)))
+		if err != nil {
+			return nil, err
+		}
+		sessionID = session.ID
+	}
+
+	claims, token, err := h.keys.CreateJWT(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refresh, err := types.NewRefreshToken(user.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.tokenStore.CreateRefreshToken(r.Context(), refreshToken); err != nil {
+		return nil, err
+	}
+
+	return &types.LoginResponse{
+		ID:               user.ID,
+		Email:            user.Email,
+		Token:            token,
+		ExpiresAt:        claims["exp"].(int64),
+		RefreshToken:     refresh,
+		RefreshExpiresAt: refreshToken.ExpiresAt.Unix(),
+	}, nil
 }
 
 // @Summary		Verify token.
@@ -10,6 +10,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/thimc/go-svelte-todo/backend/types"
 	"github.com/thimc/go-svelte-todo/backend/utils"
 )
@@ -128,6 +129,14 @@ func TestRegisterSuccess(t *testing.T) {
 		t.Errorf("expected an empty encrypted password field, got %s", user.EncryptedPassword)
 	}
 
+	lists, err := testSuite.listStore.GetLists(context.TODO(), user.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 || !lists[0].Inbox || lists[0].Name != types.InboxListName {
+		t.Errorf("expected the new user to have an inbox, got %+v", lists)
+	}
+
 	err = testSuite.userStore.DeleteUserByID(context.TODO(), int64(user.ID))
 	if err != nil {
 		t.Fatalf("error when removing mock user: %s", err)
@@ -237,3 +246,86 @@ func TestLoginSuccess(t *testing.T) {
 		t.Fatalf("error when removing mock user: %s", err)
 	}
 }
+
+func TestRefreshToken(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/login", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
+	r.HandleFunc("/logout", utils.HandleAPIFunc(testSuite.authHandler.HandleLogout)).Methods(http.MethodPost)
+	r.HandleFunc("/token/refresh", utils.HandleAPIFunc(testSuite.authHandler.HandleRefreshToken)).Methods(http.MethodPost)
+
+	user, _ := testSuite.createUser(t)
+	login := func() types.LoginResponse {
+		rr := do(t, r, http.MethodPost, "/login", types.UserParams{Email: user.Email, Password: "secret-password"}, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.LoginResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.RefreshToken == "" || resp.RefreshExpiresAt <= resp.ExpiresAt {
+			t.Fatalf("expected a refresh token outliving the jwt token, got %+v", resp)
+		}
+		return resp
+	}
+	refresh := func(token string, status int) types.LoginResponse {
+		rr := do(t, r, http.MethodPost, "/token/refresh", types.RefreshTokenParams{RefreshToken: token}, "")
+		if rr.Code != status {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", status, rr.Code, rr.Body.String())
+		}
+		var resp types.LoginResponse
+		if status == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp
+	}
+
+	t.Run("Rotate", func(t *testing.T) {
+		first := login()
+		second := refresh(first.RefreshToken, http.StatusOK)
+		if second.Token == "" || second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+			t.Fatalf("expected a new jwt token and refresh token, got %+v", second)
+		}
+		if second.ID != user.ID {
+			t.Errorf("expected user id %v, got %v", user.ID, second.ID)
+		}
+		third := refresh(second.RefreshToken, http.StatusOK)
+
+		// Reusing a rotated token revokes the whole family.
+		refresh(first.RefreshToken, http.StatusUnauthorized)
+		refresh(third.RefreshToken, http.StatusUnauthorized)
+	})
+
+	t.Run("Families", func(t *testing.T) {
+		stolen := login()
+		other := login()
+		refresh(stolen.RefreshToken, http.StatusOK)
+		refresh(stolen.RefreshToken, http.StatusUnauthorized)
+		refresh(other.RefreshToken, http.StatusOK)
+	})
+
+	t.Run("Logout", func(t *testing.T) {
+		resp := login()
+		for i := 0; i < 2; i++ {
+			rr := do(t, r, http.MethodPost, "/logout", types.RefreshTokenParams{RefreshToken: resp.RefreshToken}, "")
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var msg types.APIError
+			if err := json.NewDecoder(rr.Body).Decode(&msg); err != nil || !msg.Success {
+				t.Errorf("expected a success message, got %q (%v)", rr.Body.String(), err)
+			}
+		}
+		refresh(resp.RefreshToken, http.StatusUnauthorized)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		refresh("unknown", http.StatusUnauthorized)
+		refresh("", http.StatusBadRequest)
+	})
+}
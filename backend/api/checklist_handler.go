@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type ChecklistHandler struct {
+	store store.ChecklistStorer
+}
+
+func NewChecklistHandler(checklistStore store.ChecklistStorer) *ChecklistHandler {
+	return &ChecklistHandler{
+		store: checklistStore,
+	}
+}
+
+// @Summary		Add a checklist item.
+// @Description	adds an item to the checklist of a todo.
+// @Tags		checklist
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Param		params	body	types.ChecklistItemParams	true	"Checklist item"
+// @Produce		json
+// @Success		200	{object}	types.ChecklistItem
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/checklist [post]
+// @Security	ApiKeyAuth
+func (h *ChecklistHandler) HandleInsertChecklistItem(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	var params types.ChecklistItemParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	item := types.NewChecklistItemFromParams(params, int64(todoID))
+	if err := item.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	insertedItem, err := h.store.InsertChecklistItem(r.Context(), user.ID, item)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, insertedItem)
+}
+
+// @Summary		Patch a checklist item.
+// @Description	renames, reorders or (un)checks a checklist item and returns the updated item.
+// @Tags		checklist
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Param		itemId	path	int	true	"Checklist item ID"
+// @Param		params	body	types.UpdateChecklistItemParams	true	"New checklist item data"
+// @Produce		json
+// @Success		200	{object}	types.ChecklistItem
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/checklist/{itemId} [patch]
+// @Security	ApiKeyAuth
+func (h *ChecklistHandler) HandlePatchChecklistItem(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["itemId"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	var params types.UpdateChecklistItemParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	item, err := h.store.PatchChecklistItem(r.Context(), int64(todoID), int64(id), user.ID, params)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, item)
+}
+
+// @Summary		Delete a checklist item.
+// @Description	removes an item from the checklist of a todo.
+// @Tags		checklist
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Param		itemId	path	int	true	"Checklist item ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/checklist/{itemId} [delete]
+// @Security	ApiKeyAuth
+func (h *ChecklistHandler) HandleDeleteChecklistItem(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	todoID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["itemId"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.DeleteChecklistItem(r.Context(), int64(todoID), int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("checklist item ID: %d", id), http.StatusOK))
+}
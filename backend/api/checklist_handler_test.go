@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestChecklist(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	rr := do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Groceries", Content: "Saturday", AutoComplete: true}, token)
+	var todo types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	if len(todo.Checklist) != 0 || todo.Progress != nil {
+		t.Fatalf("expected a todo without checklist and progress, got %+v", todo)
+	}
+
+	target := fmt.Sprintf("/todos/%d/checklist", todo.ID)
+	items := []*types.ChecklistItem{}
+	for i, title := range []string{"Milk", "Eggs"} {
+		rr := do(t, r, http.MethodPost, target, types.ChecklistItemParams{Title: title, Position: i}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var item types.ChecklistItem
+		if err := json.NewDecoder(rr.Body).Decode(&item); err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, &item)
+	}
+	rr = do(t, r, http.MethodPost, target, types.ChecklistItemParams{}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v for an item without a title, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodPost, target, types.ChecklistItemParams{Title: "Bread"}, otherToken)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v for the todo of another user, got %v", http.StatusNotFound, rr.Code)
+	}
+
+	done := true
+	itemTarget := fmt.Sprintf("%s/%d", target, items[0].ID)
+	rr = do(t, r, http.MethodPatch, itemTarget, types.UpdateChecklistItemParams{Done: &done}, otherToken)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v for the item of another user, got %v", http.StatusNotFound, rr.Code)
+	}
+	rr = do(t, r, http.MethodPatch, itemTarget, types.UpdateChecklistItemParams{Done: &done}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d", todo.ID), nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	if len(todo.Checklist) != 2 || todo.Checklist[0].Title != "Milk" || !todo.Checklist[0].Done {
+		t.Fatalf("expected the checklist in position order, got %+v", todo.Checklist)
+	}
+	if todo.Progress == nil || todo.Progress.Label != "1/2 done" || todo.Done {
+		t.Fatalf("expected the todo to be 1/2 done, got %+v", todo.Progress)
+	}
+
+	// Deleting the last open item leaves only done items, which completes the todo.
+	rr = do(t, r, http.MethodDelete, fmt.Sprintf("%s/%d", target, items[1].ID), nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d", todo.ID), nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	if !todo.Done || todo.Progress.Label != "1/1 done" {
+		t.Fatalf("expected the todo to be auto completed, got done=%v progress=%+v", todo.Done, todo.Progress)
+	}
+}
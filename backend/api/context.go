@@ -0,0 +1,43 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// contextUser returns the user that the JWT middleware stored in the request context.
+func contextUser(r *http.Request) (*types.User, *types.APIError) {
+	user, ok := r.Context().Value("user").(*types.User)
+	if !ok {
+		return nil, types.NewAPIError(false, fmt.Errorf("unknown user"), http.StatusUnauthorized)
+	}
+	return user, nil
+}
+
+// contextSession returns the ID of the session that the JWT middleware stored in the request context.
+func contextSession(r *http.Request) int64 {
+	id, _ := r.Context().Value("session").(int64)
+	return id
+}
+
+// clientIP returns the address of the client. The ProxyMiddleware replaces the
+// address of requests the frontend makes on behalf of the browser.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requireSession returns an error unless the request was made with the token of a login,
+// personal access tokens can't manage the credentials of the user.
+func requireSession(r *http.Request) *types.APIError {
+	if contextSession(r) == 0 {
+		return types.NewAPIError(false, fmt.Errorf("personal access tokens can't be used for this request"), http.StatusForbidden)
+	}
+	return nil
+}
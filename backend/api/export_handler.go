@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/export"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type ExportHandler struct {
+	store            store.ExportStorer
+	userStore        store.UserStorer
+	todoStore        store.TodoStorer
+	listStore        store.ListStorer
+	tagStore         store.TagStorer
+	sessionStore     store.SessionStorer
+	accessTokenStore store.AccessTokenStorer
+	identityStore    store.IdentityStorer
+	authEventStore   store.AuthEventStorer
+	mfaStore         store.MFAStorer
+	// accounts with more todos than this are exported in the background
+	syncLimit int
+	// the exports running in the background
+	jobs sync.WaitGroup
+}
+
+func NewExportHandler(store store.ExportStorer, userStore store.UserStorer, todoStore store.TodoStorer, listStore store.ListStorer, tagStore store.TagStorer, sessionStore store.SessionStorer, accessTokenStore store.AccessTokenStorer, identityStore store.IdentityStorer, authEventStore store.AuthEventStorer, mfaStore store.MFAStorer) *ExportHandler {
+	return &ExportHandler{
+		store:            store,
+		userStore:        userStore,
+		todoStore:        todoStore,
+		listStore:        listStore,
+		tagStore:         tagStore,
+		sessionStore:     sessionStore,
+		accessTokenStore: accessTokenStore,
+		identityStore:    identityStore,
+		authEventStore:   authEventStore,
+		mfaStore:         mfaStore,
+		syncLimit:        types.ExportSyncLimit,
+	}
+}
+
+// @Summary		Export the personal data.
+// @Description	downloads a ZIP file with the profile, lists, tags, todos, trash, sessions, access tokens and auth events of the user
+// @Description	as data.json, and the todos as todos.csv and todos.md. Large accounts are exported in the background,
+// @Description	the response is then 202 with the pending export, which can be downloaded from /api/v1/user/export/{id}/download once it is ready.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		application/zip
+// @Produce		json
+// @Success		200	{file}		binary
+// @Success		202	{object}	types.Export
+// @Failure		401	{object}	types.APIError
+// @Router		/api/v1/user/export [get]
+// @Security	ApiKeyAuth
+func (h *ExportHandler) HandleExport(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	page, err := h.todoStore.GetTodos(r.Context(), types.TodoQuery{UserID: user.ID, Limit: 1, Sort: types.TodoSortCreated})
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if page.Total <= h.syncLimit {
+		now := time.Now().UTC()
+		b, err := h.build(r.Context(), user.ID, now)
+		if err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		return writeZIP(w, export.Filename(now), b)
+	}
+
+	// Asking again while an export is running returns that export.
+	e, err := h.store.GetPendingExport(r.Context(), user.ID, time.Now().Add(-types.ExportTimeout))
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if e == nil {
+		e, err = h.store.InsertExport(r.Context(), types.NewExport(user.ID))
+		if err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		h.jobs.Add(1)
+		go h.run(e.ID, user.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/user/export/%d", e.ID))
+	w.WriteHeader(http.StatusAccepted)
+	return utils.ResponseWriteJSON(w, e)
+}
+
+// @Summary		Get the status of an export.
+// @Description	reports whether an export that runs in the background is pending, ready or failed.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Export ID"
+// @Produce		json
+// @Success		200	{object}	types.Export
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/user/export/{id} [get]
+// @Security	ApiKeyAuth
+func (h *ExportHandler) HandleGetExport(w http.ResponseWriter, r *http.Request) *types.APIError {
+	e, apiErr := h.export(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return utils.ResponseWriteJSON(w, e)
+}
+
+// @Summary		Download an export.
+// @Description	downloads the ZIP file of an export that is ready, exports are purged a day after they were started.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Export ID"
+// @Produce		application/zip
+// @Success		200	{file}		binary
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Failure		409	{object}	types.APIError
+// @Router		/api/v1/user/export/{id}/download [get]
+// @Security	ApiKeyAuth
+func (h *ExportHandler) HandleDownloadExport(w http.ResponseWriter, r *http.Request) *types.APIError {
+	e, apiErr := h.export(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if e.Status != types.ExportReady {
+		return types.NewAPIError(false, fmt.Errorf("the export is %s", e.Status), http.StatusConflict)
+	}
+
+	b, err := h.store.GetExportData(r.Context(), e.ID, e.UserID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return writeZIP(w, export.Filename(e.Created), b)
+}
+
+// export returns the export in the URL, exports that were interrupted are reported as failed.
+func (h *ExportHandler) export(r *http.Request) (*types.Export, *types.APIError) {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	e, err := h.store.GetExportByID(r.Context(), id, user.ID)
+	if err != nil {
+		return nil, types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	if e.Interrupted(time.Now()) {
+		e.Status = types.ExportFailed
+		e.Error = "the export was interrupted"
+	}
+
+	return e, nil
+}
+
+// run builds an export in the background, the request that started it is gone by then.
+func (h *ExportHandler) run(id int64, userID int) {
+	defer h.jobs.Done()
+	ctx, cancel := context.WithTimeout(context.Background(), types.ExportTimeout)
+	defer cancel()
+
+	b, err := h.build(ctx, userID, time.Now().UTC())
+	if err != nil {
+		log.Printf("Export %d of user %d failed: %s", id, userID, err)
+		if err := h.store.FailExport(context.Background(), id, "the export failed"); err != nil {
+			log.Printf("Export %d: %s", id, err)
+		}
+		return
+	}
+	if err := h.store.CompleteExport(context.Background(), id, b); err != nil {
+		log.Printf("Export %d: %s", id, err)
+	}
+}
+
+// build collects the data of the user and returns the ZIP file.
+func (h *ExportHandler) build(ctx context.Context, userID int, now time.Time) ([]byte, error) {
+	data, err := h.collect(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	data.ExportedAt = now
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (h *ExportHandler) collect(ctx context.Context, userID int) (*types.ExportData, error) {
+	user, err := h.userStore.GetUserByID(ctx, int64(userID))
+	if err != nil {
+		return nil, err
+	}
+	user.EncryptedPassword = ""
+	data := &types.ExportData{User: user, Todos: []*types.Todo{}, Identities: []*types.ExportIdentity{}}
+
+	mfa, err := h.mfaStore.GetMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	data.TwoFactorEnabled = mfa.Enabled
+	if data.Lists, err = h.listStore.GetLists(ctx, userID, true); err != nil {
+		return nil, err
+	}
+	if data.Tags, err = h.tagStore.GetTags(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	query := types.TodoQuery{UserID: userID, Limit: types.MaxTodoLimit, Sort: types.TodoSortCreated}
+	for {
+		page, err := h.todoStore.GetTodos(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		data.Todos = append(data.Todos, page.Todos...)
+		if page.Next == "" {
+			break
+		}
+		if query.Cursor, err = types.DecodeTodoCursor(page.Next); err != nil {
+			return nil, err
+		}
+	}
+
+	if data.Trash, err = h.todoStore.GetTrashedTodos(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.Sessions, err = h.sessionStore.GetSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	if data.AccessTokens, err = h.accessTokenStore.GetAccessTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+	identities, err := h.identityStore.GetIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		data.Identities = append(data.Identities, &types.ExportIdentity{
+			Provider: identity.Provider,
+			Email:    identity.Email,
+			Created:  identity.Created,
+		})
+	}
+	if data.AuthEvents, err = h.authEventStore.GetAuthEvents(ctx, userID, types.ExportAuthEventLimit); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func writeZIP(w http.ResponseWriter, filename string, b []byte) *types.APIError {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	if _, err := w.Write(b); err != nil {
+		log.Printf("Writing the export: %s", err)
+	}
+	return nil
+}
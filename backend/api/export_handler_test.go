@@ -0,0 +1,156 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/export"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestExport(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	user, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	if _, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+		Title:     "This is the title",
+		Content:   "This is the content",
+		CreatedBy: user.ID,
+	})); err != nil {
+		t.Fatal(err)
+	}
+	// A subtask trashed with its parent is exported as well.
+	parent, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+		Title: "Move house", Content: "Everything", CreatedBy: user.ID,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+		Title: "Pack boxes", Content: "Kitchen first", ParentID: &parent.ID, CreatedBy: user.ID,
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := testSuite.databaseStore.DeleteTodoByID(context.TODO(), parent.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// readExport checks that the response is the ZIP file of the user.
+	readExport := func(t *testing.T, body []byte) {
+		t.Helper()
+		z, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]*zip.File{}
+		for _, f := range z.File {
+			files[f.Name] = f
+		}
+		for _, name := range []string{export.DataFile, export.CSVFile, export.MarkdownFile} {
+			if files[name] == nil {
+				t.Fatalf("expected %s in the export", name)
+			}
+		}
+		f, err := files[export.DataFile].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var data types.ExportData
+		if err := json.NewDecoder(f).Decode(&data); err != nil {
+			t.Fatal(err)
+		}
+		if data.User.Email != user.Email || len(data.Todos) != 1 || len(data.Lists) != 1 || len(data.Sessions) != 1 {
+			t.Errorf("expected the profile, todo, inbox and session of the user, got %+v", data)
+		}
+		if len(data.Trash) != 2 {
+			t.Errorf("expected the trashed todo and its subtask, got %+v", data.Trash)
+		}
+	}
+
+	t.Run("Sync", func(t *testing.T) {
+		rr := do(t, r, http.MethodGet, "/user/export", nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/zip" {
+			t.Errorf("expected a ZIP file, got %s", ct)
+		}
+		readExport(t, rr.Body.Bytes())
+	})
+
+	t.Run("Async", func(t *testing.T) {
+		testSuite.exportHandler.syncLimit = 0
+		defer func() { testSuite.exportHandler.syncLimit = types.ExportSyncLimit }()
+
+		rr := do(t, r, http.MethodGet, "/user/export", nil, token)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusAccepted, rr.Code, rr.Body.String())
+		}
+		var e types.Export
+		if err := json.NewDecoder(rr.Body).Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if location := rr.Header().Get("Location"); location != fmt.Sprintf("/api/v1/user/export/%d", e.ID) {
+			t.Errorf("expected the location of the export, got %s", location)
+		}
+		testSuite.exportHandler.jobs.Wait()
+
+		target := fmt.Sprintf("/user/export/%d", e.ID)
+		rr = do(t, r, http.MethodGet, target, nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Status != types.ExportReady || e.Finished == nil || e.Size == 0 {
+			t.Fatalf("expected the export to be ready, got %+v", e)
+		}
+
+		rr = do(t, r, http.MethodGet, target+"/download", nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		readExport(t, rr.Body.Bytes())
+
+		for _, target := range []string{target, target + "/download"} {
+			if rr := do(t, r, http.MethodGet, target, nil, otherToken); rr.Code != http.StatusNotFound {
+				t.Errorf("expected the export of another user to be unknown, got %v", rr.Code)
+			}
+		}
+	})
+
+	t.Run("Pending", func(t *testing.T) {
+		pending, err := testSuite.exportStore.InsertExport(context.TODO(), types.NewExport(user.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr := do(t, r, http.MethodGet, fmt.Sprintf("/user/export/%d/download", pending.ID), nil, token); rr.Code != http.StatusConflict {
+			t.Errorf("expected http status code %v got %v", http.StatusConflict, rr.Code)
+		}
+
+		interrupted := types.NewExport(user.ID)
+		interrupted.Created = time.Now().Add(-types.ExportTimeout - time.Minute)
+		if _, err := testSuite.exportStore.InsertExport(context.TODO(), interrupted); err != nil {
+			t.Fatal(err)
+		}
+		rr := do(t, r, http.MethodGet, fmt.Sprintf("/user/export/%d", interrupted.ID), nil, token)
+		var e types.Export
+		if err := json.NewDecoder(rr.Body).Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Status != types.ExportFailed {
+			t.Errorf("expected the interrupted export to be reported as failed, got %+v", e)
+		}
+	})
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/thimc/go-svelte-todo/backend/api/middleware"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type JWKSHandler struct {
+	keys *middleware.KeySet
+}
+
+func NewJWKSHandler(keys *middleware.KeySet) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// @Summary		Get the token keys.
+// @Description	lists the public keys that the JWT tokens are signed with, the "kid" header of a token names its key.
+// @Description	Keys of earlier signing keys are listed until they are removed from the configuration.
+// @Tags		auth
+// @Produce		json
+// @Success		200	{object}	types.JWKSResponse
+// @Router		/.well-known/jwks.json [get]
+func (h *JWKSHandler) HandleGetJWKS(w http.ResponseWriter, r *http.Request) *types.APIError {
+	// A new signing key is added to the set before tokens are signed with it,
+	// so clients only need to refetch the keys for an unknown key ID.
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return utils.ResponseWriteJSON(w, h.keys.JWKS())
+}
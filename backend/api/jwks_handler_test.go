@@ -0,0 +1,142 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/api/middleware"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+func TestJWKS(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	oldPublic, oldPrivate, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKeys, err := middleware.NewKeySet("test", "test-api", oldPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rotated key set signs with the new key and still accepts the old one.
+	newKeys, err := middleware.NewKeySet("test", "test-api", newPrivate, oldPublic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testSuite.keys = oldKeys
+	user, oldToken := testSuite.createUser(t)
+	_, newToken, err := newKeys.CreateJWT(user, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtMiddleware := middleware.NewJWTMiddleware(testSuite.userStore, testSuite.sessionStore, testSuite.accessTokenStore, newKeys)
+	r := mux.NewRouter()
+	r.HandleFunc("/.well-known/jwks.json", utils.HandleAPIFunc(NewJWKSHandler(newKeys).HandleGetJWKS)).Methods(http.MethodGet)
+	protected := r.PathPrefix("/").Subrouter()
+	protected.Use(jwtMiddleware.Middleware)
+	protected.HandleFunc("/todos", utils.HandleAPIFunc(testSuite.todoHandler.HandleGetTodos)).Methods(http.MethodGet)
+
+	rr := do(t, r, http.MethodGet, "/.well-known/jwks.json", nil, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var jwks types.JWKSResponse
+	if err := json.NewDecoder(rr.Body).Decode(&jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Alg != "RS256" || jwks.Keys[1].Alg != "EdDSA" {
+		t.Fatalf("expected the RS256 signing key and the EdDSA verification key, got %+v", jwks.Keys)
+	}
+
+	t.Run("Rotation", func(t *testing.T) {
+		if rr := do(t, r, http.MethodGet, "/todos", nil, oldToken); rr.Code != http.StatusOK {
+			t.Errorf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		// The new token has no session, only its signature is verified here.
+		if _, err := newKeys.ValidateJWT(newToken); err != nil {
+			t.Errorf("expected the token of the signing key to be valid, got %v", err)
+		}
+		if _, err := oldKeys.ValidateJWT(newToken); err == nil {
+			t.Errorf("expected the old key set to reject the token of an unknown key")
+		}
+	})
+
+	t.Run("PublishedKey", func(t *testing.T) {
+		// Other services verify the tokens with the published key alone.
+		n, errN := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+		if errN != nil || errE != nil {
+			t.Fatalf("expected a base64url encoded RSA key, got %+v", jwks.Keys[0])
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		tok, err := jwt.Parse(newToken, func(tok *jwt.Token) (interface{}, error) {
+			if tok.Header["kid"] != jwks.Keys[0].Kid {
+				t.Errorf("expected the kid %s, got %v", jwks.Keys[0].Kid, tok.Header["kid"])
+			}
+			return public, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := tok.Claims.(jwt.MapClaims)
+		if claims["iss"] != "test" || claims["aud"] != "test-api" || claims["sub"] != strconv.Itoa(user.ID) {
+			t.Errorf("expected the standard claims, got %+v", claims)
+		}
+		for _, claim := range []string{"iat", "exp"} {
+			if _, ok := claims[claim]; !ok {
+				t.Errorf("expected the %s claim, got %+v", claim, claims)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, unknownPrivate, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unknownKeys, err := middleware.NewKeySet("test", "test-api", unknownPrivate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, unknownToken, err := unknownKeys.CreateJWT(user, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, challenge, err := newKeys.CreateMFAChallenge(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherAudience, err := middleware.NewKeySet("test", "other-api", newPrivate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, otherToken, err := otherAudience.CreateJWT(user, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, token := range map[string]string{"UnknownKey": unknownToken, "Challenge": challenge, "Audience": otherToken} {
+			if rr := do(t, r, http.MethodGet, "/todos", nil, token); rr.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected http status code %v got %v", name, http.StatusBadRequest, rr.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type ListHandler struct {
+	store     store.ListStorer
+	todoStore store.TodoStorer
+}
+
+func NewListHandler(listStore store.ListStorer, todoStore store.TodoStorer) *ListHandler {
+	return &ListHandler{
+		store:     listStore,
+		todoStore: todoStore,
+	}
+}
+
+// @Summary		Get all lists.
+// @Description	fetch the lists of the authenticated user sorted by their position.
+// @Tags		lists
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		archived	query	bool	false	"Include the archived lists"	default(false)
+// @Produce		json
+// @Success		200	{object}	types.ListGetAllResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/lists [get]
+// @Security	ApiKeyAuth
+func (h *ListHandler) HandleGetLists(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	var archived bool
+	if value := r.URL.Query().Get("archived"); value != "" {
+		var err error
+		if archived, err = strconv.ParseBool(value); err != nil {
+			return types.NewAPIError(false, fmt.Errorf("archived needs to be true or false"), http.StatusBadRequest)
+		}
+	}
+	lists, err := h.store.GetLists(r.Context(), user.ID, archived)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewListGetAllResponse(lists))
+}
+
+// @Summary		Get a list by the ID.
+// @Description	fetch one list.
+// @Tags		lists
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"List ID"
+// @Produce		json
+// @Success		200	{object}	types.List
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/lists/{id} [get]
+// @Security	ApiKeyAuth
+func (h *ListHandler) HandleGetListByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	list, err := h.store.GetListByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, list)
+}
+
+// @Summary		Get the todos of a list.
+// @Description	fetch a page of the todos in the list, takes the same query parameters as /api/v1/todos.
+// @Tags		lists
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"List ID"
+// @Param		limit	query	int	false	"Page size, at most 100"	default(50)
+// @Param		cursor	query	string	false	"The next cursor of the previous page"
+// @Param		sort	query	string	false	"created, updated, title or done, prefix with - to sort descending"	default(created)
+// @Produce		json
+// @Success		200	{object}	types.TodoGetAllResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/lists/{id}/todos [get]
+// @Security	ApiKeyAuth
+func (h *ListHandler) HandleGetListTodos(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	list, err := h.store.GetListByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	query, err := types.ParseTodoQuery(r.URL.Query())
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	query.UserID = user.ID
+	query.ListID = &list.ID
+
+	page, err := h.todoStore.GetTodos(r.Context(), query)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewTodoGetAllResponse(page))
+}
+
+// @Summary		Create a list.
+// @Description	create a list to group todos in.
+// @Tags		lists
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.ListParams	true	"List metadata"
+// @Produce		json
+// @Success		200	{object}	types.List
+// @Failure		400	{object}	types.APIError
+// @Router		/api/v1/lists [post]
+// @Security	ApiKeyAuth
+func (h *ListHandler) HandleInsertList(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	var params types.ListParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	list := types.NewListFromParams(params, user.ID)
+	if err := list.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	insertedList, err := h.store.InsertList(r.Context(), list)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	return utils.ResponseWriteJSON(w, insertedList)
+}
+
+// @Summary		Patch a list.
+// @Description	renames, reorders or (un)archives a list and returns the updated list. The inbox can't be archived.
+// @Tags		lists
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"List ID"
+// @Param		params	body	types.UpdateListParams	true	"New list data"
+// @Produce		json
+// @Success		200	{object}	types.List
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/lists/{id} [patch]
+// @Security	ApiKeyAuth
+func (h *ListHandler) HandlePatchListByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	var params types.UpdateListParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	list, err := h.store.GetListByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	if list.Inbox && params.Archived != nil && *params.Archived {
+		return types.NewAPIError(false, fmt.Errorf("the inbox can't be archived"), http.StatusBadRequest)
+	}
+
+	list, err = h.store.PatchListByID(r.Context(), int64(id), user.ID, params)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, list)
+}
+
+// @Summary		Delete a list.
+// @Description	deletes a list and moves its todos to the inbox. The inbox can't be deleted.
+// @Tags		lists
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"List ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/lists/{id} [delete]
+// @Security	ApiKeyAuth
+func (h *ListHandler) HandleDeleteListByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	list, err := h.store.GetListByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	if list.Inbox {
+		return types.NewAPIError(false, fmt.Errorf("the inbox can't be deleted"), http.StatusBadRequest)
+	}
+
+	if err := h.store.DeleteListByID(r.Context(), int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("list ID: %d", id), http.StatusOK))
+}
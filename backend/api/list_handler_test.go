@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestListCRUD(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	rr := do(t, r, http.MethodPost, "/lists", types.ListParams{Name: "Groceries", Position: 2}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var list types.List
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.ID == 0 || list.Name != "Groceries" || list.Inbox || list.Archived {
+		t.Fatalf("expected a new list, got %+v", list)
+	}
+
+	rr = do(t, r, http.MethodPost, "/lists", types.ListParams{}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v for a list without a name, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	target := fmt.Sprintf("/lists/%d", list.ID)
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		name := "Stolen"
+		rr := do(t, r, method, target, types.UpdateListParams{Name: &name}, otherToken)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected http status code %v, got %v", method, http.StatusNotFound, rr.Code)
+		}
+	}
+
+	var lists types.ListGetAllResponse
+	rr = do(t, r, http.MethodGet, "/lists", nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&lists); err != nil {
+		t.Fatal(err)
+	}
+	if lists.Count != 2 || !lists.Result[0].Inbox || lists.Result[1].ID != list.ID {
+		t.Fatalf("expected the inbox followed by the new list, got %+v", lists.Result)
+	}
+	inbox := lists.Result[0]
+
+	archived := true
+	rr = do(t, r, http.MethodPatch, target, types.UpdateListParams{Archived: &archived}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = do(t, r, http.MethodGet, "/lists", nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&lists); err != nil {
+		t.Fatal(err)
+	}
+	if lists.Count != 1 {
+		t.Fatalf("expected the archived list to be hidden, got %+v", lists.Result)
+	}
+	rr = do(t, r, http.MethodGet, "/lists?archived=true", nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&lists); err != nil {
+		t.Fatal(err)
+	}
+	if lists.Count != 2 {
+		t.Fatalf("expected the archived list to be included, got %+v", lists.Result)
+	}
+
+	inboxTarget := fmt.Sprintf("/lists/%d", inbox.ID)
+	rr = do(t, r, http.MethodPatch, inboxTarget, types.UpdateListParams{Archived: &archived}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v when archiving the inbox, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodDelete, inboxTarget, nil, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v when deleting the inbox, got %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestListTodos(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	var list, otherList types.List
+	rr := do(t, r, http.MethodPost, "/lists", types.ListParams{Name: "Work"}, token)
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	rr = do(t, r, http.MethodPost, "/lists", types.ListParams{Name: "Work"}, otherToken)
+	if err := json.NewDecoder(rr.Body).Decode(&otherList); err != nil {
+		t.Fatal(err)
+	}
+
+	var inboxTodo, listTodo types.Todo
+	rr = do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Inbox todo", Content: "Sort me"}, token)
+	if err := json.NewDecoder(rr.Body).Decode(&inboxTodo); err != nil {
+		t.Fatal(err)
+	}
+	if inboxTodo.ListID == nil {
+		t.Fatalf("expected a todo without a list to be put in the inbox, got %+v", inboxTodo)
+	}
+	rr = do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Work todo", Content: "Ship it", ListID: &list.ID}, token)
+	if err := json.NewDecoder(rr.Body).Decode(&listTodo); err != nil {
+		t.Fatal(err)
+	}
+	if listTodo.ListID == nil || *listTodo.ListID != list.ID {
+		t.Fatalf("expected the todo to be in list %d, got %+v", list.ID, listTodo)
+	}
+
+	rr = do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Sneaky", Content: "Not mine", ListID: &otherList.ID}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v for a list of another user, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", inboxTodo.ID), types.UpdateTodoParams{ListID: &otherList.ID}, token)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v when moving to a list of another user, got %v", http.StatusNotFound, rr.Code)
+	}
+
+	target := fmt.Sprintf("/lists/%d/todos", list.ID)
+	var page types.TodoGetAllResponse
+	rr = do(t, r, http.MethodGet, target, nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 1 || page.Result[0].ID != listTodo.ID {
+		t.Fatalf("expected only the todo of the list, got %+v", page.Result)
+	}
+	rr = do(t, r, http.MethodGet, target, nil, otherToken)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v for the list of another user, got %v", http.StatusNotFound, rr.Code)
+	}
+
+	rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", inboxTodo.ID), types.UpdateTodoParams{ListID: &list.ID}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = do(t, r, http.MethodGet, target, nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 2 {
+		t.Fatalf("expected the moved todo in the list, got %+v", page.Result)
+	}
+
+	rr = do(t, r, http.MethodDelete, fmt.Sprintf("/lists/%d", list.ID), nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d", listTodo.ID), nil, token)
+	var moved types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved.ListID == nil || *moved.ListID != *inboxTodo.ListID {
+		t.Fatalf("expected the todos of a deleted list to move to the inbox, got %+v", moved)
+	}
+}
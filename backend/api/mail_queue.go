@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// At most this many mails of the anonymous endpoints are sent at once.
+	mailQueueSize = 32
+	// The background work of a mail gives up after this long.
+	mailTimeout = time.Minute
+)
+
+// mailQueue looks up the accounts and sends the mails of the anonymous
+// endpoints off the request path, so the response takes as long whether the
+// account exists or not. Mails beyond the size of the queue are dropped
+// instead of piling up.
+type mailQueue struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newMailQueue(size int) *mailQueue {
+	return &mailQueue{
+		slots: make(chan struct{}, size),
+	}
+}
+
+// run calls send in the background with a context that ends after mailTimeout,
+// it returns false if the queue is full.
+func (q *mailQueue) run(send func(context.Context)) bool {
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return false
+	}
+
+	q.wg.Add(1)
+	go func() {
+		defer func() {
+			<-q.slots
+			q.wg.Done()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		send(ctx)
+	}()
+	return true
+}
+
+// wait blocks until the mails being sent are done.
+func (q *mailQueue) wait() {
+	q.wg.Wait()
+}
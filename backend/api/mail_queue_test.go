@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMailQueue(t *testing.T) {
+	q := newMailQueue(2)
+	release := make(chan struct{})
+	block := func(ctx context.Context) { <-release }
+
+	if !q.run(block) || !q.run(block) {
+		t.Fatal("expected the queue to take 2 mails")
+	}
+	if q.run(block) {
+		t.Error("expected a full queue to drop the mail")
+	}
+
+	close(release)
+	q.wait()
+	if !q.run(func(ctx context.Context) {}) {
+		t.Error("expected the queue to take mails again once the others are done")
+	}
+	q.wait()
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+// The issuer that authenticator apps show next to the account.
+const mfaIssuer = "Todo"
+
+type MFAHandler struct {
+	store store.MFAStorer
+}
+
+func NewMFAHandler(mfaStore store.MFAStorer) *MFAHandler {
+	return &MFAHandler{
+		store: mfaStore,
+	}
+}
+
+// @Summary		Get the two-factor authentication status.
+// @Description	reports whether two-factor authentication is enabled and how many recovery codes are left.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.MFAStatusResponse
+// @Failure		403	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/user/mfa [get]
+// @Security	ApiKeyAuth
+func (h *MFAHandler) HandleGetMFA(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	mfa, err := h.store.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	count, err := h.store.CountRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.MFAStatusResponse{
+		Enabled:       mfa.Enabled,
+		RecoveryCodes: count,
+	})
+}
+
+// @Summary		Enroll in two-factor authentication.
+// @Description	creates a TOTP secret for an authenticator app, two-factor authentication is enabled once a code of it is confirmed.
+// @Description	Enrolling again replaces a secret that hasn't been confirmed.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.MFAEnrollResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Router		/api/v1/user/mfa [post]
+// @Security	ApiKeyAuth
+func (h *MFAHandler) HandleEnrollMFA(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+
+	secret, err := types.NewTOTPSecret()
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.store.SetMFASecret(r.Context(), user.ID, secret); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	return utils.ResponseWriteJSON(w, types.MFAEnrollResponse{
+		Secret: secret,
+		URI:    types.TOTPURI(secret, mfaIssuer, user.Email),
+	})
+}
+
+// @Summary		Confirm two-factor authentication.
+// @Description	enables two-factor authentication with a code of the enrolled secret and returns the recovery codes, they are only shown once.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.MFACodeParams	true	"A code from the authenticator app"
+// @Produce		json
+// @Success		200	{object}	types.MFARecoveryCodesResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Router		/api/v1/user/mfa/confirm [post]
+// @Security	ApiKeyAuth
+func (h *MFAHandler) HandleConfirmMFA(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+
+	var params types.MFACodeParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	mfa, err := h.store.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if mfa.Secret == "" || mfa.Enabled {
+		return types.NewAPIError(false, fmt.Errorf("two-factor authentication is not pending"), http.StatusBadRequest)
+	}
+	ok, err := checkMFACode(r.Context(), h.store, mfa, params.Code)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !ok {
+		return types.NewAPIError(false, fmt.Errorf("Invalid code"), http.StatusBadRequest)
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.store.EnableMFA(r.Context(), user.ID, hashes); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	return utils.ResponseWriteJSON(w, types.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary		Replace the recovery codes.
+// @Description	invalidates the recovery codes and returns new ones, they are only shown once.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.MFACodeParams	true	"A code from the authenticator app or a recovery code"
+// @Produce		json
+// @Success		200	{object}	types.MFARecoveryCodesResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Router		/api/v1/user/mfa/recovery-codes [post]
+// @Security	ApiKeyAuth
+func (h *MFAHandler) HandleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	if apiErr := h.checkCode(r, user); apiErr != nil {
+		return apiErr
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.store.SetRecoveryCodes(r.Context(), user.ID, hashes); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	return utils.ResponseWriteJSON(w, types.MFARecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary		Disable two-factor authentication.
+// @Description	removes the secret and the recovery codes, logins only need the password again.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.MFACodeParams	true	"A code from the authenticator app or a recovery code"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Router		/api/v1/user/mfa/disable [post]
+// @Security	ApiKeyAuth
+func (h *MFAHandler) HandleDisableMFA(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	if apiErr := h.checkCode(r, user); apiErr != nil {
+		return apiErr
+	}
+
+	if err := h.store.DisableMFA(r.Context(), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("two-factor authentication disabled"), http.StatusOK))
+}
+
+// checkCode decodes the MFACodeParams of the request and checks the code
+// against the enabled two-factor authentication of the user.
+func (h *MFAHandler) checkCode(r *http.Request, user *types.User) *types.APIError {
+	var params types.MFACodeParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	mfa, err := h.store.GetMFA(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !mfa.Enabled {
+		return types.NewAPIError(false, fmt.Errorf("two-factor authentication is not enabled"), http.StatusBadRequest)
+	}
+	ok, err := checkMFACode(r.Context(), h.store, mfa, params.Code)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !ok {
+		return types.NewAPIError(false, fmt.Errorf("Invalid code"), http.StatusBadRequest)
+	}
+	return nil
+}
+
+// checkMFACode reports whether the code is a valid TOTP code or, once two-factor
+// authentication is enabled, an unused recovery code. A valid code is spent.
+func checkMFACode(ctx context.Context, s store.MFAStorer, mfa *types.MFA, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if counter, ok := types.ValidTOTP(mfa.Secret, code, time.Now()); ok {
+		return s.UseTOTPCounter(ctx, mfa.UserID, counter)
+	}
+	if !mfa.Enabled {
+		return false, nil
+	}
+	return s.UseRecoveryCode(ctx, mfa.UserID, types.HashRecoveryCode(code))
+}
+
+// newRecoveryCodes returns new recovery codes and the hashes to store.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := types.NewRecoveryCodes(types.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := []string{}
+	for _, code := range codes {
+		hashes = append(hashes, types.HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
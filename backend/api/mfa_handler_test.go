@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+func TestMFA(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	public := mux.NewRouter()
+	public.HandleFunc("/login", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
+	public.HandleFunc("/login/mfa", utils.HandleAPIFunc(testSuite.authHandler.HandleLoginMFA)).Methods(http.MethodPost)
+
+	user, token := testSuite.createUser(t)
+	credentials := types.UserParams{Email: user.Email, Password: "secret-password"}
+
+	expect := func(t *testing.T, method, target string, body any, token string, status int, v any) {
+		t.Helper()
+		router := r
+		if !strings.HasPrefix(target, "/user") {
+			router = public
+		}
+		rr := do(t, router, method, target, body, token)
+		if rr.Code != status {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", status, rr.Code, rr.Body.String())
+		}
+		if v != nil {
+			if err := json.NewDecoder(rr.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// The steps are relative to the start so that the replayed code stays a replay.
+	start := types.TOTPCounter(time.Now())
+	code := func(t *testing.T, secret string, step int64) string {
+		t.Helper()
+		code, err := types.TOTPCode(secret, start+step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	t.Run("RFC 6238", func(t *testing.T) {
+		// The SHA1 test vector of RFC 6238 appendix B, truncated to six digits.
+		code, err := types.TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", types.TOTPCounter(time.Unix(59, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != "287082" {
+			t.Errorf("expected code 287082, got %s", code)
+		}
+	})
+
+	var enrolled types.MFAEnrollResponse
+	var recovery types.MFARecoveryCodesResponse
+	t.Run("Enroll", func(t *testing.T) {
+		expect(t, http.MethodPost, "/user/mfa/disable", types.MFACodeParams{Code: "123456"}, token, http.StatusBadRequest, nil)
+
+		expect(t, http.MethodPost, "/user/mfa", nil, token, http.StatusOK, &enrolled)
+		if enrolled.Secret == "" || !strings.HasPrefix(enrolled.URI, "otpauth://totp/") || !strings.Contains(enrolled.URI, "secret="+enrolled.Secret) {
+			t.Fatalf("expected a secret and an otpauth URI, got %+v", enrolled)
+		}
+
+		// A pending enrollment doesn't change the login.
+		var login types.LoginResponse
+		expect(t, http.MethodPost, "/login", credentials, "", http.StatusOK, &login)
+		if login.Token == "" {
+			t.Fatalf("expected a jwt token before the enrollment is confirmed, got %+v", login)
+		}
+
+		expect(t, http.MethodPost, "/user/mfa/confirm", types.MFACodeParams{Code: "abcdef"}, token, http.StatusBadRequest, nil)
+		expect(t, http.MethodPost, "/user/mfa/confirm", types.MFACodeParams{Code: code(t, enrolled.Secret, 0)}, token, http.StatusOK, &recovery)
+		if len(recovery.RecoveryCodes) != types.RecoveryCodeCount {
+			t.Fatalf("expected %d recovery codes, got %v", types.RecoveryCodeCount, recovery.RecoveryCodes)
+		}
+		expect(t, http.MethodPost, "/user/mfa", nil, token, http.StatusBadRequest, nil)
+
+		var status types.MFAStatusResponse
+		expect(t, http.MethodGet, "/user/mfa", nil, token, http.StatusOK, &status)
+		if !status.Enabled || status.RecoveryCodes != types.RecoveryCodeCount {
+			t.Errorf("expected two-factor authentication with %d recovery codes, got %+v", types.RecoveryCodeCount, status)
+		}
+	})
+
+	challenge := func(t *testing.T) string {
+		t.Helper()
+		var resp types.MFAChallengeResponse
+		expect(t, http.MethodPost, "/login", credentials, "", http.StatusOK, &resp)
+		if !resp.MFARequired || resp.ChallengeToken == "" {
+			t.Fatalf("expected an MFA challenge, got %+v", resp)
+		}
+		return resp.ChallengeToken
+	}
+
+	t.Run("Login", func(t *testing.T) {
+		challengeToken := challenge(t)
+
+		// The challenge is no access token.
+		expect(t, http.MethodGet, "/user/mfa", nil, challengeToken, http.StatusBadRequest, nil)
+		expect(t, http.MethodPost, "/login/mfa", types.MFALoginParams{ChallengeToken: token, Code: code(t, enrolled.Secret, 1)}, "", http.StatusUnauthorized, nil)
+
+		// The code of the confirmation was used already.
+		expect(t, http.MethodPost, "/login/mfa", types.MFALoginParams{ChallengeToken: challengeToken, Code: code(t, enrolled.Secret, 0)}, "", http.StatusUnauthorized, nil)
+
+		var login types.LoginResponse
+		expect(t, http.MethodPost, "/login/mfa", types.MFALoginParams{ChallengeToken: challengeToken, Code: code(t, enrolled.Secret, 1)}, "", http.StatusOK, &login)
+		if login.Token == "" || login.RefreshToken == "" || login.ID != user.ID {
+			t.Fatalf("expected a login response, got %+v", login)
+		}
+		expect(t, http.MethodGet, "/user/mfa", nil, login.Token, http.StatusOK, nil)
+	})
+
+	t.Run("Recovery codes", func(t *testing.T) {
+		// Recovery codes can be typed without the dash and in upper case.
+		recoveryCode := strings.ToUpper(strings.ReplaceAll(recovery.RecoveryCodes[0], "-", ""))
+		expect(t, http.MethodPost, "/login/mfa", types.MFALoginParams{ChallengeToken: challenge(t), Code: recoveryCode}, "", http.StatusOK, nil)
+		expect(t, http.MethodPost, "/login/mfa", types.MFALoginParams{ChallengeToken: challenge(t), Code: recoveryCode}, "", http.StatusUnauthorized, nil)
+
+		var status types.MFAStatusResponse
+		expect(t, http.MethodGet, "/user/mfa", nil, token, http.StatusOK, &status)
+		if status.RecoveryCodes != types.RecoveryCodeCount-1 {
+			t.Errorf("expected %d recovery codes, got %d", types.RecoveryCodeCount-1, status.RecoveryCodes)
+		}
+
+		var regenerated types.MFARecoveryCodesResponse
+		expect(t, http.MethodPost, "/user/mfa/recovery-codes", types.MFACodeParams{Code: recovery.RecoveryCodes[1]}, token, http.StatusOK, &regenerated)
+		expect(t, http.MethodPost, "/login/mfa", types.MFALoginParams{ChallengeToken: challenge(t), Code: recovery.RecoveryCodes[2]}, "", http.StatusUnauthorized, nil)
+		recovery = regenerated
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		expect(t, http.MethodPost, "/user/mfa/disable", types.MFACodeParams{Code: "wrong-code"}, token, http.StatusBadRequest, nil)
+		expect(t, http.MethodPost, "/user/mfa/disable", types.MFACodeParams{Code: recovery.RecoveryCodes[0]}, token, http.StatusOK, nil)
+
+		var login types.LoginResponse
+		expect(t, http.MethodPost, "/login", credentials, "", http.StatusOK, &login)
+		if login.Token == "" {
+			t.Fatalf("expected a jwt token without two-factor authentication, got %+v", login)
+		}
+	})
+}
@@ -2,9 +2,10 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,13 +15,25 @@ import (
 	"github.com/thimc/go-svelte-todo/backend/utils"
 )
 
+// Access tokens are short lived, clients get a new one with their refresh token.
+const AccessTokenTTL = 15 * time.Minute
+
+// The last seen time of sessions and access tokens is only written once per interval.
+const touchInterval = time.Minute
+
 type JWTMiddleware struct {
-	store store.UserStorer
+	store            store.UserStorer
+	sessionStore     store.SessionStorer
+	accessTokenStore store.AccessTokenStorer
+	keys             *KeySet
 }
 
-func NewJWTMiddleware(store store.UserStorer) *JWTMiddleware {
+func NewJWTMiddleware(store store.UserStorer, sessionStore store.SessionStorer, accessTokenStore store.AccessTokenStorer, keys *KeySet) *JWTMiddleware {
 	return &JWTMiddleware{
-		store: store,
+		store:            store,
+		sessionStore:     sessionStore,
+		accessTokenStore: accessTokenStore,
+		keys:             keys,
 	}
 }
 
@@ -37,50 +50,169 @@ func (m *JWTMiddleware) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tok, err := ValidateJWT(tokenArr[1])
-		if err != nil || !tok.Valid {
-			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Invalid token"), http.StatusBadRequest))
+		if strings.HasPrefix(tokenArr[1], types.AccessTokenPrefix) {
+			m.accessTokenMiddleware(w, r, next, tokenArr[1])
 			return
 		}
 
-		claims := tok.Claims.(jwt.MapClaims)
-		if time.Now().Unix() > int64(claims["expiresAt"].(float64)) {
-			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Token expired"), http.StatusUnauthorized))
+		claims, err := m.keys.ValidateJWT(tokenArr[1])
+		if errors.Is(err, ErrTokenExpired) {
+			utils.WriteJSON(w, types.NewAPIError(false, err, http.StatusUnauthorized))
+			return
+		}
+		if err != nil {
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Invalid token"), http.StatusBadRequest))
 			return
 		}
 
-		email := claims["email"].(string)
-		user, err := m.store.GetUserByEmail(r.Context(), email)
+		sub, _ := claims["sub"].(string)
+		userID, err := strconv.ParseInt(sub, 10, 64)
 		if err != nil {
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Invalid token"), http.StatusBadRequest))
+			return
+		}
+		user, err := m.store.GetUserByID(r.Context(), userID)
+		if err != nil || user.Disabled || user.DeleteAt != nil {
 			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Access denied"), http.StatusUnauthorized))
 			return
 		}
 		user.EncryptedPassword = ""
 
+		// Tokens stop working once their session is signed out, not only once they expire.
+		sessionID, ok := claims["sid"].(float64)
+		if !ok {
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Invalid token"), http.StatusUnauthorized))
+			return
+		}
+		session, err := m.sessionStore.GetSessionByID(r.Context(), int64(sessionID), user.ID)
+		if err != nil {
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Session revoked"), http.StatusUnauthorized))
+			return
+		}
+		if now := time.Now(); now.Sub(session.LastSeen) > touchInterval {
+			if err := m.sessionStore.TouchSession(r.Context(), session.ID, now); err != nil {
+				utils.WriteJSON(w, types.NewAPIError(false, err, http.StatusInternalServerError))
+				return
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), "user", user)
+		ctx = context.WithValue(ctx, "session", session.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-func CreateJWT(user *types.User) (jwt.MapClaims, string, error) {
-	claims := &jwt.MapClaims{
-		"id":        user.ID,
-		"email":     user.Email,
-		"expiresAt": time.Now().Add(time.Hour * 6).Unix(),
+// accessTokenMiddleware authenticates a request made with a personal access token.
+// Those requests have no session in their context.
+func (m *JWTMiddleware) accessTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	accessToken, err := m.accessTokenStore.GetAccessTokenByHash(r.Context(), types.HashToken(token))
+	if err != nil {
+		utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Invalid token"), http.StatusUnauthorized))
+		return
+	}
+	now := time.Now()
+	if accessToken.Expired(now) {
+		utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Token expired"), http.StatusUnauthorized))
+		return
+	}
+	if !accessToken.Allows(r.Method) {
+		utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("the token needs the %s scope", types.ScopeWrite), http.StatusForbidden))
+		return
 	}
-	secret := os.Getenv("JWT_SECRET")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tok, err := token.SignedString([]byte(secret))
 
-	return *claims, tok, err
-}
+	user, err := m.store.GetUserByID(r.Context(), int64(accessToken.UserID))
+	if err != nil || user.Disabled || user.DeleteAt != nil {
+		utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("Access denied"), http.StatusUnauthorized))
+		return
+	}
+	user.EncryptedPassword = ""
 
-func ValidateJWT(token string) (*jwt.Token, error) {
-	secret := os.Getenv("JWT_SECRET")
-	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Invalid token")
+	if accessToken.LastUsed == nil || now.Sub(*accessToken.LastUsed) > touchInterval {
+		if err := m.accessTokenStore.TouchAccessToken(r.Context(), accessToken.ID, now); err != nil {
+			utils.WriteJSON(w, types.NewAPIError(false, err, http.StatusInternalServerError))
+			return
 		}
-		return []byte(secret), nil
-	})
+	}
+
+	ctx := context.WithValue(r.Context(), "user", user)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// CreateJWT creates the access token of the session, its subject is the user ID.
+func (k *KeySet) CreateJWT(user *types.User, sessionID int64) (jwt.MapClaims, string, error) {
+	claims := jwt.MapClaims{
+		"sub":   strconv.Itoa(user.ID),
+		"email": user.Email,
+		"sid":   sessionID,
+	}
+	return k.sign(claims, k.audience, AccessTokenTTL)
+}
+
+// ValidateJWT returns the claims of an unexpired access token.
+func (k *KeySet) ValidateJWT(token string) (jwt.MapClaims, error) {
+	return k.parse(token, k.audience)
+}
+
+// The "typ" claims of MFA challenge and OAuth flow tokens. They are only meant for
+// the API itself, so their audience is the issuer and never the access token audience.
+const (
+	mfaChallengeType = "mfa"
+	oauthFlowType    = "oauth"
+)
+
+// CreateMFAChallenge creates the token that a user with two-factor authentication
+// gets for the password, it is exchanged together with a code for a JWT token.
+func (k *KeySet) CreateMFAChallenge(user *types.User) (jwt.MapClaims, string, error) {
+	claims := jwt.MapClaims{
+		"sub": strconv.Itoa(user.ID),
+		"typ": mfaChallengeType,
+	}
+	return k.sign(claims, k.issuer, types.MFAChallengeTTL)
+}
+
+// ValidateMFAChallenge returns the user ID of an unexpired MFA challenge token.
+func (k *KeySet) ValidateMFAChallenge(token string) (int, error) {
+	claims, err := k.parse(token, k.issuer)
+	if errors.Is(err, ErrTokenExpired) {
+		return 0, fmt.Errorf("Challenge token expired")
+	}
+	if err != nil || claims["typ"] != mfaChallengeType {
+		return 0, fmt.Errorf("Invalid challenge token")
+	}
+	sub, _ := claims["sub"].(string)
+	id, err := strconv.Atoi(sub)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid challenge token")
+	}
+	return id, nil
+}
+
+// CreateOAuthFlow creates the token that keeps the flow between the start of an
+// OAuth login and its callback.
+func (k *KeySet) CreateOAuthFlow(flow *types.OAuthFlow) (jwt.MapClaims, string, error) {
+	claims := jwt.MapClaims{
+		"typ":      oauthFlowType,
+		"provider": flow.Provider,
+		"state":    flow.State,
+		"nonce":    flow.Nonce,
+		"verifier": flow.Verifier,
+	}
+	return k.sign(claims, k.issuer, types.OAuthFlowTTL)
+}
+
+// ValidateOAuthFlow returns the flow of an unexpired OAuth flow token.
+func (k *KeySet) ValidateOAuthFlow(token string) (*types.OAuthFlow, error) {
+	claims, err := k.parse(token, k.issuer)
+	if errors.Is(err, ErrTokenExpired) {
+		return nil, fmt.Errorf("Flow token expired")
+	}
+	if err != nil || claims["typ"] != oauthFlowType {
+		return nil, fmt.Errorf("Invalid flow token")
+	}
+	flow := &types.OAuthFlow{}
+	flow.Provider, _ = claims["provider"].(string)
+	flow.State, _ = claims["state"].(string)
+	flow.Nonce, _ = claims["nonce"].(string)
+	flow.Verifier, _ = claims["verifier"].(string)
+	return flow, nil
 }
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// RSA keys shorter than this are rejected.
+const minRSAKeyBits = 2048
+
+// The issuer and audience of the tokens unless JWT_ISSUER and JWT_AUDIENCE are set.
+const (
+	defaultIssuer   = "go-svelte-todo"
+	defaultAudience = "go-svelte-todo-api"
+)
+
+// ErrTokenExpired is returned for tokens that are valid apart from having expired.
+var ErrTokenExpired = errors.New("Token expired")
+
+var errInvalidToken = errors.New("Invalid token")
+
+type verificationKey struct {
+	jwk    *types.JWK
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet signs the JWT tokens with its signing key and verifies them with any of
+// its keys, so that the tokens signed before a key rotation stay valid until
+// they expire. RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+type KeySet struct {
+	issuer   string
+	audience string
+
+	signingKey crypto.Signer
+	signing    *verificationKey
+	keys       map[string]*verificationKey
+	// in the order they were added, the signing key first
+	jwks []*types.JWK
+}
+
+// NewKeySet returns the key set signing with signingKey. The verification keys
+// are the public keys of earlier signing keys that are still accepted.
+func NewKeySet(issuer, audience string, signingKey crypto.Signer, verificationKeys ...crypto.PublicKey) (*KeySet, error) {
+	if issuer == "" || audience == "" {
+		return nil, fmt.Errorf("the issuer and audience of the tokens need to be set")
+	}
+	// Tokens that the API issues to itself have the issuer as their audience.
+	if issuer == audience {
+		return nil, fmt.Errorf("the issuer and audience of the tokens need to differ")
+	}
+
+	k := &KeySet{
+		issuer:     issuer,
+		audience:   audience,
+		signingKey: signingKey,
+		keys:       map[string]*verificationKey{},
+	}
+	signing, err := k.add(signingKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	k.signing = signing
+	for _, key := range verificationKeys {
+		if _, err := k.add(key); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// KeySetFromEnv reads the signing key from the PEM file JWT_SIGNING_KEY_FILE and
+// the keys of earlier signing keys from the comma separated PEM files in
+// JWT_VERIFICATION_KEY_FILES. Without a signing key the tokens are signed with a
+// temporary key, so they stop working once the API restarts.
+func KeySetFromEnv() (*KeySet, error) {
+	issuer := os.Getenv("JWT_ISSUER")
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		audience = defaultAudience
+	}
+
+	var signingKey crypto.Signer
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); path != "" {
+		_, private, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if private == nil {
+			return nil, fmt.Errorf("%s needs to contain a private key", path)
+		}
+		signingKey = private
+	} else {
+		log.Printf("JWT_SIGNING_KEY_FILE is not set, signing the tokens with a temporary key")
+		_, private, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, err
+		}
+		signingKey = private
+	}
+
+	verificationKeys := []crypto.PublicKey{}
+	for _, path := range strings.Split(os.Getenv("JWT_VERIFICATION_KEY_FILES"), ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		public, _, err := readKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verificationKeys = append(verificationKeys, public)
+	}
+
+	return NewKeySet(issuer, audience, signingKey, verificationKeys...)
+}
+
+// readKeyFile reads a PEM encoded public or private key, the private key is nil
+// for public keys.
+func readKeyFile(path string) (crypto.PublicKey, crypto.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, nil, fmt.Errorf("%s contains no PEM encoded key", path)
+	}
+
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("%s contains an unsupported %s", path, block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if private, ok := key.(crypto.Signer); ok {
+		return private.Public(), private, nil
+	}
+	return key, nil, nil
+}
+
+func (k *KeySet) add(public crypto.PublicKey) (*verificationKey, error) {
+	var method jwt.SigningMethod
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		if public.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys need at least %d bits", minRSAKeyBits)
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected an RSA or Ed25519 key", public)
+	}
+
+	jwk, err := types.NewJWK(public, method.Alg())
+	if err != nil {
+		return nil, err
+	}
+	key := &verificationKey{jwk: jwk, method: method, public: public}
+	if _, ok := k.keys[jwk.Kid]; !ok {
+		k.keys[jwk.Kid] = key
+		k.jwks = append(k.jwks, jwk)
+	}
+	return key, nil
+}
+
+// JWKS returns the public keys that the tokens are verified with.
+func (k *KeySet) JWKS() *types.JWKSResponse {
+	return &types.JWKSResponse{Keys: k.jwks}
+}
+
+// sign adds the issuer, audience and lifetime to the claims and signs them with
+// the signing key, naming it in the "kid" header.
+func (k *KeySet) sign(claims jwt.MapClaims, audience string, ttl time.Duration) (jwt.MapClaims, string, error) {
+	now := time.Now()
+	claims["iss"] = k.issuer
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(ttl).Unix()
+
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.jwk.Kid
+	tok, err := token.SignedString(k.signingKey)
+
+	return claims, tok, err
+}
+
+// parse verifies the token with the key named in its header and returns its
+// claims, ErrTokenExpired if it has expired.
+func (k *KeySet) parse(token, audience string) (jwt.MapClaims, error) {
+	tok, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		// The key decides the algorithm, a token can't pick another one.
+		if !ok || t.Method.Alg() != key.method.Alg() {
+			return nil, errInvalidToken
+		}
+		return key.public, nil
+	})
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		// The signature has been verified if the expiry is the only error.
+		if errors.As(err, &validationErr) && validationErr.Errors == jwt.ValidationErrorExpired {
+			return nil, ErrTokenExpired
+		}
+		return nil, errInvalidToken
+	}
+
+	claims, ok := tok.Claims.(jwt.MapClaims)
+	if !ok || !tok.Valid || !claims.VerifyIssuer(k.issuer, true) || !claims.VerifyAudience(audience, true) ||
+		!claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errInvalidToken
+	}
+	return claims, nil
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+// PermissionMiddleware rejects the requests of users whose role lacks the
+// permission, it needs to run after the JWTMiddleware.
+type PermissionMiddleware struct {
+	permission types.Permission
+}
+
+func NewPermissionMiddleware(permission types.Permission) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		permission: permission,
+	}
+}
+
+func (m *PermissionMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*types.User)
+		if !ok {
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("unknown user"), http.StatusUnauthorized))
+			return
+		}
+		if !user.Can(m.permission) {
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("the %s permission is required", m.permission), http.StatusForbidden))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ProxyMiddleware replaces the address of requests made by a trusted proxy,
+// like the frontend, with the client address the proxy forwarded in
+// X-Forwarded-For. Anybody can send the header, so it's ignored unless the
+// request comes from one of the trusted proxies.
+type ProxyMiddleware struct {
+	trusted []*net.IPNet
+}
+
+func NewProxyMiddleware(trusted []*net.IPNet) *ProxyMiddleware {
+	return &ProxyMiddleware{
+		trusted: trusted,
+	}
+}
+
+// ParseTrustedProxies parses comma separated IP addresses and CIDR ranges,
+// an empty string trusts no proxy.
+func ParseTrustedProxies(proxies string) ([]*net.IPNet, error) {
+	trusted := []*net.IPNet{}
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			trusted = append(trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+func (m *ProxyMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if client := m.forwardedFor(r); client != "" {
+			r.RemoteAddr = client
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedFor returns the client address of a request made by a trusted
+// proxy, or an empty string. Proxies append the address they received the
+// request from, so the header is read from the right and the first address
+// that isn't a trusted proxy is the client.
+func (m *ProxyMiddleware) forwardedFor(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !m.isTrusted(net.ParseIP(host)) {
+		return ""
+	}
+
+	addresses := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := ""
+	for i := len(addresses) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(addresses[i]))
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !m.isTrusted(ip) {
+			break
+		}
+	}
+	return client
+}
+
+func (m *ProxyMiddleware) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range m.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+// VerifiedMiddleware applies the policy for users that haven't verified their
+// email address, it needs to run after the JWTMiddleware.
+type VerifiedMiddleware struct {
+	policy types.UnverifiedPolicy
+}
+
+func NewVerifiedMiddleware(policy types.UnverifiedPolicy) *VerifiedMiddleware {
+	return &VerifiedMiddleware{
+		policy: policy,
+	}
+}
+
+func (m *VerifiedMiddleware) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*types.User)
+		if !ok || user.Verified {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch {
+		case m.policy == types.UnverifiedBlock:
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("the email address needs to be verified"), http.StatusForbidden))
+		case m.policy == types.UnverifiedReadOnly && !types.IsReadMethod(r.Method):
+			utils.WriteJSON(w, types.NewAPIError(false, fmt.Errorf("the email address needs to be verified before making changes"), http.StatusForbidden))
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
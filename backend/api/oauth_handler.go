@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/oidc"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type OAuthHandler struct {
+	providers        map[string]*oidc.Provider
+	store            store.UserStorer
+	identityStore    store.IdentityStorer
+	accessTokenStore store.AccessTokenStorer
+	auth             *AuthHandler
+}
+
+func NewOAuthHandler(providers map[string]*oidc.Provider, store store.UserStorer, identityStore store.IdentityStorer, accessTokenStore store.AccessTokenStorer, auth *AuthHandler) *OAuthHandler {
+	return &OAuthHandler{
+		providers:        providers,
+		store:            store,
+		identityStore:    identityStore,
+		accessTokenStore: accessTokenStore,
+		auth:             auth,
+	}
+}
+
+// @Summary		Get the login providers.
+// @Description	lists the OpenID Connect providers that users can log in with.
+// @Tags		auth
+// @Produce		json
+// @Success		200	{object}	types.OAuthProvidersResponse
+// @Router		/api/oauth/providers [get]
+func (h *OAuthHandler) HandleGetProviders(w http.ResponseWriter, r *http.Request) *types.APIError {
+	names := []string{}
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return utils.ResponseWriteJSON(w, types.OAuthProvidersResponse{Providers: names})
+}
+
+// @Summary		Start a login with a provider.
+// @Description	returns the URL of the provider to send the user to and a flow token that is valid for 10 minutes.
+// @Description	The provider redirects back to the frontend, which passes the code, the state and the flow token to the callback.
+// @Tags		auth
+// @Param		provider	path	string	true	"The name of the provider"
+// @Produce		json
+// @Success		200	{object}	types.OAuthStartResponse
+// @Failure		404	{object}	types.APIError
+// @Failure		502	{object}	types.APIError
+// @Router		/api/oauth/{provider}/start [get]
+func (h *OAuthHandler) HandleOAuthStart(w http.ResponseWriter, r *http.Request) *types.APIError {
+	provider, apiErr := h.provider(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	flow, err := types.NewOAuthFlow(provider.Name())
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	authorizationURL, err := provider.AuthCodeURL(r.Context(), flow.State, flow.Nonce, oidc.PKCEChallenge(flow.Verifier))
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadGateway)
+	}
+	claims, token, err := h.auth.keys.CreateOAuthFlow(flow)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.OAuthStartResponse{
+		AuthorizationURL: authorizationURL,
+		FlowToken:        token,
+		ExpiresAt:        claims["exp"].(int64),
+	})
+}
+
+// @Summary		Complete a login with a provider.
+// @Description	exchanges the code for the ID token of the user and logs in the user linked to the identity.
+// @Description	Unknown identities are linked to the user with their verified email address, a new user is registered if there is none.
+// @Tags		auth
+// @Accept		json
+// @Param		provider	path	string	true	"The name of the provider"
+// @Param		params	body	types.OAuthCallbackParams	true	"The code and state of the redirect and the flow token"
+// @Produce		json
+// @Success		200	{object}	types.LoginResponse
+// @Success		200	{object}	types.MFAChallengeResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		401	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/oauth/{provider}/callback [post]
+func (h *OAuthHandler) HandleOAuthCallback(w http.ResponseWriter, r *http.Request) *types.APIError {
+	provider, apiErr := h.provider(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var params types.OAuthCallbackParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	// The state ties the redirect to the flow that the client started.
+	flow, err := h.auth.keys.ValidateOAuthFlow(params.FlowToken)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusUnauthorized)
+	}
+	if flow.Provider != provider.Name() || subtle.ConstantTimeCompare([]byte(flow.State), []byte(params.State)) != 1 {
+		return types.NewAPIError(false, fmt.Errorf("Invalid state"), http.StatusUnauthorized)
+	}
+
+	claims, err := provider.Exchange(r.Context(), params.Code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusUnauthorized)
+	}
+
+	user, apiErr := h.linkedUser(r.Context(), provider.Name(), claims)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	return h.auth.authenticated(w, r, user)
+}
+
+// linkedUser returns the user linked to the identity, linking it to the user with
+// the same email address or a new user if it is unknown. Only email addresses
+// that the provider has verified are trusted. An unverified user loses its
+// credentials before it is linked, see resetCredentials.
+func (h *OAuthHandler) linkedUser(ctx context.Context, provider string, claims *oidc.Claims) (*types.User, *types.APIError) {
+	if identity, err := h.identityStore.GetIdentity(ctx, provider, claims.Subject); err == nil {
+		user, err := h.store.GetUserByID(ctx, int64(identity.UserID))
+		if err != nil {
+			return nil, types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		return user, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, types.NewAPIError(false, fmt.Errorf("the provider needs to share a verified email address"), http.StatusForbidden)
+	}
+
+	user, err := h.store.GetUserByEmail(ctx, claims.Email)
+	if err == nil && !user.Verified {
+		if apiErr := h.resetCredentials(ctx, user); apiErr != nil {
+			return nil, apiErr
+		}
+		// The provider has verified the email address just like the link would.
+		if err := h.store.SetUserVerifiedByID(ctx, int64(user.ID)); err != nil {
+			return nil, types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		user.Verified = true
+	}
+	if err != nil {
+		newUser, err := types.NewExternalUser(claims.Email)
+		if err != nil {
+			return nil, types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		var apiErr *types.APIError
+		if user, apiErr = h.auth.createUser(ctx, newUser); apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	if _, err := h.identityStore.InsertIdentity(ctx, types.NewIdentity(user.ID, provider, claims.Subject, claims.Email)); err != nil {
+		return nil, types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return user, nil
+}
+
+// resetCredentials replaces the password of the user with a random one and revokes
+// its sessions, access tokens and two-factor authentication. Anybody could have
+// registered an unverified user with the email address before its owner, who
+// needs a reset link to log in with a password afterwards.
+func (h *OAuthHandler) resetCredentials(ctx context.Context, user *types.User) *types.APIError {
+	password, err := types.NewRandomPassword()
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.store.UpdateUserPasswordByID(ctx, password, int64(user.ID)); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.auth.sessionStore.RevokeUserSessions(ctx, user.ID, 0); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	tokens, err := h.accessTokenStore.GetAccessTokens(ctx, user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	for _, token := range tokens {
+		if err := h.accessTokenStore.DeleteAccessToken(ctx, token.ID, user.ID); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+	}
+	if err := h.auth.mfaStore.DisableMFA(ctx, user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return nil
+}
+
+// provider returns the provider named in the URL.
+func (h *OAuthHandler) provider(r *http.Request) (*oidc.Provider, *types.APIError) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := h.providers[name]
+	if !ok {
+		return nil, types.NewAPIError(false, fmt.Errorf("unknown provider: %s", name), http.StatusNotFound)
+	}
+	return provider, nil
+}
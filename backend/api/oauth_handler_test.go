@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/oidc"
+	"github.com/thimc/go-svelte-todo/backend/oidc/oidctest"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+func TestOAuthLogin(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	server := oidctest.NewServer("todo", "client-secret")
+	defer server.Close()
+	providers := map[string]*oidc.Provider{
+		"company": oidc.NewProvider(server.Config("company", "http://localhost:5173/oauth/company/callback"), nil),
+	}
+	handler := NewOAuthHandler(providers, testSuite.userStore, testSuite.identityStore, testSuite.accessTokenStore, testSuite.authHandler)
+
+	r := testSuite.router()
+	public := mux.NewRouter()
+	public.HandleFunc("/oauth/providers", utils.HandleAPIFunc(handler.HandleGetProviders)).Methods(http.MethodGet)
+	public.HandleFunc("/oauth/{provider}/start", utils.HandleAPIFunc(handler.HandleOAuthStart)).Methods(http.MethodGet)
+	public.HandleFunc("/oauth/{provider}/callback", utils.HandleAPIFunc(handler.HandleOAuthCallback)).Methods(http.MethodPost)
+
+	start := func(t *testing.T) types.OAuthStartResponse {
+		t.Helper()
+		rr := do(t, public, http.MethodGet, "/oauth/company/start", nil, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.OAuthStartResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	// login logs in as the identity at the provider and passes the redirect to the callback.
+	login := func(t *testing.T, identity oidctest.Identity, status int) *httptest.ResponseRecorder {
+		t.Helper()
+		flow := start(t)
+		server.SetIdentity(identity)
+		code, state, err := server.Authorize(flow.AuthorizationURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := do(t, public, http.MethodPost, "/oauth/company/callback", types.OAuthCallbackParams{Code: code, State: state, FlowToken: flow.FlowToken}, "")
+		if rr.Code != status {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", status, rr.Code, rr.Body.String())
+		}
+		return rr
+	}
+	loginResponse := func(t *testing.T, identity oidctest.Identity) types.LoginResponse {
+		t.Helper()
+		var resp types.LoginResponse
+		if err := json.NewDecoder(login(t, identity, http.StatusOK).Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Token == "" || resp.RefreshToken == "" {
+			t.Fatalf("expected a login response, got %+v", resp)
+		}
+		return resp
+	}
+	newIdentity := func(verified bool) oidctest.Identity {
+		id := rand.Intn(1000000)
+		return oidctest.Identity{Subject: fmt.Sprint("subject-", id), Email: fmt.Sprintf("oauth%d@golangtest.com", id), EmailVerified: verified}
+	}
+
+	t.Run("Providers", func(t *testing.T) {
+		rr := do(t, public, http.MethodGet, "/oauth/providers", nil, "")
+		var resp types.OAuthProvidersResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Providers) != 1 || resp.Providers[0] != "company" {
+			t.Errorf("expected the company provider, got %v", resp.Providers)
+		}
+		if rr := do(t, public, http.MethodGet, "/oauth/unknown/start", nil, ""); rr.Code != http.StatusNotFound {
+			t.Errorf("expected http status code %v got %v", http.StatusNotFound, rr.Code)
+		}
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		identity := newIdentity(true)
+		first := loginResponse(t, identity)
+		t.Cleanup(func() {
+			testSuite.userStore.DeleteUserByID(context.TODO(), int64(first.ID))
+		})
+		if first.Email != identity.Email {
+			t.Errorf("expected a user with the email %s, got %s", identity.Email, first.Email)
+		}
+		if rr := do(t, r, http.MethodGet, "/lists", nil, first.Token); rr.Code != http.StatusOK {
+			t.Errorf("expected the token to work like a password login, got %v (resp: %s)", rr.Code, rr.Body.String())
+		}
+		lists, err := testSuite.listStore.GetLists(context.TODO(), first.ID, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lists) != 1 || !lists[0].Inbox {
+			t.Errorf("expected the new user to have an inbox, got %+v", lists)
+		}
+
+		// The identity stays linked when the email address changes at the provider.
+		identity.Email = "changed-" + identity.Email
+		if second := loginResponse(t, identity); second.ID != first.ID {
+			t.Errorf("expected the linked user %d, got %d", first.ID, second.ID)
+		}
+	})
+
+	t.Run("Link", func(t *testing.T) {
+		user, _ := testSuite.createUser(t)
+		identity := newIdentity(true)
+		identity.Email = user.Email
+		if resp := loginResponse(t, identity); resp.ID != user.ID {
+			t.Errorf("expected the user %d with the same email address, got %d", user.ID, resp.ID)
+		}
+		linked, err := testSuite.userStore.GetUserByID(context.TODO(), int64(user.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !linked.Verified {
+			t.Errorf("expected the email address verified by the provider to be verified")
+		}
+	})
+
+	t.Run("Unverified account", func(t *testing.T) {
+		// Somebody registers the email address of the owner with a password, a
+		// personal access token and two-factor authentication before the owner
+		// logs in with single sign-on for the first time.
+		squatter, token := testSuite.createUser(t)
+		rr := do(t, r, http.MethodPost, "/user/tokens", types.AccessTokenParams{Name: "CI", Scopes: []string{types.ScopeWrite}}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var pat types.AccessTokenCreatedResponse
+		if err := json.NewDecoder(rr.Body).Decode(&pat); err != nil {
+			t.Fatal(err)
+		}
+		if err := testSuite.mfaStore.SetMFASecret(context.TODO(), squatter.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+			t.Fatal(err)
+		}
+		if err := testSuite.mfaStore.EnableMFA(context.TODO(), squatter.ID, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		identity := newIdentity(true)
+		identity.Email = squatter.Email
+		if resp := loginResponse(t, identity); resp.ID != squatter.ID {
+			t.Errorf("expected the user %d with the same email address, got %d", squatter.ID, resp.ID)
+		}
+
+		linked, err := testSuite.userStore.GetUserByID(context.TODO(), int64(squatter.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if types.ValidPassword(linked.EncryptedPassword, "secret-password") == nil {
+			t.Errorf("expected the password of the unverified account to be replaced")
+		}
+		for _, token := range []string{token, pat.Token} {
+			if rr := do(t, r, http.MethodGet, "/lists", nil, token); rr.Code == http.StatusOK {
+				t.Errorf("expected the session and access token of the unverified account to be revoked")
+			}
+		}
+		mfa, err := testSuite.mfaStore.GetMFA(context.TODO(), squatter.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mfa.Enabled {
+			t.Errorf("expected the two-factor authentication of the unverified account to be disabled")
+		}
+	})
+
+	t.Run("Unverified email", func(t *testing.T) {
+		user, _ := testSuite.createUser(t)
+		identity := newIdentity(false)
+		identity.Email = user.Email
+		login(t, identity, http.StatusForbidden)
+	})
+
+	t.Run("State", func(t *testing.T) {
+		flow := start(t)
+		server.SetIdentity(newIdentity(true))
+		code, state, err := server.Authorize(flow.AuthorizationURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A redirect can't be completed with the flow token of another client.
+		other := start(t)
+		tests := []types.OAuthCallbackParams{
+			{Code: code, State: "another-state", FlowToken: flow.FlowToken},
+			{Code: code, State: state, FlowToken: other.FlowToken},
+			{Code: code, State: state, FlowToken: "invalid"},
+		}
+		for _, params := range tests {
+			if rr := do(t, public, http.MethodPost, "/oauth/company/callback", params, ""); rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected http status code %v got %v (resp: %s)", http.StatusUnauthorized, rr.Code, rr.Body.String())
+			}
+		}
+		// The flow token is no access token.
+		if rr := do(t, r, http.MethodGet, "/lists", nil, flow.FlowToken); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected http status code %v got %v", http.StatusBadRequest, rr.Code)
+		}
+	})
+}
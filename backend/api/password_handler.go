@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/thimc/go-svelte-todo/backend/mail"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+const passwordResetMail = `Someone asked to reset the password of your account.
+
+Open the link below within %d minutes to choose a new password:
+
+%s
+
+If it wasn't you, you can ignore this email and your password stays the same.
+`
+
+const adminPasswordResetMail = `An administrator has reset the password of your account.
+
+Open the link below within %d minutes to choose a new password:
+
+%s
+`
+
+type PasswordHandler struct {
+	store        store.UserStorer
+	tokenStore   store.TokenStorer
+	sessionStore store.SessionStorer
+	mailer       mail.Mailer
+	// The URL of the frontend that the reset links point to
+	appURL string
+	// the password reset mails being sent in the background
+	mails *mailQueue
+}
+
+func NewPasswordHandler(store store.UserStorer, tokenStore store.TokenStorer, sessionStore store.SessionStorer, mailer mail.Mailer, appURL string) *PasswordHandler {
+	return &PasswordHandler{
+		store:        store,
+		tokenStore:   tokenStore,
+		sessionStore: sessionStore,
+		mailer:       mailer,
+		appURL:       strings.TrimSuffix(appURL, "/"),
+		mails:        newMailQueue(mailQueueSize),
+	}
+}
+
+// Wait blocks until the password reset mails being sent in the background are done.
+func (h *PasswordHandler) Wait() {
+	h.mails.wait()
+}
+
+// @Summary		Forgot the password.
+// @Description	sends a password reset link to the email address if it belongs to an account, the link is valid for an hour.
+// @Description	The response is the same whether the account exists or not. Asking again within 5 minutes sends no new link.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.ForgotPasswordParams	true	"The email address"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Router		/api/password/forgot [post]
+func (h *PasswordHandler) HandleForgotPassword(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.ForgotPasswordParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	// The account is looked up in the background, so the response takes as
+	// long whether the account exists or not.
+	email := params.Email
+	if !h.mails.run(func(ctx context.Context) { h.forgotPassword(ctx, email) }) {
+		log.Printf("too many password reset mails are being sent, dropping the one to %s", email)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("a reset link has been sent if the email belongs to an account"), http.StatusOK))
+}
+
+// forgotPassword sends a reset link if the email belongs to an account and no link
+// was sent within the last types.PasswordResetMailInterval. The request is gone by then.
+func (h *PasswordHandler) forgotPassword(ctx context.Context, email string) {
+	user, err := h.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+	ok, err := h.store.MarkPasswordResetSent(ctx, int64(user.ID), types.PasswordResetMailInterval)
+	if err != nil {
+		log.Printf("error when sending the password reset mail: %s", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	if err := h.sendResetLink(ctx, user, passwordResetMail); err != nil {
+		log.Printf("error when sending the password reset mail: %s", err)
+	}
+}
+
+// sendResetLink mails a new password reset link to the user, the body is formatted
+// with the minutes the link is valid for and the link.
+func (h *PasswordHandler) sendResetLink(ctx context.Context, user *types.User, body string) error {
+	resetToken, token, err := types.NewPasswordResetToken(user.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := h.tokenStore.CreatePasswordResetToken(ctx, resetToken); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf(body, int(types.PasswordResetTokenTTL.Minutes()), link),
+	})
+}
+
+// @Summary		Reset the password.
+// @Description	sets a new password with the token from a reset link, every session of the user is signed out.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.ResetPasswordParams	true	"The token and the new password"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Router		/api/password/reset [post]
+func (h *PasswordHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.ResetPasswordParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	invalid := types.NewAPIError(false, fmt.Errorf("the reset link is invalid or has expired"), http.StatusBadRequest)
+
+	resetToken, err := h.tokenStore.GetPasswordResetTokenByHash(r.Context(), types.HashToken(params.Token))
+	if err != nil {
+		return invalid
+	}
+	user, err := h.store.GetUserByID(r.Context(), int64(resetToken.UserID))
+	if err != nil {
+		return invalid
+	}
+	newUser, err := types.NewUser(user.Email, params.Password)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	used, err := h.tokenStore.UsePasswordResetToken(r.Context(), resetToken.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if !used {
+		return invalid
+	}
+
+	if err := h.store.UpdateUserPasswordByID(r.Context(), newUser.EncryptedPassword, int64(user.ID)); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.sessionStore.RevokeUserSessions(r.Context(), user.ID, 0); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("the password has been reset"), http.StatusOK))
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+var resetLinkRegex = regexp.MustCompile(`/reset-password\?token=([A-Za-z0-9_\-]+)`)
+
+// resetTokens returns the tokens of the reset links that were mailed so far.
+func (s *testSuite) resetTokens(t *testing.T) []string {
+	b, err := os.ReadFile(s.mailFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := []string{}
+	for _, match := range resetLinkRegex.FindAllStringSubmatch(string(b), -1) {
+		tokens = append(tokens, match[1])
+	}
+	return tokens
+}
+
+func TestPasswordReset(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	public := mux.NewRouter()
+	public.HandleFunc("/login", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
+	public.HandleFunc("/password/forgot", utils.HandleAPIFunc(testSuite.passwordHandler.HandleForgotPassword)).Methods(http.MethodPost)
+	public.HandleFunc("/password/reset", utils.HandleAPIFunc(testSuite.passwordHandler.HandleResetPassword)).Methods(http.MethodPost)
+
+	user, token := testSuite.createUser(t)
+
+	forgot := func(email string) {
+		rr := do(t, public, http.MethodPost, "/password/forgot", types.ForgotPasswordParams{Email: email}, "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		testSuite.passwordHandler.Wait()
+	}
+	reset := func(resetToken, password string, status int) {
+		rr := do(t, public, http.MethodPost, "/password/reset", types.ResetPasswordParams{Token: resetToken, Password: password}, "")
+		if rr.Code != status {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", status, rr.Code, rr.Body.String())
+		}
+	}
+	login := func(password string) int {
+		return do(t, public, http.MethodPost, "/login", types.UserParams{Email: user.Email, Password: password}, "").Code
+	}
+
+	// Unknown accounts get the same response and no mail.
+	forgot("unknown@golangtest.com")
+	if tokens := testSuite.resetTokens(t); len(tokens) != 0 {
+		t.Fatalf("expected no reset mail, got %v", tokens)
+	}
+
+	// Asking again right away sends no second mail.
+	forgot(user.Email)
+	forgot(user.Email)
+	if tokens := testSuite.resetTokens(t); len(tokens) != 1 {
+		t.Fatalf("expected one reset mail, got %v", tokens)
+	}
+	// A second link, like the one an admin can send.
+	if err := testSuite.passwordHandler.sendResetLink(context.TODO(), user, passwordResetMail); err != nil {
+		t.Fatal(err)
+	}
+	tokens := testSuite.resetTokens(t)
+	if len(tokens) != 2 {
+		t.Fatalf("expected two reset mails, got %v", tokens)
+	}
+
+	reset("unknown", "new-password", http.StatusBadRequest)
+	reset(tokens[0], "pw", http.StatusBadRequest)
+	reset(tokens[0], "new-password", http.StatusOK)
+
+	if code := login("secret-password"); code != http.StatusUnauthorized {
+		t.Errorf("expected the old password to be rejected, got %v", code)
+	}
+	if code := login("new-password"); code != http.StatusOK {
+		t.Errorf("expected the new password to be accepted, got %v", code)
+	}
+	if rr := do(t, r, http.MethodGet, "/todos", nil, token); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected the sessions to be signed out, got %v", rr.Code)
+	}
+
+	// Both the used token and the other link of the user are spent.
+	reset(tokens[0], "another-password", http.StatusBadRequest)
+	reset(tokens[1], "another-password", http.StatusBadRequest)
+
+	t.Run("Expired", func(t *testing.T) {
+		resetToken, expired, err := types.NewPasswordResetToken(user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resetToken.ExpiresAt = time.Now().Add(-time.Minute)
+		if _, err := testSuite.tokenStore.CreatePasswordResetToken(context.TODO(), resetToken); err != nil {
+			t.Fatal(err)
+		}
+		reset(expired, "another-password", http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type RevisionHandler struct {
+	store     store.TodoRevisionStorer
+	todoStore store.TodoStorer
+}
+
+func NewRevisionHandler(revisionStore store.TodoRevisionStorer, todoStore store.TodoStorer) *RevisionHandler {
+	return &RevisionHandler{
+		store:     revisionStore,
+		todoStore: todoStore,
+	}
+}
+
+// @Summary		Get the history of a todo.
+// @Description	lists the revisions of a todo, newest first. Every revision has the user that made the change and the old and new values of the fields that changed.
+// @Tags		todos
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Produce		json
+// @Success		200	{object}	types.TodoHistoryResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/history [get]
+// @Security	ApiKeyAuth
+func (h *RevisionHandler) HandleGetHistory(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	revisions, err := h.store.GetTodoRevisions(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.TodoHistoryResponse{Count: len(revisions), Result: revisions})
+}
+
+// @Summary		Revert a todo.
+// @Description	restores the fields of a todo to their values right after the revision by undoing every later revision, and returns the todo.
+// @Description	The revert is recorded as a new revision. It fails if a list, tag or parent todo it would restore is gone.
+// @Tags		todos
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Param		revisionId	path	int	true	"Revision ID"
+// @Produce		json
+// @Success		200	{object}	types.Todo
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Failure		409	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/history/{revisionId}/revert [post]
+// @Security	ApiKeyAuth
+func (h *RevisionHandler) HandleRevertTodo(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	revisionID, err := strconv.ParseInt(mux.Vars(r)["revisionId"], 10, 64)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	todo, err := h.todoStore.GetTodoByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	revisions, err := h.store.GetTodoRevisions(r.Context(), todo.ID, user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	// The revisions are sorted newest first, those before the wanted one are undone.
+	since := -1
+	for i, revision := range revisions {
+		if revision.ID == revisionID {
+			since = i
+			break
+		}
+	}
+	if since == -1 {
+		return types.NewAPIError(false, fmt.Errorf("unknown revision ID: %d", revisionID), http.StatusNotFound)
+	}
+
+	params, err := types.RevertTodoParams(todo, revisions[:since])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	now := time.Now().UTC()
+	params.Updated = &now
+	params.UpdatedBy = &user.ID
+	if err := h.todoStore.UpdateTodoByID(r.Context(), params, todo.ID, user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusConflict)
+	}
+
+	if todo, err = h.todoStore.GetTodoByID(r.Context(), todo.ID, user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, todo)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestTodoHistory(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	rr := do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Write report", Content: "First draft"}, token)
+	var todo types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	target := fmt.Sprintf("/todos/%d", todo.ID)
+
+	history := func() []*types.TodoRevision {
+		t.Helper()
+		rr := do(t, r, http.MethodGet, target+"/history", nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.TodoHistoryResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Result
+	}
+	patch := func(params types.UpdateTodoParams) *types.Todo {
+		t.Helper()
+		rr := do(t, r, http.MethodPatch, target, params, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		return &todo
+	}
+
+	revisions := history()
+	if len(revisions) != 1 || revisions[0].ChangedBy != user.ID || len(revisions[0].Changes) == 0 {
+		t.Fatalf("expected the revision that created the todo, got %+v", revisions)
+	}
+	created := revisions[0]
+
+	title, done := "Write the report", true
+	patch(types.UpdateTodoParams{Title: &title})
+	patched := patch(types.UpdateTodoParams{Done: &done})
+	if patched.UpdatedBy == nil || *patched.UpdatedBy != int64(user.ID) {
+		t.Errorf("expected the patch to set the editor, got %v", patched.UpdatedBy)
+	}
+
+	revisions = history()
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(revisions))
+	}
+	change := revisions[1].Changes
+	if len(change) != 1 || change[0].Field != "title" || string(change[0].Old) != `"Write report"` || string(change[0].New) != `"Write the report"` {
+		t.Errorf("expected the title change, got %+v", change)
+	}
+
+	t.Run("Revert", func(t *testing.T) {
+		rr := do(t, r, http.MethodPost, fmt.Sprintf("%s/history/%d/revert", target, created.ID), nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var reverted types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&reverted); err != nil {
+			t.Fatal(err)
+		}
+		if reverted.Title != "Write report" || reverted.Done || reverted.Content != "First draft" {
+			t.Errorf("expected the todo as it was created, got %+v", reverted)
+		}
+
+		revisions := history()
+		if len(revisions) != 4 || len(revisions[0].Changes) != 2 {
+			t.Errorf("expected the revert to be recorded with 2 changes, got %+v", revisions[0])
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if rr := do(t, r, http.MethodGet, target+"/history", nil, otherToken); rr.Code != http.StatusNotFound {
+			t.Errorf("expected http status code %v for the todo of another user, got %v", http.StatusNotFound, rr.Code)
+		}
+		rr := do(t, r, http.MethodPost, fmt.Sprintf("%s/history/%d/revert", target, created.ID), nil, otherToken)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected http status code %v for the todo of another user, got %v", http.StatusNotFound, rr.Code)
+		}
+		rr = do(t, r, http.MethodPost, target+"/history/999999/revert", nil, token)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected http status code %v for an unknown revision, got %v", http.StatusNotFound, rr.Code)
+		}
+	})
+}
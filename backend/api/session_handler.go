@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type SessionHandler struct {
+	store store.SessionStorer
+}
+
+func NewSessionHandler(sessionStore store.SessionStorer) *SessionHandler {
+	return &SessionHandler{
+		store: sessionStore,
+	}
+}
+
+// @Summary		Get all sessions.
+// @Description	fetch every session of the authenticated user that hasn't been signed out, most recently seen first.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.SessionGetAllResponse
+// @Failure		403	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/user/sessions [get]
+// @Security	ApiKeyAuth
+func (h *SessionHandler) HandleGetSessions(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	sessions, err := h.store.GetSessions(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	current := contextSession(r)
+	for _, session := range sessions {
+		session.Current = session.ID == current
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewSessionGetAllResponse(sessions))
+}
+
+// @Summary		Sign out a session.
+// @Description	signs out a session, its tokens stop working right away.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Session ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/user/sessions/{id} [delete]
+// @Security	ApiKeyAuth
+func (h *SessionHandler) HandleDeleteSession(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.RevokeSession(r.Context(), int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("session ID: %d", id), http.StatusOK))
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+func TestSessions(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	user, _ := testSuite.createUser(t)
+
+	login := func(userAgent string) types.LoginResponse {
+		body, err := json.Marshal(types.UserParams{Email: user.Email, Password: "secret-password"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+		req.Header.Add("User-Agent", userAgent)
+		req.Header.Add("X-Forwarded-For", "198.51.100.1, 192.0.2.1")
+		rr := httptest.NewRecorder()
+		testSuite.proxy.Middleware(utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.LoginResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	getSessions := func(token string) []*types.Session {
+		rr := do(t, r, http.MethodGet, "/user/sessions", nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.SessionGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Result
+	}
+
+	laptop := login("laptop")
+	phone := login("phone")
+
+	var phoneSession *types.Session
+	for _, session := range getSessions(laptop.Token) {
+		if session.UserAgent == "phone" {
+			phoneSession = session
+		}
+		if session.Current != (session.UserAgent == "laptop") {
+			t.Errorf("expected only the laptop session to be current, got %+v", session)
+		}
+	}
+	if phoneSession == nil || phoneSession.IP != "198.51.100.1" {
+		t.Fatalf("expected a phone session from 198.51.100.1, got %+v", phoneSession)
+	}
+
+	rr := do(t, r, http.MethodDelete, fmt.Sprintf("/user/sessions/%d", phoneSession.ID), nil, laptop.Token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// The signed out session can neither use its token nor refresh it.
+	if rr := do(t, r, http.MethodGet, "/user/sessions", nil, phone.Token); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected http status code %v got %v", http.StatusUnauthorized, rr.Code)
+	}
+	refresh := utils.HandleAPIFunc(testSuite.authHandler.HandleRefreshToken)
+	rr = do(t, http.HandlerFunc(refresh), http.MethodPost, "/", types.RefreshTokenParams{RefreshToken: phone.RefreshToken}, "")
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected http status code %v got %v", http.StatusUnauthorized, rr.Code)
+	}
+
+	for _, session := range getSessions(laptop.Token) {
+		if session.ID == phoneSession.ID {
+			t.Errorf("expected the phone session to be signed out, got %+v", session)
+		}
+	}
+	if rr := do(t, r, http.MethodDelete, fmt.Sprintf("/user/sessions/%d", phoneSession.ID), nil, laptop.Token); rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v got %v", http.StatusNotFound, rr.Code)
+	}
+
+	// Other users can't sign out the session.
+	_, otherToken := testSuite.createUser(t)
+	current := getSessions(laptop.Token)[0]
+	if rr := do(t, r, http.MethodDelete, fmt.Sprintf("/user/sessions/%d", current.ID), nil, otherToken); rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v got %v", http.StatusNotFound, rr.Code)
+	}
+}
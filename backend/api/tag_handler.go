@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type TagHandler struct {
+	store store.TagStorer
+}
+
+func NewTagHandler(tagStore store.TagStorer) *TagHandler {
+	return &TagHandler{
+		store: tagStore,
+	}
+}
+
+// @Summary		Get all tags.
+// @Description	fetch every tag of the authenticated user.
+// @Tags		tags
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.TagGetAllResponse
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/tags [get]
+// @Security	ApiKeyAuth
+func (h *TagHandler) HandleGetTags(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	tags, err := h.store.GetTags(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewTagGetAllResponse(tags))
+}
+
+// @Summary		Get a tag by the ID.
+// @Description	fetch one tag.
+// @Tags		tags
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Tag ID"
+// @Produce		json
+// @Success		200	{object}	types.Tag
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/tags/{id} [get]
+// @Security	ApiKeyAuth
+func (h *TagHandler) HandleGetTagByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	tag, err := h.store.GetTagByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, tag)
+}
+
+// @Summary		Create a tag.
+// @Description	create a tag, the name needs to be unique per user.
+// @Tags		tags
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.TagParams	true	"Tag metadata"
+// @Produce		json
+// @Success		200	{object}	types.Tag
+// @Failure		400	{object}	types.APIError
+// @Router		/api/v1/tags [post]
+// @Security	ApiKeyAuth
+func (h *TagHandler) HandleInsertTag(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	var params types.TagParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	tag := types.NewTagFromParams(params, user.ID)
+	if err := tag.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	insertedTag, err := h.store.InsertTag(r.Context(), tag)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	return utils.ResponseWriteJSON(w, insertedTag)
+}
+
+// @Summary		Patch a tag.
+// @Description	renames or recolors a tag and returns the updated tag. Renaming it to the name of another tag of the user fails with 400.
+// @Tags		tags
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Tag ID"
+// @Param		params	body	types.UpdateTagParams	true	"New tag data"
+// @Produce		json
+// @Success		200	{object}	types.Tag
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/tags/{id} [patch]
+// @Security	ApiKeyAuth
+func (h *TagHandler) HandlePatchTagByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	var params types.UpdateTagParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	tag, err := h.store.PatchTagByID(r.Context(), int64(id), user.ID, params)
+	if errors.Is(err, store.ErrTagExists) {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, tag)
+}
+
+// @Summary		Delete a tag.
+// @Description	deletes a tag and removes it from every todo.
+// @Tags		tags
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Tag ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/tags/{id} [delete]
+// @Security	ApiKeyAuth
+func (h *TagHandler) HandleDeleteTagByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.DeleteTagByID(r.Context(), int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("tag ID: %d", id), http.StatusOK))
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestTagCRUD(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	rr := do(t, r, http.MethodPost, "/tags", types.TagParams{Name: "work"}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var tag types.Tag
+	if err := json.NewDecoder(rr.Body).Decode(&tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag.ID == 0 || tag.Name != "work" || tag.Color != types.DefaultTagColor {
+		t.Fatalf("expected a new tag with the default color, got %+v", tag)
+	}
+
+	rr = do(t, r, http.MethodPost, "/tags", types.TagParams{Name: "work"}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v for a duplicate name, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodPost, "/tags", types.TagParams{Name: "work"}, otherToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected tag names to be unique per user, got http status code %v", rr.Code)
+	}
+	rr = do(t, r, http.MethodPost, "/tags", types.TagParams{Name: "home", Color: "red"}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v for an invalid color, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	target := fmt.Sprintf("/tags/%d", tag.ID)
+	color := "#ff0000"
+	rr = do(t, r, http.MethodPatch, target, types.UpdateTagParams{Color: &color}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&tag); err != nil {
+		t.Fatal(err)
+	}
+	if tag.Color != color || tag.Name != "work" {
+		t.Fatalf("expected the color to be patched, got %+v", tag)
+	}
+
+	rr = do(t, r, http.MethodPost, "/tags", types.TagParams{Name: "home"}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var home types.Tag
+	if err := json.NewDecoder(rr.Body).Decode(&home); err != nil {
+		t.Fatal(err)
+	}
+	name := "work"
+	rr = do(t, r, http.MethodPatch, fmt.Sprintf("/tags/%d", home.ID), types.UpdateTagParams{Name: &name}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v for a duplicate name, got %v (resp: %s)", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	rr = do(t, r, http.MethodPatch, "/tags/999999", types.UpdateTagParams{Name: &name}, token)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected http status code %v for an unknown tag, got %v", http.StatusNotFound, rr.Code)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		rr := do(t, r, method, target, types.UpdateTagParams{Color: &color}, otherToken)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected http status code %v, got %v", method, http.StatusNotFound, rr.Code)
+		}
+	}
+
+	rr = do(t, r, http.MethodGet, "/tags", nil, token)
+	var list types.TagGetAllResponse
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if list.Count != 2 {
+		t.Fatalf("expected 2 tags, got %v", list.Count)
+	}
+
+	rr = do(t, r, http.MethodDelete, target, nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v", http.StatusOK, rr.Code)
+	}
+	rr = do(t, r, http.MethodGet, target, nil, token)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected http status code %v, got %v", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestTodoTags(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	other, _ := testSuite.createUser(t)
+	r := testSuite.router()
+
+	newTag := func(name string, userID int) *types.Tag {
+		tag, err := testSuite.tagStore.InsertTag(context.TODO(), types.NewTagFromParams(types.TagParams{Name: name}, userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { testSuite.tagStore.DeleteTagByID(context.TODO(), tag.ID, userID) })
+		return tag
+	}
+	work, home, urgent := newTag("work", user.ID), newTag("home", user.ID), newTag("urgent", user.ID)
+	foreign := newTag("foreign", other.ID)
+
+	newTodo := func(title string, tags ...*types.Tag) *types.Todo {
+		ids := []int64{}
+		for _, tag := range tags {
+			ids = append(ids, tag.ID)
+		}
+		rr := do(t, r, http.MethodPost, "/todos", map[string]any{
+			"title":   title,
+			"content": "Tagged content",
+			"tagIds":  ids,
+		}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, user.ID) })
+		return &todo
+	}
+	both := newTodo("Work from home", work, home)
+	newTodo("Office", work, urgent)
+	newTodo("Untagged")
+
+	if len(both.Tags) != 2 || both.Tags[0].Name != "home" || both.Tags[1].Name != "work" {
+		t.Fatalf("expected the todo to be tagged home and work, got %+v", both.Tags)
+	}
+
+	rr := do(t, r, http.MethodPost, "/todos", map[string]any{
+		"title":   "Foreign",
+		"content": "Someone else's tag",
+		"tagIds":  []int64{foreign.ID},
+	}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v for another users tag, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	tests := []struct {
+		target   string
+		expected []string
+	}{
+		{target: "/todos?tag=work", expected: []string{"Work from home", "Office"}},
+		{target: "/todos?tag=home,urgent", expected: []string{"Work from home", "Office"}},
+		{target: "/todos?tag=home&tag=work&tag_mode=all", expected: []string{"Work from home"}},
+		{target: "/todos?tag=home,urgent&tag_mode=all", expected: []string{}},
+		{target: "/todos?tag=missing", expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rr := do(t, r, http.MethodGet, tt.target, nil, token)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var resp types.TodoGetAllResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, todo := range resp.Result {
+				got = append(got, todo.Title)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Fatalf("expected todos %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	target := fmt.Sprintf("/todos/%d", both.ID)
+	rr = do(t, r, http.MethodPatch, target, map[string]any{"tagIds": []int64{urgent.ID}}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var patched types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&patched); err != nil {
+		t.Fatal(err)
+	}
+	if len(patched.Tags) != 1 || patched.Tags[0].ID != urgent.ID {
+		t.Fatalf("expected the tags to be replaced by urgent, got %+v", patched.Tags)
+	}
+
+	rr = do(t, r, http.MethodDelete, fmt.Sprintf("/tags/%d", urgent.ID), nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v", http.StatusOK, rr.Code)
+	}
+	rr = do(t, r, http.MethodGet, target, nil, token)
+	var got types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tags) != 0 {
+		t.Fatalf("expected a deleted tag to be removed from the todo, got %+v", got.Tags)
+	}
+}
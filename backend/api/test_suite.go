@@ -1,28 +1,193 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/thimc/go-svelte-todo/backend/api/middleware"
+	"github.com/thimc/go-svelte-todo/backend/mail"
 	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
 )
 
 type testSuite struct {
-	databaseStore store.TodoStorer
-	userStore     store.UserStorer
+	databaseStore    store.TodoStorer
+	userStore        store.UserStorer
+	tagStore         store.TagStorer
+	listStore        store.ListStorer
+	checklistStore   store.ChecklistStorer
+	revisionStore    store.TodoRevisionStorer
+	tokenStore       store.TokenStorer
+	sessionStore     store.SessionStorer
+	accessTokenStore store.AccessTokenStorer
+	mfaStore         store.MFAStorer
+	authEventStore   store.AuthEventStorer
+	identityStore    store.IdentityStorer
+	exportStore      store.ExportStorer
 
-	authHandler *AuthHandler
-	userHandler *UserHandler
-	todoHandler *TodoHandler
+	authHandler         *AuthHandler
+	userHandler         *UserHandler
+	todoHandler         *TodoHandler
+	trashHandler        *TrashHandler
+	tagHandler          *TagHandler
+	listHandler         *ListHandler
+	checklistHandler    *ChecklistHandler
+	revisionHandler     *RevisionHandler
+	sessionHandler      *SessionHandler
+	accessTokenHandler  *AccessTokenHandler
+	passwordHandler     *PasswordHandler
+	verificationHandler *VerificationHandler
+	mfaHandler          *MFAHandler
+	authEventHandler    *AuthEventHandler
+	exportHandler       *ExportHandler
+
+	// The keys signing the tokens of the test users
+	keys *middleware.KeySet
+	// Trusts the X-Forwarded-For header of requests from 192.0.2.1, the
+	// address of the requests made by httptest
+	proxy *middleware.ProxyMiddleware
+
+	// The mails sent by the handlers are appended to this file
+	mailFile string
 }
 
 func (s *testSuite) Teardown(t *testing.T) error {
 	return s.databaseStore.Close()
 }
 
+// newTestSuite runs against the in-memory stores unless TEST_STORE is set to
+// "postgres", in which case the connection details are read from ../.env.
 func newTestSuite(t *testing.T) *testSuite {
+	var (
+		databaseStore    store.TodoStorer
+		userStore        store.UserStorer
+		tagStore         store.TagStorer
+		listStore        store.ListStorer
+		checklistStore   store.ChecklistStorer
+		revisionStore    store.TodoRevisionStorer
+		tokenStore       store.TokenStorer
+		sessionStore     store.SessionStorer
+		accessTokenStore store.AccessTokenStorer
+		mfaStore         store.MFAStorer
+		authEventStore   store.AuthEventStorer
+		identityStore    store.IdentityStorer
+		exportStore      store.ExportStorer
+	)
+
+	switch os.Getenv("TEST_STORE") {
+	case "postgres":
+		postgreStore := newPostgreTestStore(t)
+		databaseStore = postgreStore
+		userStore = store.NewPostgreUserStore(postgreStore)
+		tagStore = store.NewPostgreTagStore(postgreStore)
+		listStore = store.NewPostgreListStore(postgreStore)
+		checklistStore = store.NewPostgreChecklistStore(postgreStore)
+		revisionStore = store.NewPostgreRevisionStore(postgreStore)
+		tokenStore = store.NewPostgreTokenStore(postgreStore)
+		sessionStore = store.NewPostgreSessionStore(postgreStore)
+		accessTokenStore = store.NewPostgreAccessTokenStore(postgreStore)
+		mfaStore = store.NewPostgreMFAStore(postgreStore)
+		authEventStore = store.NewPostgreAuthEventStore(postgreStore)
+		identityStore = store.NewPostgreIdentityStore(postgreStore)
+		exportStore = store.NewPostgreExportStore(postgreStore)
+	default:
+		memoryStore := store.NewMemoryTodoStore()
+		databaseStore = memoryStore
+		userStore = store.NewMemoryUserStore(memoryStore)
+		tagStore = store.NewMemoryTagStore(memoryStore)
+		listStore = store.NewMemoryListStore(memoryStore)
+		checklistStore = store.NewMemoryChecklistStore(memoryStore)
+		revisionStore = store.NewMemoryRevisionStore(memoryStore)
+		tokenStore = store.NewMemoryTokenStore(memoryStore)
+		sessionStore = store.NewMemorySessionStore(memoryStore)
+		accessTokenStore = store.NewMemoryAccessTokenStore(memoryStore)
+		mfaStore = store.NewMemoryMFAStore(memoryStore)
+		authEventStore = store.NewMemoryAuthEventStore(memoryStore)
+		identityStore = store.NewMemoryIdentityStore(memoryStore)
+		exportStore = store.NewMemoryExportStore(memoryStore)
+	}
+
+	_, signingKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := middleware.NewKeySet("test", "test-api", signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trustedProxies, err := middleware.ParseTrustedProxies("192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailFile := filepath.Join(t.TempDir(), "mail.txt")
+	mailer := mail.NewFileMailer(mailFile, "todo@localhost")
+	authEventHandler := NewAuthEventHandler(authEventStore)
+	verificationHandler := NewVerificationHandler(userStore, mailer, "http://localhost:5173", types.UnverifiedAllow, "test-secret")
+	authHandler := NewAuthHandler(userStore, listStore, tokenStore, sessionStore, mfaStore, verificationHandler, authEventHandler, keys)
+	todoHandler := NewTodoHandler(databaseStore)
+	trashHandler := NewTrashHandler(databaseStore)
+	tagHandler := NewTagHandler(tagStore)
+	listHandler := NewListHandler(listStore, databaseStore)
+	checklistHandler := NewChecklistHandler(checklistStore)
+	revisionHandler := NewRevisionHandler(revisionStore, databaseStore)
+	sessionHandler := NewSessionHandler(sessionStore)
+	accessTokenHandler := NewAccessTokenHandler(accessTokenStore)
+	passwordHandler := NewPasswordHandler(userStore, tokenStore, sessionStore, mailer, "http://localhost:5173")
+	userHandler := NewUserHandler(userStore, sessionStore, passwordHandler, types.DefaultDeletionGracePeriod)
+	mfaHandler := NewMFAHandler(mfaStore)
+	exportHandler := NewExportHandler(exportStore, userStore, databaseStore, listStore, tagStore, sessionStore, accessTokenStore, identityStore, authEventStore, mfaStore)
+
+	return &testSuite{
+		databaseStore:       databaseStore,
+		userStore:           userStore,
+		tagStore:            tagStore,
+		listStore:           listStore,
+		checklistStore:      checklistStore,
+		revisionStore:       revisionStore,
+		tokenStore:          tokenStore,
+		sessionStore:        sessionStore,
+		accessTokenStore:    accessTokenStore,
+		mfaStore:            mfaStore,
+		authEventStore:      authEventStore,
+		identityStore:       identityStore,
+		exportStore:         exportStore,
+		authHandler:         authHandler,
+		userHandler:         userHandler,
+		todoHandler:         todoHandler,
+		trashHandler:        trashHandler,
+		tagHandler:          tagHandler,
+		listHandler:         listHandler,
+		checklistHandler:    checklistHandler,
+		revisionHandler:     revisionHandler,
+		sessionHandler:      sessionHandler,
+		accessTokenHandler:  accessTokenHandler,
+		passwordHandler:     passwordHandler,
+		verificationHandler: verificationHandler,
+		mfaHandler:          mfaHandler,
+		authEventHandler:    authEventHandler,
+		exportHandler:       exportHandler,
+		keys:                keys,
+		proxy:               middleware.NewProxyMiddleware(trustedProxies),
+		mailFile:            mailFile,
+	}
+}
+
+func newPostgreTestStore(t *testing.T) *store.PostgreTodoStore {
 	if err := godotenv.Load("../.env"); err != nil {
 		t.Fatal(err)
 	}
@@ -40,20 +205,128 @@ func newTestSuite(t *testing.T) *testSuite {
 		t.Fatal(err)
 	}
 
-	userStore, err := store.NewPostgreUserStore(databaseStore)
+	migrator, err := store.NewMigrator(databaseStore)
 	if err != nil {
 		t.Fatal(err)
 	}
+	if _, err := migrator.Up(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
 
-	authHandler := NewAuthHandler(userStore)
-	userHandler := NewUserHandler(userStore)
-	todoHandler := NewTodoHandler(databaseStore)
+	return databaseStore
+}
 
-	return &testSuite{
-		databaseStore: databaseStore,
-		userStore:     userStore,
-		authHandler:   authHandler,
-		userHandler:   userHandler,
-		todoHandler:   todoHandler,
+// createUser inserts a random user with an inbox like HandleRegister does and
+// returns it together with a valid bearer token.
+func (s *testSuite) createUser(t *testing.T) (*types.User, string) {
+	user, err := types.NewUser(fmt.Sprintf("test%d@golangtest.com", rand.Intn(1000000)), "secret-password")
+	if err != nil {
+		t.Fatalf("user validation failed: %v", err)
+	}
+	insertedUser, err := s.userStore.CreateUser(context.TODO(), user)
+	if err != nil {
+		t.Fatalf("error when creating a mock user: %v", err)
 	}
+	t.Cleanup(func() {
+		if err := s.userStore.DeleteUserByID(context.TODO(), int64(insertedUser.ID)); err != nil {
+			t.Errorf("error when removing mock user: %s", err)
+		}
+	})
+	if _, err := s.listStore.InsertList(context.TODO(), types.NewInboxList(insertedUser.ID)); err != nil {
+		t.Fatalf("error when creating the inbox of the mock user: %v", err)
+	}
+
+	session, err := s.sessionStore.CreateSession(context.TODO(), types.NewSession(insertedUser.ID, "go-test", "127.0.0.1"))
+	if err != nil {
+		t.Fatalf("error when creating a session for the mock user: %v", err)
+	}
+	_, token, err := s.keys.CreateJWT(insertedUser, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return insertedUser, token
+}
+
+// router mounts the handlers behind the JWT middleware like main does under /api/v1.
+func (s *testSuite) router() *mux.Router {
+	jwt := middleware.NewJWTMiddleware(s.userStore, s.sessionStore, s.accessTokenStore, s.keys)
+	r := mux.NewRouter()
+	r.Use(jwt.Middleware)
+	r.HandleFunc("/todos", utils.HandleAPIFunc(s.todoHandler.HandleGetTodos)).Methods(http.MethodGet)
+	r.HandleFunc("/todos", utils.HandleAPIFunc(s.todoHandler.HandleInsertTodo)).Methods(http.MethodPost)
+	r.HandleFunc("/todos/search", utils.HandleAPIFunc(s.todoHandler.HandleSearchTodos)).Methods(http.MethodGet)
+	r.HandleFunc("/todos/{id}", utils.HandleAPIFunc(s.todoHandler.HandleGetTodoByID)).Methods(http.MethodGet)
+	r.HandleFunc("/todos/{id}", utils.HandleAPIFunc(s.todoHandler.HandlePutTodo)).Methods(http.MethodPut)
+	r.HandleFunc("/todos/{id}", utils.HandleAPIFunc(s.todoHandler.HandlePatchTodoByID)).Methods(http.MethodPatch)
+	r.HandleFunc("/todos/{id}", utils.HandleAPIFunc(s.todoHandler.HandleDeleteTodoByID)).Methods(http.MethodDelete)
+	r.HandleFunc("/todos/{id}/subtasks", utils.HandleAPIFunc(s.todoHandler.HandleGetSubtasks)).Methods(http.MethodGet)
+	r.HandleFunc("/todos/{id}/occurrences", utils.HandleAPIFunc(s.todoHandler.HandleGetOccurrences)).Methods(http.MethodGet)
+	r.HandleFunc("/todos/{id}/history", utils.HandleAPIFunc(s.revisionHandler.HandleGetHistory)).Methods(http.MethodGet)
+	r.HandleFunc("/todos/{id}/history/{revisionId}/revert", utils.HandleAPIFunc(s.revisionHandler.HandleRevertTodo)).Methods(http.MethodPost)
+	r.HandleFunc("/todos/{id}/restore", utils.HandleAPIFunc(s.trashHandler.HandleRestoreTodo)).Methods(http.MethodPost)
+	r.HandleFunc("/trash", utils.HandleAPIFunc(s.trashHandler.HandleGetTrash)).Methods(http.MethodGet)
+	r.HandleFunc("/trash", utils.HandleAPIFunc(s.trashHandler.HandleEmptyTrash)).Methods(http.MethodDelete)
+	r.HandleFunc("/trash/{id}", utils.HandleAPIFunc(s.trashHandler.HandleDeleteTrashedTodo)).Methods(http.MethodDelete)
+	r.HandleFunc("/todos/{id}/checklist", utils.HandleAPIFunc(s.checklistHandler.HandleInsertChecklistItem)).Methods(http.MethodPost)
+	r.HandleFunc("/todos/{id}/checklist/{itemId}", utils.HandleAPIFunc(s.checklistHandler.HandlePatchChecklistItem)).Methods(http.MethodPatch)
+	r.HandleFunc("/todos/{id}/checklist/{itemId}", utils.HandleAPIFunc(s.checklistHandler.HandleDeleteChecklistItem)).Methods(http.MethodDelete)
+	r.HandleFunc("/tags", utils.HandleAPIFunc(s.tagHandler.HandleGetTags)).Methods(http.MethodGet)
+	r.HandleFunc("/tags", utils.HandleAPIFunc(s.tagHandler.HandleInsertTag)).Methods(http.MethodPost)
+	r.HandleFunc("/tags/{id}", utils.HandleAPIFunc(s.tagHandler.HandleGetTagByID)).Methods(http.MethodGet)
+	r.HandleFunc("/tags/{id}", utils.HandleAPIFunc(s.tagHandler.HandlePatchTagByID)).Methods(http.MethodPatch)
+	r.HandleFunc("/tags/{id}", utils.HandleAPIFunc(s.tagHandler.HandleDeleteTagByID)).Methods(http.MethodDelete)
+	r.HandleFunc("/lists", utils.HandleAPIFunc(s.listHandler.HandleGetLists)).Methods(http.MethodGet)
+	r.HandleFunc("/lists", utils.HandleAPIFunc(s.listHandler.HandleInsertList)).Methods(http.MethodPost)
+	r.HandleFunc("/lists/{id}", utils.HandleAPIFunc(s.listHandler.HandleGetListByID)).Methods(http.MethodGet)
+	r.HandleFunc("/lists/{id}", utils.HandleAPIFunc(s.listHandler.HandlePatchListByID)).Methods(http.MethodPatch)
+	r.HandleFunc("/lists/{id}", utils.HandleAPIFunc(s.listHandler.HandleDeleteListByID)).Methods(http.MethodDelete)
+	r.HandleFunc("/lists/{id}/todos", utils.HandleAPIFunc(s.listHandler.HandleGetListTodos)).Methods(http.MethodGet)
+	admin := r.NewRoute().Subrouter()
+	admin.Use(middleware.NewPermissionMiddleware(types.PermissionManageUsers).Middleware)
+	admin.HandleFunc("/users", utils.HandleAPIFunc(s.userHandler.HandleGetUsers)).Methods(http.MethodGet)
+	admin.HandleFunc("/users/{id}", utils.HandleAPIFunc(s.userHandler.HandleDeleteUserByID)).Methods(http.MethodDelete)
+	admin.HandleFunc("/users/{id}/role", utils.HandleAPIFunc(s.userHandler.HandlePutUserRole)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/disabled", utils.HandleAPIFunc(s.userHandler.HandlePutUserDisabled)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/password/reset", utils.HandleAPIFunc(s.userHandler.HandleResetUserPassword)).Methods(http.MethodPost)
+	r.HandleFunc("/users/{id}", utils.HandleAPIFunc(s.userHandler.HandleGetUserByID)).Methods(http.MethodGet)
+	r.HandleFunc("/user", utils.HandleAPIFunc(s.userHandler.HandleDeleteUser)).Methods(http.MethodDelete)
+	r.HandleFunc("/user/sessions", utils.HandleAPIFunc(s.sessionHandler.HandleGetSessions)).Methods(http.MethodGet)
+	r.HandleFunc("/user/sessions/{id}", utils.HandleAPIFunc(s.sessionHandler.HandleDeleteSession)).Methods(http.MethodDelete)
+	r.HandleFunc("/user/tokens", utils.HandleAPIFunc(s.accessTokenHandler.HandleGetAccessTokens)).Methods(http.MethodGet)
+	r.HandleFunc("/user/tokens", utils.HandleAPIFunc(s.accessTokenHandler.HandleInsertAccessToken)).Methods(http.MethodPost)
+	r.HandleFunc("/user/tokens/{id}", utils.HandleAPIFunc(s.accessTokenHandler.HandleDeleteAccessToken)).Methods(http.MethodDelete)
+	r.HandleFunc("/user/events", utils.HandleAPIFunc(s.authEventHandler.HandleGetAuthEvents)).Methods(http.MethodGet)
+	r.HandleFunc("/user/export", utils.HandleAPIFunc(s.exportHandler.HandleExport)).Methods(http.MethodGet)
+	r.HandleFunc("/user/export/{id}", utils.HandleAPIFunc(s.exportHandler.HandleGetExport)).Methods(http.MethodGet)
+	r.HandleFunc("/user/export/{id}/download", utils.HandleAPIFunc(s.exportHandler.HandleDownloadExport)).Methods(http.MethodGet)
+	r.HandleFunc("/user/mfa", utils.HandleAPIFunc(s.mfaHandler.HandleGetMFA)).Methods(http.MethodGet)
+	r.HandleFunc("/user/mfa", utils.HandleAPIFunc(s.mfaHandler.HandleEnrollMFA)).Methods(http.MethodPost)
+	r.HandleFunc("/user/mfa/confirm", utils.HandleAPIFunc(s.mfaHandler.HandleConfirmMFA)).Methods(http.MethodPost)
+	r.HandleFunc("/user/mfa/recovery-codes", utils.HandleAPIFunc(s.mfaHandler.HandleRegenerateRecoveryCodes)).Methods(http.MethodPost)
+	r.HandleFunc("/user/mfa/disable", utils.HandleAPIFunc(s.mfaHandler.HandleDisableMFA)).Methods(http.MethodPost)
+
+	return r
+}
+
+// do sends a request with an optional JSON body and bearer token through the router.
+func do(t *testing.T, r http.Handler, method, target string, body any, token string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Add("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	return rr
 }
@@ -2,9 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/thimc/go-svelte-todo/backend/store"
@@ -24,9 +27,21 @@ func NewTodoHandler(databaseStore store.TodoStorer) *TodoHandler {
 
 
 // @Summary		Get all todos.
-// @Description	fetch every todo available.
+// @Description	fetch a page of the todos created by the authenticated user.
 // @Tags		todos
 // @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		limit	query	int	false	"Page size, at most 100"	default(50)
+// @Param		cursor	query	string	false	"The next cursor of the previous page"
+// @Param		sort	query	string	false	"created, updated, title or done, prefix with - to sort descending"	default(created)
+// @Param		done	query	bool	false	"Only todos with this done status"
+// @Param		created_after	query	string	false	"Only todos created after this RFC 3339 timestamp"
+// @Param		created_by	query	int	false	"Only todos created by this user ID"
+// @Param		overdue	query	bool	false	"Only todos that are past their due date and not done"
+// @Param		due_today	query	bool	false	"Only todos due today"
+// @Param		due_within	query	string	false	"Only todos due within this duration from now, such as 7d or 12h"
+// @Param		tz	query	string	false	"IANA time zone that decides when today starts"	default(UTC)
+// @Param		list_id	query	int	false	"Only todos in this list"
+// @Param		parent_id	query	int	false	"Only the subtasks of this todo"
 // @Accept		*/*
 // @Produce		json
 // @Success		200	{object}	types.TodoGetAllResponse
@@ -35,12 +50,60 @@ func NewTodoHandler(databaseStore store.TodoStorer) *TodoHandler {
 // @Router		/api/v1/todos [get]
 // @Security	ApiKeyAuth
 func (h *TodoHandler) HandleGetTodos(w http.ResponseWriter, r *http.Request) *types.APIError {
-	todos, err := h.store.GetTodos(r.Context())
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	query, err := types.ParseTodoQuery(r.URL.Query())
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	query.UserID = user.ID
+
+	page, err := h.store.GetTodos(r.Context(), query)
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusInternalServerError)
 	}
 
-	return utils.ResponseWriteJSON(w, types.NewTodoGetAllResponse(todos))
+	return utils.ResponseWriteJSON(w, types.NewTodoGetAllResponse(page))
+}
+
+// @Summary		Search todos.
+// @Description	full-text search across the titles and contents of the authenticated users todos.
+// @Tags		todos
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		q	query	string	true	"Search query"
+// @Param		limit	query	int	false	"Maximum number of results, at most 100"	default(50)
+// @Produce		json
+// @Success		200	{object}	types.TodoSearchResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/todos/search [get]
+// @Security	ApiKeyAuth
+func (h *TodoHandler) HandleSearchTodos(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	search := strings.TrimSpace(r.URL.Query().Get("q"))
+	if search == "" {
+		return types.NewAPIError(false, fmt.Errorf("the search query can't be empty"), http.StatusBadRequest)
+	}
+	limit := types.DefaultTodoLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > types.MaxTodoLimit {
+			return types.NewAPIError(false, fmt.Errorf("limit needs to be a number between 1 and %d", types.MaxTodoLimit), http.StatusBadRequest)
+		}
+		limit = n
+	}
+
+	results, err := h.store.SearchTodos(r.Context(), user.ID, search, limit)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewTodoSearchResponse(results))
 }
 
 // @Summary		Get a todo by the ID.
@@ -59,7 +122,11 @@ func (h *TodoHandler) HandleGetTodoByID(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
-	todo, err := h.store.GetTodoByID(r.Context(), int64(id))
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	todo, err := h.store.GetTodoByID(r.Context(), int64(id), user.ID)
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusNotFound)
 	}
@@ -79,10 +146,15 @@ func (h *TodoHandler) HandleGetTodoByID(w http.ResponseWriter, r *http.Request)
 // @Router		/api/v1/todos [post]
 // @Security	ApiKeyAuth
 func (h *TodoHandler) HandleInsertTodo(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
 	var params types.InsertTodoParams
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
+	params.CreatedBy = user.ID
 	todo := types.NewTodoFromParams(params)
 	if err := todo.Validate(); err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
@@ -105,9 +177,14 @@ func (h *TodoHandler) HandleInsertTodo(w http.ResponseWriter, r *http.Request) *
 // @Produce		json
 // @Success		200	{object}	types.APIError
 // @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
 // @Security	ApiKeyAuth
 // @Router		/api/v1/todos/{id} [put]
 func (h *TodoHandler) HandlePutTodo(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
@@ -116,16 +193,22 @@ func (h *TodoHandler) HandlePutTodo(w http.ResponseWriter, r *http.Request) *typ
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
-
-	if err := h.store.UpdateTodoByID(r.Context(), params, int64(id)); err != nil {
+	if err := params.Validate(); err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
+	// The owner can't be replaced and the editor is always the caller.
+	params.CreatedBy = &user.ID
+	params.UpdatedBy = &user.ID
+
+	if err := h.store.UpdateTodoByID(r.Context(), params, int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, todoErrorStatus(err, http.StatusNotFound))
+	}
 
 	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("todo ID: %d", id), http.StatusOK))
 }
 
 // @Summary		Delete a todo.
-// @Description	deletes a todo by the ID.
+// @Description	moves a todo with its subtasks to the trash, where it can be restored until it is purged.
 // @Tags		todos
 // @Accept		json
 // @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
@@ -137,12 +220,16 @@ func (h *TodoHandler) HandlePutTodo(w http.ResponseWriter, r *http.Request) *typ
 // @Router		/api/v1/todos/{id} [delete]
 // @Security	ApiKeyAuth
 func (h *TodoHandler) HandleDeleteTodoByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
 
-	if err := h.store.DeleteTodoByID(r.Context(), int64(id)); err != nil {
+	if err := h.store.DeleteTodoByID(r.Context(), int64(id), user.ID); err != nil {
 		return types.NewAPIError(false, err, http.StatusNotFound)
 	}
 
@@ -150,19 +237,23 @@ func (h *TodoHandler) HandleDeleteTodoByID(w http.ResponseWriter, r *http.Reques
 }
 
 // @Summary		Patch a todo.
-// @Description	mutates a todos properties.
+// @Description	mutates a todos properties and returns the updated todo. Completing a recurring todo creates its next occurrence.
 // @Tags		todos
 // @Accept		json
 // @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
 // @Param		id	path	int	true	"Todo ID"
-// @Param		todo	body	types.UpdateTodoParams	false	"New todo data"
+// @Param		todo	body	types.UpdateTodoParams	true	"New todo data"
 // @Produce		json
-// @Success		200	{object}	types.APIError
+// @Success		200	{object}	types.Todo
 // @Failure		400	{object}	types.APIError
 // @Failure		404	{object}	types.APIError
 // Security		ApiKeyAuth
 // @Router		/api/v1/todos/{id} [patch]
 func (h *TodoHandler) HandlePatchTodoByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
@@ -172,10 +263,114 @@ func (h *TodoHandler) HandlePatchTodoByID(w http.ResponseWriter, r *http.Request
 	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
+	// Ownership can't be transferred to another user.
+	params.CreatedBy = nil
+	if params.Empty() {
+		return types.NewAPIError(false, fmt.Errorf("the patch needs to contain at least one field"), http.StatusBadRequest)
+	}
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	// The editor is always the caller.
+	params.UpdatedBy = &user.ID
+
+	todo, err := h.store.PatchTodoByID(r.Context(), int64(id), user.ID, params)
+	if err != nil {
+		return types.NewAPIError(false, err, todoErrorStatus(err, http.StatusNotFound))
+	}
 
-	if err := h.store.PatchTodoByID(r.Context(), int64(id), params); err != nil {
+	return utils.ResponseWriteJSON(w, todo)
+}
+
+// @Summary		Preview the occurrences of a recurring todo.
+// @Description	lists the due dates of the todos that marking this recurring todo (and its successors) as done would create.
+// @Tags		todos
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Param		count	query	int	false	"Maximum number of occurrences, at most 50"	default(5)
+// @Produce		json
+// @Success		200	{object}	types.TodoOccurrencesResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/occurrences [get]
+// @Security	ApiKeyAuth
+func (h *TodoHandler) HandleGetOccurrences(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	count := types.DefaultOccurrenceCount
+	if value := r.URL.Query().Get("count"); value != "" {
+		count, err = strconv.Atoi(value)
+		if err != nil || count < 1 || count > types.MaxOccurrenceCount {
+			return types.NewAPIError(false, fmt.Errorf("count needs to be between 1 and %d", types.MaxOccurrenceCount), http.StatusBadRequest)
+		}
+	}
+
+	todo, err := h.store.GetTodoByID(r.Context(), int64(id), user.ID)
+	if err != nil {
 		return types.NewAPIError(false, err, http.StatusNotFound)
 	}
+	occurrences, err := todo.UpcomingOccurrences(time.Now(), count)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
 
-	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("todo ID: %d", id), http.StatusOK))
+	return utils.ResponseWriteJSON(w, types.NewTodoOccurrencesResponse(occurrences))
+}
+
+// @Summary		Get the subtasks of a todo.
+// @Description	fetch a page of the direct subtasks of a todo, takes the same query parameters as /api/v1/todos.
+// @Tags		todos
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Param		limit	query	int	false	"Page size, at most 100"	default(50)
+// @Param		cursor	query	string	false	"The next cursor of the previous page"
+// @Param		sort	query	string	false	"created, updated, title or done, prefix with - to sort descending"	default(created)
+// @Produce		json
+// @Success		200	{object}	types.TodoGetAllResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/subtasks [get]
+// @Security	ApiKeyAuth
+func (h *TodoHandler) HandleGetSubtasks(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	todo, err := h.store.GetTodoByID(r.Context(), int64(id), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	query, err := types.ParseTodoQuery(r.URL.Query())
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	query.UserID = user.ID
+	query.ParentID = &todo.ID
+
+	page, err := h.store.GetTodos(r.Context(), query)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewTodoGetAllResponse(page))
+}
+
+// todoErrorStatus returns the status of a store error, an invalid parent is
+// always a bad request.
+func todoErrorStatus(err error, status int) int {
+	if errors.Is(err, store.ErrInvalidParent) {
+		return http.StatusBadRequest
+	}
+	return status
 }
@@ -8,7 +8,9 @@ import (
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/thimc/go-svelte-todo/backend/api/middleware"
@@ -20,7 +22,7 @@ func TestHandleGetTodosFail(t *testing.T) {
 	testSuite := newTestSuite(t)
 	defer testSuite.Teardown(t)
 
-	jwt := middleware.NewJWTMiddleware(testSuite.userStore)
+	jwt := middleware.NewJWTMiddleware(testSuite.userStore, testSuite.sessionStore, testSuite.accessTokenStore, testSuite.keys)
 	r := mux.NewRouter()
 	r.Use(jwt.Middleware)
 
@@ -76,13 +78,13 @@ func TestHandleGetTodosSuccess(t *testing.T) {
 	}
 
 	defer func() {
-		err := testSuite.databaseStore.DeleteTodoByID(context.TODO(), int64(insertedTodo.ID))
+		err := testSuite.databaseStore.DeleteTodoByID(context.TODO(), int64(insertedTodo.ID), insertedUser.ID)
 		if err != nil {
 			t.Fatal(err)
 		}
 	}()
 
-	jwt := middleware.NewJWTMiddleware(testSuite.userStore)
+	jwt := middleware.NewJWTMiddleware(testSuite.userStore, testSuite.sessionStore, testSuite.accessTokenStore, testSuite.keys)
 	r := mux.NewRouter()
 	r.HandleFunc("/", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
 
@@ -166,3 +168,671 @@ func TestHandleGetTodosSuccess(t *testing.T) {
 	}
 
 }
+
+func TestTodoOwnershipIsolation(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	owner, ownerToken := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+
+	r := testSuite.router()
+
+	rr := do(t, r, http.MethodPost, "/todos", map[string]any{
+		"title":     "Owned todo",
+		"content":   "Owned content",
+		"createdBy": owner.ID + 1000,
+	}, ownerToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var todo types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, owner.ID)
+
+	if todo.CreatedBy != owner.ID {
+		t.Fatalf("expected created by user id '%v', got '%v'", owner.ID, todo.CreatedBy)
+	}
+
+	rr = do(t, r, http.MethodGet, "/todos", nil, otherToken)
+	var getResp types.TodoGetAllResponse
+	if err := json.NewDecoder(rr.Body).Decode(&getResp); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range getResp.Result {
+		if got.ID == todo.ID {
+			t.Fatal("expected another users todo to be excluded from the list")
+		}
+	}
+
+	target := fmt.Sprintf("/todos/%d", todo.ID)
+	title := "Hijacked"
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		var body any
+		if method == http.MethodPut || method == http.MethodPatch {
+			body = types.UpdateTodoParams{Title: &title}
+		}
+		rr := do(t, r, method, target, body, otherToken)
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("%s: expected http status code %v, got %v", method, http.StatusNotFound, rr.Code)
+		}
+	}
+
+	rr = do(t, r, http.MethodGet, target, nil, ownerToken)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v", http.StatusOK, rr.Code)
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	if todo.Title != "Owned todo" {
+		t.Fatalf("expected todo title '%v', got '%v'", "Owned todo", todo.Title)
+	}
+}
+
+func TestHandlePatchTodoByID(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	r := testSuite.router()
+
+	todo, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+		Title:     "This is the title",
+		Content:   "This is the content",
+		CreatedBy: user.ID,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, user.ID)
+	target := fmt.Sprintf("/todos/%d", todo.ID)
+
+	rr := do(t, r, http.MethodPatch, target, map[string]any{}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v for an empty patch, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	title := "It's a title'; DROP TABLE todo; --"
+	done := true
+	rr = do(t, r, http.MethodPatch, target, types.UpdateTodoParams{Title: &title, Done: &done}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var patched types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.ID != todo.ID {
+		t.Fatalf("expected todo ID %v, got %v", todo.ID, patched.ID)
+	}
+	if patched.Title != title {
+		t.Fatalf("expected todo title '%v', got '%v'", title, patched.Title)
+	}
+	if patched.Content != todo.Content {
+		t.Fatalf("expected todo content '%v', got '%v'", todo.Content, patched.Content)
+	}
+	if !patched.Done {
+		t.Fatal("expected the todo to be done")
+	}
+
+	rr = do(t, r, http.MethodPatch, "/todos/0", types.UpdateTodoParams{Done: &done}, token)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected http status code %v, got %v", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleGetTodosPagination(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	r := testSuite.router()
+
+	titles := []string{"Echo", "Alpha", "Delta", "Bravo", "Charlie"}
+	for i, title := range titles {
+		todo, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+			Title:     title,
+			Content:   "Some content",
+			CreatedBy: user.ID,
+			Done:      i%2 == 0,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, user.ID)
+	}
+
+	var (
+		got  []string
+		next string
+	)
+	for page := 0; ; page++ {
+		target := "/todos?limit=2&sort=-title"
+		if next != "" {
+			target += "&cursor=" + next
+		}
+		rr := do(t, r, http.MethodGet, target, nil, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.TodoGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Count != len(titles) {
+			t.Fatalf("expected count %v, got %v", len(titles), resp.Count)
+		}
+		if len(resp.Result) > 2 {
+			t.Fatalf("expected at most 2 todos per page, got %v", len(resp.Result))
+		}
+		for _, todo := range resp.Result {
+			got = append(got, todo.Title)
+		}
+		if resp.Next == "" {
+			break
+		}
+		if page > len(titles) {
+			t.Fatal("expected the pagination to end")
+		}
+		next = resp.Next
+	}
+
+	expected := []string{"Echo", "Delta", "Charlie", "Bravo", "Alpha"}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("expected todos %v, got %v", expected, got)
+	}
+
+	rr := do(t, r, http.MethodGet, "/todos?done=true&sort=title", nil, token)
+	var resp types.TodoGetAllResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 3 || len(resp.Result) != 3 || resp.Next != "" {
+		t.Fatalf("expected 3 done todos on a single page, got %+v", resp)
+	}
+	if resp.Result[0].Title != "Charlie" || resp.Result[2].Title != "Echo" {
+		t.Fatalf("expected the done todos sorted by title, got %v, %v, %v",
+			resp.Result[0].Title, resp.Result[1].Title, resp.Result[2].Title)
+	}
+
+	for _, target := range []string{
+		"/todos?limit=0",
+		"/todos?limit=1000",
+		"/todos?sort=content",
+		"/todos?done=maybe",
+		"/todos?created_after=yesterday",
+		"/todos?cursor=garbage",
+		"/todos?sort=title&cursor=" + next,
+	} {
+		rr := do(t, r, http.MethodGet, target, nil, token)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected http status code %v, got %v", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestHandleSearchTodos(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	other, _ := testSuite.createUser(t)
+	r := testSuite.router()
+
+	for _, params := range []types.InsertTodoParams{
+		{Title: "Groceries", Content: "Buy milk and eggs", CreatedBy: user.ID},
+		{Title: "Milk the cows", Content: "Before breakfast, the milk truck comes at nine", CreatedBy: user.ID},
+		{Title: "Laundry", Content: "Wash the towels", CreatedBy: user.ID},
+		{Title: "Milkshake", Content: "Someone else's milk", CreatedBy: other.ID},
+	} {
+		todo, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(params))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, params.CreatedBy)
+	}
+
+	rr := do(t, r, http.MethodGet, "/todos/search?q=milk", nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var resp types.TodoSearchResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 2 {
+		t.Fatalf("expected 2 matches, got %v", resp.Count)
+	}
+	if resp.Result[0].Todo.Title != "Milk the cows" {
+		t.Fatalf("expected the title match to rank first, got '%v'", resp.Result[0].Todo.Title)
+	}
+	for _, result := range resp.Result {
+		if !strings.Contains(result.Snippet, "<b>") {
+			t.Errorf("expected a highlighted snippet, got '%v'", result.Snippet)
+		}
+		if result.Todo.CreatedBy != user.ID {
+			t.Errorf("expected only the users own todos, got one created by %v", result.Todo.CreatedBy)
+		}
+	}
+
+	rr = do(t, r, http.MethodGet, "/todos/search?q=towels+milk", nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 0 {
+		t.Fatalf("expected every word to match, got %v results", resp.Count)
+	}
+
+	xss, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+		Title: "Oatmeal", Content: "<script>alert('oatmeal')</script>", CreatedBy: user.ID,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), xss.ID, user.ID)
+	rr = do(t, r, http.MethodGet, "/todos/search?q=oatmeal", nil, token)
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Count != 1 {
+		t.Fatalf("expected 1 match, got %v", resp.Count)
+	}
+	if snippet := resp.Result[0].Snippet; strings.Contains(snippet, "<script>") || !strings.Contains(snippet, "&lt;script&gt;") || !strings.Contains(snippet, "<b>") {
+		t.Errorf("expected an escaped snippet with the matches highlighted, got '%v'", snippet)
+	}
+
+	rr = do(t, r, http.MethodGet, "/todos/search?q=", nil, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v, got %v", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandleGetTodosDueFilters(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	r := testSuite.router()
+
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	for _, params := range []types.InsertTodoParams{
+		{Title: "Overdue", Content: "Was due yesterday", DueAt: at(-30 * time.Hour), Priority: types.PriorityUrgent},
+		{Title: "Done late", Content: "Was due yesterday but done", DueAt: at(-30 * time.Hour), Done: true},
+		{Title: "Due now", Content: "Due right about now", DueAt: at(time.Second)},
+		{Title: "Due soon", Content: "Due in three days", DueAt: at(72 * time.Hour), Priority: types.PriorityLow},
+		{Title: "Due later", Content: "Due in two weeks", DueAt: at(14 * 24 * time.Hour)},
+		{Title: "Someday", Content: "No due date"},
+	} {
+		params.CreatedBy = user.ID
+		todo, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(params))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, user.ID)
+	}
+
+	tests := []struct {
+		target   string
+		expected []string
+	}{
+		{target: "/todos?overdue=true", expected: []string{"Overdue"}},
+		{target: "/todos?due_today=true", expected: []string{"Due now"}},
+		{target: "/todos?due_within=7d", expected: []string{"Due now", "Due soon"}},
+		{target: "/todos?due_within=7d&due_today=true", expected: []string{"Due now"}},
+		{target: "/todos?overdue=false", expected: []string{"Overdue", "Done late", "Due now", "Due soon", "Due later", "Someday"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rr := do(t, r, http.MethodGet, tt.target, nil, token)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+			}
+			var resp types.TodoGetAllResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, todo := range resp.Result {
+				got = append(got, todo.Title)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+				t.Fatalf("expected todos %v, got %v", tt.expected, got)
+			}
+		})
+	}
+
+	for _, target := range []string{
+		"/todos?overdue=true&done=true",
+		"/todos?due_within=-1d",
+		"/todos?due_within=soon",
+		"/todos?due_today=true&tz=Mars/Olympus_Mons",
+	} {
+		rr := do(t, r, http.MethodGet, target, nil, token)
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected http status code %v, got %v", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestTodoPriorityValidation(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	user, token := testSuite.createUser(t)
+	r := testSuite.router()
+
+	rr := do(t, r, http.MethodPost, "/todos", map[string]any{
+		"title":    "Priority",
+		"content":  "Unknown priority",
+		"priority": "critical",
+	}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = do(t, r, http.MethodPost, "/todos", map[string]any{
+		"title":   "Priority",
+		"content": "Default priority",
+		"dueAt":   "2030-01-02T15:04:05+02:00",
+	}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var todo types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	defer testSuite.databaseStore.DeleteTodoByID(context.TODO(), todo.ID, user.ID)
+	if todo.Priority != types.PriorityNormal {
+		t.Fatalf("expected priority '%v', got '%v'", types.PriorityNormal, todo.Priority)
+	}
+	if todo.DueAt == nil || !todo.DueAt.Equal(time.Date(2030, 1, 2, 13, 4, 5, 0, time.UTC)) {
+		t.Fatalf("expected the due date to be stored, got %v", todo.DueAt)
+	}
+
+	target := fmt.Sprintf("/todos/%d", todo.ID)
+	rr = do(t, r, http.MethodPatch, target, map[string]any{"priority": "whenever"}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected http status code %v, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodPatch, target, map[string]any{"priority": types.PriorityHigh}, token)
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+	if todo.Priority != types.PriorityHigh {
+		t.Fatalf("expected priority '%v', got '%v'", types.PriorityHigh, todo.Priority)
+	}
+}
+
+func TestTodoSubtasks(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	insert := func(params types.InsertTodoParams, token string) *types.Todo {
+		t.Helper()
+		rr := do(t, r, http.MethodPost, "/todos", params, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		return &todo
+	}
+
+	parent := insert(types.InsertTodoParams{Title: "Move house", Content: "Everything", AutoComplete: true}, token)
+	child := insert(types.InsertTodoParams{Title: "Pack boxes", Content: "Kitchen first", ParentID: &parent.ID}, token)
+	grandchild := insert(types.InsertTodoParams{Title: "Buy tape", Content: "Lots of it", ParentID: &child.ID}, token)
+	other := insert(types.InsertTodoParams{Title: "Not yours", Content: "Really not"}, otherToken)
+
+	rr := do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Too deep", Content: "Level four", ParentID: &grandchild.ID}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v when nesting too deep, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Sneaky", Content: "Below theirs", ParentID: &other.ID}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v for a parent of another user, got %v", http.StatusBadRequest, rr.Code)
+	}
+	rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", parent.ID), types.UpdateTodoParams{ParentID: &grandchild.ID}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v for a cycle, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d/subtasks", parent.ID), nil, token)
+	var page types.TodoGetAllResponse
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 1 || page.Result[0].ID != child.ID {
+		t.Fatalf("expected only the direct subtask, got %+v", page.Result)
+	}
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d/subtasks", parent.ID), nil, otherToken)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected http status code %v for the subtasks of another user, got %v", http.StatusNotFound, rr.Code)
+	}
+
+	sibling := insert(types.InsertTodoParams{Title: "Book a van", Content: "Saturday", ParentID: &parent.ID}, token)
+	done := true
+	rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", sibling.ID), types.UpdateTodoParams{Done: &done}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	get := func(id int64) *types.Todo {
+		t.Helper()
+		rr := do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d", id), nil, token)
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		return &todo
+	}
+	parent = get(parent.ID)
+	if parent.Progress == nil || parent.Progress.Label != "1/2 done" || parent.Done {
+		t.Fatalf("expected the parent to be 1/2 done, got %+v", parent.Progress)
+	}
+
+	// The child has no auto complete, so both of the lower levels are marked done by hand.
+	for _, id := range []int64{grandchild.ID, child.ID} {
+		rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", id), types.UpdateTodoParams{Done: &done}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+	parent = get(parent.ID)
+	if !parent.Done || parent.Progress.Label != "2/2 done" {
+		t.Fatalf("expected the parent to be auto completed, got done=%v progress=%+v", parent.Done, parent.Progress)
+	}
+
+	rr = do(t, r, http.MethodDelete, fmt.Sprintf("/todos/%d", child.ID), nil, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v", http.StatusOK, rr.Code)
+	}
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d", grandchild.ID), nil, token)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected the subtasks to be deleted with their parent, got http status code %v", rr.Code)
+	}
+}
+
+func TestRecurringTodos(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	r := testSuite.router()
+
+	monday := time.Date(2030, time.January, 7, 9, 0, 0, 0, time.UTC)
+	rr := do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Chores", Content: "Vacuum", DueAt: &monday, Recurrence: "FREQ=YEARLY"}, token)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected http status code %v for an unsupported rule, got %v", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Chores", Content: "Vacuum", DueAt: &monday,
+		Recurrence: "RRULE:FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3"}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var todo types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+		t.Fatal(err)
+	}
+
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d/occurrences?count=10", todo.ID), nil, token)
+	var occurrences types.TodoOccurrencesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&occurrences); err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{monday.AddDate(0, 0, 3), monday.AddDate(0, 0, 7)}
+	if occurrences.Count != len(expected) {
+		t.Fatalf("expected %d occurrences, got %v", len(expected), occurrences.Result)
+	}
+	for i, occurrence := range occurrences.Result {
+		if !occurrence.Equal(expected[i]) {
+			t.Errorf("expected occurrence %d at %v, got %v", i, expected[i], occurrence)
+		}
+	}
+
+	done := true
+	for i, due := range expected {
+		rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", todo.ID), types.UpdateTodoParams{Done: &done}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var completed types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&completed); err != nil {
+			t.Fatal(err)
+		}
+		if completed.Recurrence != "" {
+			t.Errorf("expected the series to continue on the next todo, got %q", completed.Recurrence)
+		}
+
+		rr = do(t, r, http.MethodGet, "/todos?done=false", nil, token)
+		var page types.TodoGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Count != 1 {
+			t.Fatalf("expected one open todo after completing occurrence %d, got %+v", i, page.Result)
+		}
+		todo = *page.Result[0]
+		if todo.DueAt == nil || !todo.DueAt.Equal(due) || todo.Title != "Chores" {
+			t.Fatalf("expected the next occurrence to be due at %v, got %+v", due, todo)
+		}
+	}
+	if todo.Recurrence != "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=1" {
+		t.Errorf("expected the last occurrence to have a count of 1, got %q", todo.Recurrence)
+	}
+
+	rr = do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", todo.ID), types.UpdateTodoParams{Done: &done}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = do(t, r, http.MethodGet, "/todos?done=false", nil, token)
+	var page types.TodoGetAllResponse
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 0 {
+		t.Fatalf("expected the series to end after COUNT occurrences, got %+v", page.Result)
+	}
+
+	endOfMonth := time.Date(2030, time.January, 31, 9, 0, 0, 0, time.UTC)
+	for rule, expected := range map[string][]time.Time{
+		"FREQ=MONTHLY;BYMONTHDAY=-1": {
+			time.Date(2030, time.February, 28, 9, 0, 0, 0, time.UTC),
+			time.Date(2030, time.March, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2030, time.April, 30, 9, 0, 0, 0, time.UTC),
+		},
+		"monthly": {
+			time.Date(2030, time.March, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2030, time.May, 31, 9, 0, 0, 0, time.UTC),
+			time.Date(2030, time.July, 31, 9, 0, 0, 0, time.UTC),
+		},
+	} {
+		rr := do(t, r, http.MethodPost, "/todos", types.InsertTodoParams{Title: "Rent", Content: "Pay it", DueAt: &endOfMonth, Recurrence: rule}, token)
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d/occurrences?count=3", todo.ID), nil, token)
+		var occurrences types.TodoOccurrencesResponse
+		if err := json.NewDecoder(rr.Body).Decode(&occurrences); err != nil {
+			t.Fatal(err)
+		}
+		if occurrences.Count != len(expected) {
+			t.Fatalf("%s: expected %d occurrences, got %v", rule, len(expected), occurrences.Result)
+		}
+		for i, occurrence := range occurrences.Result {
+			if !occurrence.Equal(expected[i]) {
+				t.Errorf("%s: expected occurrence %d at %v, got %v", rule, i, expected[i], occurrence)
+			}
+		}
+	}
+}
+
+func TestRecurringTodoAutoComplete(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	r := testSuite.router()
+
+	insert := func(params types.InsertTodoParams) *types.Todo {
+		t.Helper()
+		rr := do(t, r, http.MethodPost, "/todos", params, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		return &todo
+	}
+
+	monday := time.Date(2030, time.January, 7, 9, 0, 0, 0, time.UTC)
+	parent := insert(types.InsertTodoParams{Title: "Water plants", Content: "All of them", DueAt: &monday, AutoComplete: true, Recurrence: "FREQ=DAILY"})
+	subtask := insert(types.InsertTodoParams{Title: "Balcony", Content: "The big ones", ParentID: &parent.ID})
+
+	// Completing the last subtask completes the parent, which continues the series.
+	done := true
+	rr := do(t, r, http.MethodPatch, fmt.Sprintf("/todos/%d", subtask.ID), types.UpdateTodoParams{Done: &done}, token)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	rr = do(t, r, http.MethodGet, fmt.Sprintf("/todos/%d", parent.ID), nil, token)
+	var completed types.Todo
+	if err := json.NewDecoder(rr.Body).Decode(&completed); err != nil {
+		t.Fatal(err)
+	}
+	if !completed.Done || completed.Recurrence != "" {
+		t.Errorf("expected the parent to be done and the series to continue on the next todo, got %+v", completed)
+	}
+
+	rr = do(t, r, http.MethodGet, "/todos?done=false", nil, token)
+	var page types.TodoGetAllResponse
+	if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 1 {
+		t.Fatalf("expected the next occurrence to be the only open todo, got %+v", page.Result)
+	}
+	next := page.Result[0]
+	if next.Title != parent.Title || next.DueAt == nil || !next.DueAt.Equal(monday.AddDate(0, 0, 1)) || next.Recurrence != "FREQ=DAILY" {
+		t.Errorf("expected the next occurrence to be due at %v, got %+v", monday.AddDate(0, 0, 1), next)
+	}
+}
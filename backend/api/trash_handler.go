@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+type TrashHandler struct {
+	store store.TodoStorer
+}
+
+func NewTrashHandler(databaseStore store.TodoStorer) *TrashHandler {
+	return &TrashHandler{
+		store: databaseStore,
+	}
+}
+
+// @Summary		Get the trash.
+// @Description	fetch the deleted todos of the authenticated user, most recently deleted first. The subtasks of deleted todos are restored and deleted along with them.
+// @Tags		trash
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.TrashGetAllResponse
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/trash [get]
+// @Security	ApiKeyAuth
+func (h *TrashHandler) HandleGetTrash(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	todos, err := h.store.GetTrash(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.TrashGetAllResponse{Count: len(todos), Result: todos})
+}
+
+// @Summary		Restore a todo.
+// @Description	restores a deleted todo with the subtasks that were deleted along with it and returns the todo.
+// @Tags		trash
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Produce		json
+// @Success		200	{object}	types.Todo
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Failure		409	{object}	types.APIError
+// @Router		/api/v1/todos/{id}/restore [post]
+// @Security	ApiKeyAuth
+func (h *TrashHandler) HandleRestoreTodo(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	todo, err := h.store.RestoreTodoByID(r.Context(), int64(id), user.ID)
+	if errors.Is(err, store.ErrTrashedParent) {
+		return types.NewAPIError(false, err, http.StatusConflict)
+	}
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, todo)
+}
+
+// @Summary		Delete a todo for good.
+// @Description	deletes a todo in the trash with its subtasks, it can't be restored afterwards.
+// @Tags		trash
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"Todo ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/trash/{id} [delete]
+// @Security	ApiKeyAuth
+func (h *TrashHandler) HandleDeleteTrashedTodo(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.DeleteTrashedTodoByID(r.Context(), int64(id), user.ID); err != nil {
+		return types.NewAPIError(false, err, http.StatusNotFound)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("todo ID: %d", id), http.StatusOK))
+}
+
+// @Summary		Empty the trash.
+// @Description	deletes every todo in the trash for good.
+// @Tags		trash
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		500	{object}	types.APIError
+// @Router		/api/v1/trash [delete]
+// @Security	ApiKeyAuth
+func (h *TrashHandler) HandleEmptyTrash(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	deleted, err := h.store.EmptyTrash(r.Context(), user.ID)
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("deleted %d todos", deleted), http.StatusOK))
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestTrash(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	_, token := testSuite.createUser(t)
+	_, otherToken := testSuite.createUser(t)
+	r := testSuite.router()
+
+	insert := func(params types.InsertTodoParams) *types.Todo {
+		t.Helper()
+		rr := do(t, r, http.MethodPost, "/todos", params, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v, got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var todo types.Todo
+		if err := json.NewDecoder(rr.Body).Decode(&todo); err != nil {
+			t.Fatal(err)
+		}
+		return &todo
+	}
+	expectStatus := func(method, target string, token string, code int) {
+		t.Helper()
+		if rr := do(t, r, method, target, nil, token); rr.Code != code {
+			t.Fatalf("%s %s: expected http status code %v, got %v (resp: %s)", method, target, code, rr.Code, rr.Body.String())
+		}
+	}
+	trash := func() []*types.Todo {
+		t.Helper()
+		rr := do(t, r, http.MethodGet, "/trash", nil, token)
+		var resp types.TrashGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Result
+	}
+
+	parent := insert(types.InsertTodoParams{Title: "Move house", Content: "Everything"})
+	child := insert(types.InsertTodoParams{Title: "Pack boxes", Content: "Kitchen first", ParentID: &parent.ID})
+	insert(types.InsertTodoParams{Title: "Water plants", Content: "Daily"})
+	parentTarget := fmt.Sprintf("/todos/%d", parent.ID)
+	childTarget := fmt.Sprintf("/todos/%d", child.ID)
+
+	t.Run("Delete", func(t *testing.T) {
+		expectStatus(http.MethodDelete, parentTarget, token, http.StatusOK)
+		expectStatus(http.MethodGet, parentTarget, token, http.StatusNotFound)
+		expectStatus(http.MethodGet, childTarget, token, http.StatusNotFound)
+		expectStatus(http.MethodDelete, parentTarget, token, http.StatusNotFound)
+
+		rr := do(t, r, http.MethodGet, "/todos", nil, token)
+		var page types.TodoGetAllResponse
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Count != 1 {
+			t.Errorf("expected the trashed todos to be left out, got %d todos", page.Count)
+		}
+		if todos := trash(); len(todos) != 1 || todos[0].ID != parent.ID || todos[0].DeletedAt == nil {
+			t.Errorf("expected only the parent in the trash, got %+v", todos)
+		}
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		expectStatus(http.MethodPost, childTarget+"/restore", token, http.StatusConflict)
+		expectStatus(http.MethodPost, parentTarget+"/restore", otherToken, http.StatusNotFound)
+		expectStatus(http.MethodPost, parentTarget+"/restore", token, http.StatusOK)
+		expectStatus(http.MethodGet, childTarget, token, http.StatusOK)
+		expectStatus(http.MethodPost, parentTarget+"/restore", token, http.StatusNotFound)
+
+		// A subtask trashed on its own stays in the trash when its parent is restored.
+		expectStatus(http.MethodDelete, childTarget, token, http.StatusOK)
+		expectStatus(http.MethodDelete, parentTarget, token, http.StatusOK)
+		expectStatus(http.MethodPost, parentTarget+"/restore", token, http.StatusOK)
+		if todos := trash(); len(todos) != 1 || todos[0].ID != child.ID {
+			t.Errorf("expected only the subtask in the trash, got %+v", todos)
+		}
+	})
+
+	t.Run("DeleteForGood", func(t *testing.T) {
+		target := fmt.Sprintf("/trash/%d", child.ID)
+		expectStatus(http.MethodDelete, fmt.Sprintf("/trash/%d", parent.ID), token, http.StatusNotFound)
+		expectStatus(http.MethodDelete, target, otherToken, http.StatusNotFound)
+		expectStatus(http.MethodDelete, target, token, http.StatusOK)
+		expectStatus(http.MethodPost, childTarget+"/restore", token, http.StatusNotFound)
+
+		expectStatus(http.MethodDelete, parentTarget, token, http.StatusOK)
+		expectStatus(http.MethodDelete, "/trash", token, http.StatusOK)
+		if todos := trash(); len(todos) != 0 {
+			t.Errorf("expected an empty trash, got %+v", todos)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		todo := insert(types.InsertTodoParams{Title: "Old news", Content: "Gone soon"})
+		expectStatus(http.MethodDelete, fmt.Sprintf("/todos/%d", todo.ID), token, http.StatusOK)
+
+		purged, err := testSuite.databaseStore.PurgeTrash(context.TODO(), time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged != 0 {
+			t.Errorf("expected nothing to be purged before the retention is over, got %d", purged)
+		}
+		purged, err = testSuite.databaseStore.PurgeTrash(context.TODO(), time.Now().Add(time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged != 1 || len(trash()) != 0 {
+			t.Errorf("expected the todo to be purged, got %d", purged)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/thimc/go-svelte-todo/backend/store"
@@ -14,26 +15,37 @@ import (
 )
 
 type UserHandler struct {
-	store store.UserStorer
+	store        store.UserStorer
+	sessionStore store.SessionStorer
+	passwords    *PasswordHandler
+	// how long deleted accounts can still be restored by logging in, zero deletes them right away
+	deletionGracePeriod time.Duration
 }
 
-func NewUserHandler(store store.UserStorer) *UserHandler {
+func NewUserHandler(store store.UserStorer, sessionStore store.SessionStorer, passwords *PasswordHandler, deletionGracePeriod time.Duration) *UserHandler {
 	return &UserHandler{
-		store: store,
+		store:               store,
+		sessionStore:        sessionStore,
+		passwords:           passwords,
+		deletionGracePeriod: deletionGracePeriod,
 	}
 }
 
 // @Summary		Get all users.
-// @Description	gets all users.
+// @Description	gets all users, only admins can list them.
 // @Tags		users
 // @Accept		json
 // @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
 // @Produce		json
 // @Success		200	{object}	[]types.User
+// @Failure		403	{object}	types.APIError
 // @Failure		404	{object}	types.APIError
 // @Router		/api/v1/users [get]
 // @Security	ApiKeyAuth
 func (h *UserHandler) HandleGetUsers(w http.ResponseWriter, r *http.Request) *types.APIError {
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
 	users, err := h.store.GetUsers(r.Context())
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusNotFound)
@@ -43,31 +55,201 @@ func (h *UserHandler) HandleGetUsers(w http.ResponseWriter, r *http.Request) *ty
 }
 
 // @Summary		Get user by ID.
-// @Description	fetch one user.
+// @Description	fetch one user, users can only fetch their own profile unless they are an admin.
 // @Tags		users
 // @Accept		json
 // @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
 // @Produce		json
 // @Success		200	{object}	types.User
 // @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
 // @Failure		404	{object}	types.APIError
 // @Router		/api/v1/users/{id} [get]
 // @Security	ApiKeyAuth
 func (h *UserHandler) HandleGetUserByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	current, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusBadRequest)
 	}
+	if id != current.ID && !current.Can(types.PermissionManageUsers) {
+		return types.NewAPIError(false, fmt.Errorf("users can only view their own profile"), http.StatusForbidden)
+	}
 	user, err := h.store.GetUserByID(r.Context(), int64(id))
 	if err != nil {
 		return types.NewAPIError(false, err, http.StatusNotFound)
 	}
+	user.EncryptedPassword = ""
 
 	return utils.ResponseWriteJSON(w, user)
 }
 
+// @Summary		Change the role of a user.
+// @Description	promotes a user to an admin or demotes an admin, admins can't change their own role.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"User ID"
+// @Param		params	body	types.UserRoleParams	true	"The new role"
+// @Produce		json
+// @Success		200	{object}	types.User
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/users/{id}/role [put]
+// @Security	ApiKeyAuth
+func (h *UserHandler) HandlePutUserRole(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := h.managedUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var params types.UserRoleParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.SetUserRoleByID(r.Context(), int64(user.ID), params.Role); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	user.Role = params.Role
+
+	return utils.ResponseWriteJSON(w, user)
+}
+
+// @Summary		Disable or enable a user.
+// @Description	disabled users can't log in and every session of theirs is signed out, admins can't disable themselves.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"User ID"
+// @Param		params	body	types.UserDisableParams	true	"Whether the user is disabled"
+// @Produce		json
+// @Success		200	{object}	types.User
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/users/{id}/disabled [put]
+// @Security	ApiKeyAuth
+func (h *UserHandler) HandlePutUserDisabled(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := h.managedUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	var params types.UserDisableParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := h.store.SetUserDisabledByID(r.Context(), int64(user.ID), params.Disabled); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if params.Disabled {
+		if err := h.sessionStore.RevokeUserSessions(r.Context(), user.ID, 0); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+	}
+	user.Disabled = params.Disabled
+
+	return utils.ResponseWriteJSON(w, user)
+}
+
+// @Summary		Reset the password of a user.
+// @Description	replaces the password of the user with a random one, signs out every session and mails the user a reset link.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"User ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/users/{id}/password/reset [post]
+// @Security	ApiKeyAuth
+func (h *UserHandler) HandleResetUserPassword(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := h.managedUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	password, err := types.NewRandomPassword()
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.store.UpdateUserPasswordByID(r.Context(), password, int64(user.ID)); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.sessionStore.RevokeUserSessions(r.Context(), user.ID, 0); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.passwords.sendResetLink(r.Context(), user, adminPasswordResetMail); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("a reset link has been sent to %s", user.Email), http.StatusOK))
+}
+
+// @Summary		Delete a user.
+// @Description	deletes the user with everything the user owns, admins can't delete themselves.
+// @Tags		users
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		id	path	int	true	"User ID"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Failure		404	{object}	types.APIError
+// @Router		/api/v1/users/{id} [delete]
+// @Security	ApiKeyAuth
+func (h *UserHandler) HandleDeleteUserByID(w http.ResponseWriter, r *http.Request) *types.APIError {
+	user, apiErr := h.managedUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	if err := h.store.DeleteUserByID(r.Context(), int64(user.ID)); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("user ID: %d", user.ID), http.StatusOK))
+}
+
+// managedUser returns the user in the URL that an admin manages. Admins can't
+// manage their own account, so there is always an admin left.
+func (h *UserHandler) managedUser(r *http.Request) (*types.User, *types.APIError) {
+	current, apiErr := contextUser(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return nil, apiErr
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	if id == current.ID {
+		return nil, types.NewAPIError(false, fmt.Errorf("admins can't manage their own account"), http.StatusBadRequest)
+	}
+	user, err := h.store.GetUserByID(r.Context(), int64(id))
+	if err != nil {
+		return nil, types.NewAPIError(false, err, http.StatusNotFound)
+	}
+	user.EncryptedPassword = ""
+
+	return user, nil
+}
+
 // @Summary		Update the password.
-// @Description	updates the users password.
+// @Description	updates the users password and signs out every other session of the user.
 // @Tags		users
 // @Accept		json
 // @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
@@ -75,11 +257,15 @@ func (h *UserHandler) HandleGetUserByID(w http.ResponseWriter, r *http.Request)
 // @Produce		json
 // @Success		200	{object}	nil
 // @Failure		400	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
 // @Router		/api/v1/user/password [put]
 // @Security	ApiKeyAuth
 func (h *UserHandler) HandlePutUserPassword(w http.ResponseWriter, r *http.Request) *types.APIError {
 	if user, ok := r.Context().Value("user").(*types.User); ok {
 		log.Printf("Hello %s\n", user.Email)
+		if apiErr := requireSession(r); apiErr != nil {
+			return apiErr
+		}
 
 		var params *types.UserPutPasswordParams
 		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
@@ -100,8 +286,73 @@ func (h *UserHandler) HandlePutUserPassword(w http.ResponseWriter, r *http.Reque
 			return types.NewAPIError(false, err, http.StatusBadRequest)
 		}
 
+		// Every other session that logged in with the old password is signed out.
+		if err := h.sessionStore.RevokeUserSessions(r.Context(), user.ID, contextSession(r)); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+
 		return nil
 	}
 
 	return utils.ResponseWriteJSON(w, types.NewAPIError(false, fmt.Errorf("unknown user"), http.StatusBadRequest))
 }
+
+// @Summary		Delete the account.
+// @Description	deletes the account of the user with every todo, tag and list, the password needs to be confirmed.
+// @Description	With a grace period the account is only marked for deletion and every session is signed out,
+// @Description	logging in again before the account is purged cancels the deletion.
+// @Tags		users
+// @Accept		json
+// @Param		Authorization	header	string	true	"JWT Token, needs to start with Bearer"
+// @Param		params	body	types.DeleteAccountParams	true	"The current password"
+// @Produce		json
+// @Success		200	{object}	types.DeleteAccountResponse
+// @Failure		400	{object}	types.APIError
+// @Failure		401	{object}	types.APIError
+// @Failure		403	{object}	types.APIError
+// @Router		/api/v1/user [delete]
+// @Security	ApiKeyAuth
+func (h *UserHandler) HandleDeleteUser(w http.ResponseWriter, r *http.Request) *types.APIError {
+	current, apiErr := contextUser(r)
+	if apiErr != nil {
+		return apiErr
+	}
+	if apiErr := requireSession(r); apiErr != nil {
+		return apiErr
+	}
+
+	var params types.DeleteAccountParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	// The user in the context has no password hash.
+	user, err := h.store.GetUserByID(r.Context(), int64(current.ID))
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := types.ValidPassword(user.EncryptedPassword, params.Password); err != nil {
+		return types.NewAPIError(false, fmt.Errorf("Invalid password"), http.StatusUnauthorized)
+	}
+
+	if h.deletionGracePeriod == 0 {
+		if err := h.store.DeleteUserByID(r.Context(), int64(user.ID)); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+		return utils.ResponseWriteJSON(w, types.DeleteAccountResponse{Deleted: true})
+	}
+
+	deleteAt := time.Now().Add(h.deletionGracePeriod).UTC()
+	if err := h.store.ScheduleUserDeletion(r.Context(), int64(user.ID), deleteAt); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+	if err := h.sessionStore.RevokeUserSessions(r.Context(), user.ID, 0); err != nil {
+		return types.NewAPIError(false, err, http.StatusInternalServerError)
+	}
+
+	return utils.ResponseWriteJSON(w, types.DeleteAccountResponse{DeleteAt: &deleteAt})
+}
@@ -9,7 +9,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/api/middleware"
 	"github.com/thimc/go-svelte-todo/backend/types"
 	"github.com/thimc/go-svelte-todo/backend/utils"
 )
@@ -18,7 +21,9 @@ func TestGetUsersSuccess(t *testing.T) {
 	testSuite := newTestSuite(t)
 	defer testSuite.Teardown(t)
 	loginHandler := http.HandlerFunc(utils.HandleAPIFunc(testSuite.authHandler.HandleLogin))
-	userHandler := http.HandlerFunc(utils.HandleAPIFunc(testSuite.userHandler.HandleGetUsers))
+	// The JWT middleware stores the session of the login in the request context.
+	jwt := middleware.NewJWTMiddleware(testSuite.userStore, testSuite.sessionStore, testSuite.accessTokenStore, testSuite.keys)
+	userHandler := jwt.Middleware(http.HandlerFunc(utils.HandleAPIFunc(testSuite.userHandler.HandleGetUsers)))
 
 	params := types.UserParams{
 		Email:    fmt.Sprintf("test%d@golangtest.com", rand.Intn(10000)),
@@ -97,3 +102,204 @@ func TestGetUsersSuccess(t *testing.T) {
 		t.Fatalf("expected the mock user to be removed, got %+v", removedUser)
 	}
 }
+
+func TestUserManagement(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	public := mux.NewRouter()
+	public.HandleFunc("/login", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
+
+	admin, adminToken := testSuite.createUser(t)
+	if err := testSuite.userStore.SetUserRoleByID(context.TODO(), int64(admin.ID), types.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	user, userToken := testSuite.createUser(t)
+
+	expect := func(method, target string, body any, token string, status int) {
+		t.Helper()
+		if rr := do(t, r, method, target, body, token); rr.Code != status {
+			t.Errorf("%s %s: expected http status code %v got %v (resp: %s)", method, target, status, rr.Code, rr.Body.String())
+		}
+	}
+	login := func(password string) int {
+		return do(t, public, http.MethodPost, "/login", types.UserParams{Email: user.Email, Password: password}, "").Code
+	}
+	userURL := fmt.Sprintf("/users/%d", user.ID)
+
+	t.Run("User", func(t *testing.T) {
+		expect(http.MethodGet, "/users", nil, userToken, http.StatusForbidden)
+		expect(http.MethodGet, fmt.Sprintf("/users/%d", admin.ID), nil, userToken, http.StatusForbidden)
+		expect(http.MethodGet, userURL, nil, userToken, http.StatusOK)
+		expect(http.MethodPut, fmt.Sprintf("/users/%d/role", admin.ID), types.UserRoleParams{Role: types.RoleUser}, userToken, http.StatusForbidden)
+		expect(http.MethodDelete, fmt.Sprintf("/users/%d", admin.ID), nil, userToken, http.StatusForbidden)
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		expect(http.MethodGet, "/users", nil, adminToken, http.StatusOK)
+		expect(http.MethodGet, userURL, nil, adminToken, http.StatusOK)
+		expect(http.MethodPut, fmt.Sprintf("/users/%d/role", admin.ID), types.UserRoleParams{Role: types.RoleUser}, adminToken, http.StatusBadRequest)
+		expect(http.MethodPut, userURL+"/role", types.UserRoleParams{Role: "root"}, adminToken, http.StatusBadRequest)
+	})
+
+	t.Run("AccessToken", func(t *testing.T) {
+		rr := do(t, r, http.MethodPost, "/user/tokens", types.AccessTokenParams{Name: "admin script", Scopes: []string{types.ScopeWrite}}, adminToken)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var created types.AccessTokenCreatedResponse
+		if err := json.NewDecoder(rr.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+
+		// Personal access tokens of admins can't manage users.
+		expect(http.MethodGet, "/users", nil, created.Token, http.StatusForbidden)
+		expect(http.MethodPut, userURL+"/role", types.UserRoleParams{Role: types.RoleAdmin}, created.Token, http.StatusForbidden)
+		expect(http.MethodPut, userURL+"/disabled", types.UserDisableParams{Disabled: true}, created.Token, http.StatusForbidden)
+		expect(http.MethodPost, userURL+"/password/reset", nil, created.Token, http.StatusForbidden)
+		expect(http.MethodDelete, userURL, nil, created.Token, http.StatusForbidden)
+	})
+
+	t.Run("Promote", func(t *testing.T) {
+		expect(http.MethodPut, userURL+"/role", types.UserRoleParams{Role: types.RoleAdmin}, adminToken, http.StatusOK)
+		expect(http.MethodGet, "/users", nil, userToken, http.StatusOK)
+		expect(http.MethodPut, userURL+"/role", types.UserRoleParams{Role: types.RoleUser}, adminToken, http.StatusOK)
+		expect(http.MethodGet, "/users", nil, userToken, http.StatusForbidden)
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		expect(http.MethodPut, userURL+"/disabled", types.UserDisableParams{Disabled: true}, adminToken, http.StatusOK)
+		expect(http.MethodGet, userURL, nil, userToken, http.StatusUnauthorized)
+		if code := login("secret-password"); code != http.StatusForbidden {
+			t.Errorf("expected the login of a disabled user to fail with %v, got %v", http.StatusForbidden, code)
+		}
+		expect(http.MethodPut, userURL+"/disabled", types.UserDisableParams{Disabled: false}, adminToken, http.StatusOK)
+		if code := login("secret-password"); code != http.StatusOK {
+			t.Errorf("expected the login of an enabled user to succeed, got %v", code)
+		}
+	})
+
+	t.Run("ResetPassword", func(t *testing.T) {
+		expect(http.MethodPost, userURL+"/password/reset", nil, adminToken, http.StatusOK)
+		if tokens := testSuite.resetTokens(t); len(tokens) != 1 {
+			t.Errorf("expected a reset link to be mailed, got %v", tokens)
+		}
+		if code := login("secret-password"); code != http.StatusUnauthorized {
+			t.Errorf("expected the old password to be replaced, got %v", code)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		expect(http.MethodDelete, fmt.Sprintf("/users/%d", admin.ID), nil, adminToken, http.StatusBadRequest)
+		expect(http.MethodDelete, userURL, nil, adminToken, http.StatusOK)
+		expect(http.MethodGet, userURL, nil, adminToken, http.StatusNotFound)
+	})
+}
+
+func TestDeleteAccount(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	r := testSuite.router()
+	public := mux.NewRouter()
+	public.HandleFunc("/login", utils.HandleAPIFunc(testSuite.authHandler.HandleLogin)).Methods(http.MethodPost)
+
+	user, token := testSuite.createUser(t)
+	todo, err := testSuite.databaseStore.InsertTodo(context.TODO(), types.NewTodoFromParams(types.InsertTodoParams{
+		Title:     "This is the title",
+		Content:   "This is the content",
+		CreatedBy: user.ID,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testSuite.tagStore.InsertTag(context.TODO(), types.NewTagFromParams(types.TagParams{Name: "work"}, user.ID)); err != nil {
+		t.Fatal(err)
+	}
+	login := func() int {
+		return do(t, public, http.MethodPost, "/login", types.UserParams{Email: user.Email, Password: "secret-password"}, "").Code
+	}
+
+	t.Run("WrongPassword", func(t *testing.T) {
+		rr := do(t, r, http.MethodDelete, "/user", types.DeleteAccountParams{Password: "wrong-password"}, token)
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusUnauthorized, rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		rr := do(t, r, http.MethodDelete, "/user", types.DeleteAccountParams{Password: "secret-password"}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		var resp types.DeleteAccountResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Deleted || resp.DeleteAt == nil {
+			t.Fatalf("expected the account to be marked for deletion, got %+v", resp)
+		}
+		if rr := do(t, r, http.MethodGet, "/todos", nil, token); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected the sessions to be signed out, got %v", rr.Code)
+		}
+
+		// Purging before the grace period is over keeps the account.
+		if purged, err := testSuite.userStore.PurgeDeletedUsers(context.TODO(), time.Now()); err != nil || purged != 0 {
+			t.Fatalf("expected nothing to be purged, got %d (%v)", purged, err)
+		}
+		if code := login(); code != http.StatusOK {
+			t.Fatalf("expected the login to cancel the deletion, got %v", code)
+		}
+		u, err := testSuite.userStore.GetUserByID(context.TODO(), int64(user.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.DeleteAt != nil {
+			t.Errorf("expected the deletion to be cancelled, got %v", u.DeleteAt)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		session, err := testSuite.sessionStore.CreateSession(context.TODO(), types.NewSession(user.ID, "go-test", "127.0.0.1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, token, err := testSuite.keys.CreateJWT(user, session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rr := do(t, r, http.MethodDelete, "/user", types.DeleteAccountParams{Password: "secret-password"}, token); rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		purged, err := testSuite.userStore.PurgeDeletedUsers(context.TODO(), time.Now().Add(types.DefaultDeletionGracePeriod+time.Minute))
+		if err != nil || purged != 1 {
+			t.Fatalf("expected the account to be purged, got %d (%v)", purged, err)
+		}
+		if _, err := testSuite.userStore.GetUserByID(context.TODO(), int64(user.ID)); err == nil {
+			t.Errorf("expected the user to be deleted")
+		}
+		if _, err := testSuite.databaseStore.GetTodoByID(context.TODO(), todo.ID, user.ID); err == nil {
+			t.Errorf("expected the todos of the user to be deleted")
+		}
+		if tags, err := testSuite.tagStore.GetTags(context.TODO(), user.ID); err != nil || len(tags) != 0 {
+			t.Errorf("expected the tags of the user to be deleted, got %v (%v)", tags, err)
+		}
+	})
+
+	t.Run("NoGracePeriod", func(t *testing.T) {
+		user, token := testSuite.createUser(t)
+		r := mux.NewRouter()
+		r.Use(middleware.NewJWTMiddleware(testSuite.userStore, testSuite.sessionStore, testSuite.accessTokenStore, testSuite.keys).Middleware)
+		userHandler := NewUserHandler(testSuite.userStore, testSuite.sessionStore, nil, 0)
+		r.HandleFunc("/user", utils.HandleAPIFunc(userHandler.HandleDeleteUser)).Methods(http.MethodDelete)
+
+		rr := do(t, r, http.MethodDelete, "/user", types.DeleteAccountParams{Password: "secret-password"}, token)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+		}
+		if _, err := testSuite.userStore.GetUserByID(context.TODO(), int64(user.ID)); err == nil {
+			t.Errorf("expected the user to be deleted right away")
+		}
+	})
+}
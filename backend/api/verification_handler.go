@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/mail"
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+const verificationMail = `Welcome! Please confirm that this is your email address by opening the
+link below within %d hours:
+
+%s
+
+If you didn't create an account, you can ignore this email.
+`
+
+type VerificationHandler struct {
+	store  store.UserStorer
+	mailer mail.Mailer
+	// The URL of the frontend that the verification links point to
+	appURL string
+	policy types.UnverifiedPolicy
+	// The HMAC key of the verification links
+	secret []byte
+	// the resent verification mails being sent in the background
+	mails *mailQueue
+}
+
+func NewVerificationHandler(store store.UserStorer, mailer mail.Mailer, appURL string, policy types.UnverifiedPolicy, secret string) *VerificationHandler {
+	return &VerificationHandler{
+		store:  store,
+		mailer: mailer,
+		appURL: strings.TrimSuffix(appURL, "/"),
+		policy: policy,
+		secret: []byte(secret),
+		mails:  newMailQueue(mailQueueSize),
+	}
+}
+
+// Wait blocks until the verification mails being resent in the background are done.
+func (h *VerificationHandler) Wait() {
+	h.mails.wait()
+}
+
+// @Summary		Verify the email address.
+// @Description	marks the email address of the user as verified with the token from a verification link.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.VerifyEmailParams	true	"The token"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Router		/api/verify-email [post]
+func (h *VerificationHandler) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.VerifyEmailParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	user, err := h.verificationUser(r.Context(), params.Token, time.Now())
+	if err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+	if !user.Verified {
+		if err := h.store.SetUserVerifiedByID(r.Context(), int64(user.ID)); err != nil {
+			return types.NewAPIError(false, err, http.StatusInternalServerError)
+		}
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("the email address has been verified"), http.StatusOK))
+}
+
+// @Summary		Resend the verification mail.
+// @Description	sends a new verification link if the email address belongs to an unverified account, at most once every 5 minutes.
+// @Description	The response is the same whether the account exists or not.
+// @Tags		auth
+// @Accept		json
+// @Param		params	body	types.ResendVerificationParams	true	"The email address"
+// @Produce		json
+// @Success		200	{object}	types.APIError
+// @Failure		400	{object}	types.APIError
+// @Router		/api/verify-email/resend [post]
+func (h *VerificationHandler) HandleResendVerification(w http.ResponseWriter, r *http.Request) *types.APIError {
+	var params types.ResendVerificationParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	if err := params.Validate(); err != nil {
+		return types.NewAPIError(false, err, http.StatusBadRequest)
+	}
+
+	// The account is looked up in the background, so the response takes as
+	// long whether the account exists or not.
+	email := params.Email
+	if !h.mails.run(func(ctx context.Context) { h.resendVerification(ctx, email) }) {
+		log.Printf("too many verification mails are being sent, dropping the one to %s", email)
+	}
+
+	return utils.ResponseWriteJSON(w, types.NewAPIError(true, fmt.Errorf("a verification link has been sent if the email belongs to an unverified account"), http.StatusOK))
+}
+
+// resendVerification sends a verification link if the email belongs to an
+// unverified account. The request is gone by then.
+func (h *VerificationHandler) resendVerification(ctx context.Context, email string) {
+	user, err := h.store.GetUserByEmail(ctx, email)
+	if err != nil || user.Verified {
+		return
+	}
+	if err := h.SendVerificationMail(ctx, user); err != nil {
+		log.Printf("error when sending the verification mail: %s", err)
+	}
+}
+
+// SendVerificationMail sends a verification link to the user unless one was sent
+// within the last types.VerificationMailInterval.
+func (h *VerificationHandler) SendVerificationMail(ctx context.Context, user *types.User) error {
+	ok, err := h.store.MarkVerificationSent(ctx, int64(user.ID), types.VerificationMailInterval)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	token := h.signVerificationToken(user, time.Now().Add(types.EmailVerificationTTL))
+	link := fmt.Sprintf("%s/verify-email?token=%s", h.appURL, url.QueryEscape(token))
+	return h.mailer.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body:    fmt.Sprintf(verificationMail, int(types.EmailVerificationTTL.Hours()), link),
+	})
+}
+
+// checkLogin returns an error if the policy doesn't let the user log in.
+func (h *VerificationHandler) checkLogin(user *types.User) *types.APIError {
+	if !user.Verified && h.policy == types.UnverifiedBlock {
+		return types.NewAPIError(false, fmt.Errorf("the email address needs to be verified before logging in"), http.StatusForbidden)
+	}
+	return nil
+}
+
+// verificationUser returns the user of a verification token that hasn't expired.
+func (h *VerificationHandler) verificationUser(ctx context.Context, token string, now time.Time) (*types.User, error) {
+	invalid := fmt.Errorf("the verification link is invalid or has expired")
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, invalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return nil, invalid
+	}
+
+	user, err := h.store.GetUserByID(ctx, int64(userID))
+	if err != nil {
+		return nil, invalid
+	}
+	// The signature covers the email address, so links stop working if it changes.
+	if !hmac.Equal([]byte(token), []byte(h.signVerificationToken(user, time.Unix(expires, 0)))) {
+		return nil, invalid
+	}
+
+	return user, nil
+}
+
+// signVerificationToken returns "<user ID>.<expiry>.<signature>", signed with the verification secret.
+func (h *VerificationHandler) signVerificationToken(user *types.User, expires time.Time) string {
+	payload := fmt.Sprintf("%d.%d", user.ID, expires.Unix())
+	mac := hmac.New(sha256.New, h.secret)
+	fmt.Fprintf(mac, "verify-email\x00%s\x00%s", payload, user.Email)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/thimc/go-svelte-todo/backend/api/middleware"
+	"github.com/thimc/go-svelte-todo/backend/types"
+	"github.com/thimc/go-svelte-todo/backend/utils"
+)
+
+var verificationLinkRegex = regexp.MustCompile(`/verify-email\?token=([A-Za-z0-9_.\-]+)`)
+
+// verificationTokens returns the tokens of the verification links that were mailed so far.
+func (s *testSuite) verificationTokens(t *testing.T) []string {
+	b, err := os.ReadFile(s.mailFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := []string{}
+	for _, match := range verificationLinkRegex.FindAllStringSubmatch(string(b), -1) {
+		tokens = append(tokens, match[1])
+	}
+	return tokens
+}
+
+func TestEmailVerification(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	// The login is blocked for unverified users.
+	verification := NewVerificationHandler(testSuite.userStore, testSuite.verificationHandler.mailer, "http://localhost:5173", types.UnverifiedBlock, "test-secret")
+	authHandler := NewAuthHandler(testSuite.userStore, testSuite.listStore, testSuite.tokenStore, testSuite.sessionStore, testSuite.mfaStore, verification, testSuite.authEventHandler, testSuite.keys)
+
+	public := mux.NewRouter()
+	public.HandleFunc("/register", utils.HandleAPIFunc(authHandler.HandleRegister)).Methods(http.MethodPost)
+	public.HandleFunc("/login", utils.HandleAPIFunc(authHandler.HandleLogin)).Methods(http.MethodPost)
+	public.HandleFunc("/verify-email", utils.HandleAPIFunc(verification.HandleVerifyEmail)).Methods(http.MethodPost)
+	public.HandleFunc("/verify-email/resend", utils.HandleAPIFunc(verification.HandleResendVerification)).Methods(http.MethodPost)
+
+	params := types.UserParams{
+		Email:    fmt.Sprintf("test%d@golangtest.com", rand.Intn(1000000)),
+		Password: "secret-password",
+	}
+	rr := do(t, public, http.MethodPost, "/register", params, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var user types.User
+	if err := json.NewDecoder(rr.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := testSuite.userStore.DeleteUserByID(context.TODO(), int64(user.ID)); err != nil {
+			t.Errorf("error when removing mock user: %s", err)
+		}
+	})
+	if user.Verified {
+		t.Fatalf("expected a new user to be unverified, got %+v", user)
+	}
+
+	if rr := do(t, public, http.MethodPost, "/login", params, ""); rr.Code != http.StatusForbidden {
+		t.Errorf("expected http status code %v got %v", http.StatusForbidden, rr.Code)
+	}
+
+	// The resend is throttled.
+	if rr := do(t, public, http.MethodPost, "/verify-email/resend", types.ResendVerificationParams{Email: params.Email}, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected http status code %v got %v", http.StatusOK, rr.Code)
+	}
+	verification.Wait()
+	tokens := testSuite.verificationTokens(t)
+	if len(tokens) != 1 {
+		t.Fatalf("expected a single verification mail, got %v", tokens)
+	}
+
+	verify := func(token string, status int) {
+		rr := do(t, public, http.MethodPost, "/verify-email", types.VerifyEmailParams{Token: token}, "")
+		if rr.Code != status {
+			t.Fatalf("expected http status code %v got %v (resp: %s)", status, rr.Code, rr.Body.String())
+		}
+	}
+	verify("unknown", http.StatusBadRequest)
+	_, rest, _ := strings.Cut(tokens[0], ".")
+	verify(fmt.Sprintf("%d.%s", user.ID+1, rest), http.StatusBadRequest)
+	verify(tokens[0]+"x", http.StatusBadRequest)
+	verify(tokens[0], http.StatusOK)
+
+	verified, err := testSuite.userStore.GetUserByID(context.TODO(), int64(user.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verified.Verified {
+		t.Errorf("expected the user to be verified, got %+v", verified)
+	}
+	if rr := do(t, public, http.MethodPost, "/login", params, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	// Verified users get no more links.
+	if rr := do(t, public, http.MethodPost, "/verify-email/resend", types.ResendVerificationParams{Email: params.Email}, ""); rr.Code != http.StatusOK {
+		t.Errorf("expected http status code %v got %v", http.StatusOK, rr.Code)
+	}
+	verification.Wait()
+	if tokens := testSuite.verificationTokens(t); len(tokens) != 1 {
+		t.Errorf("expected no further verification mail, got %v", tokens)
+	}
+}
+
+func TestUnverifiedReadOnly(t *testing.T) {
+	testSuite := newTestSuite(t)
+	defer testSuite.Teardown(t)
+
+	jwt := middleware.NewJWTMiddleware(testSuite.userStore, testSuite.sessionStore, testSuite.accessTokenStore, testSuite.keys)
+	verified := middleware.NewVerifiedMiddleware(types.UnverifiedReadOnly)
+	r := mux.NewRouter()
+	r.Use(jwt.Middleware, verified.Middleware)
+	r.HandleFunc("/todos", utils.HandleAPIFunc(testSuite.todoHandler.HandleGetTodos)).Methods(http.MethodGet)
+	r.HandleFunc("/todos", utils.HandleAPIFunc(testSuite.todoHandler.HandleInsertTodo)).Methods(http.MethodPost)
+
+	user, token := testSuite.createUser(t)
+	todo := types.InsertTodoParams{Title: "Unverified", Content: "not allowed yet"}
+
+	if rr := do(t, r, http.MethodGet, "/todos", nil, token); rr.Code != http.StatusOK {
+		t.Errorf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if rr := do(t, r, http.MethodPost, "/todos", todo, token); rr.Code != http.StatusForbidden {
+		t.Errorf("expected http status code %v got %v", http.StatusForbidden, rr.Code)
+	}
+
+	if err := testSuite.userStore.SetUserVerifiedByID(context.TODO(), int64(user.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if rr := do(t, r, http.MethodPost, "/todos", todo, token); rr.Code != http.StatusOK {
+		t.Errorf("expected http status code %v got %v (resp: %s)", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,217 @@
+// Package export writes the personal data of a user as a ZIP file: everything
+// in data.json and the todos rendered as todos.csv and todos.md for people.
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// The files in the ZIP file.
+const (
+	DataFile     = "data.json"
+	CSVFile      = "todos.csv"
+	MarkdownFile = "todos.md"
+)
+
+// Dates in the CSV and Markdown files.
+const dateLayout = "2006-01-02 15:04 MST"
+
+// Filename returns the name the ZIP file is downloaded as.
+func Filename(exportedAt time.Time) string {
+	return fmt.Sprintf("todo-export-%s.zip", exportedAt.UTC().Format("2006-01-02"))
+}
+
+// Write writes the ZIP file of the data to w.
+func Write(w io.Writer, data *types.ExportData) error {
+	z := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer, *types.ExportData) error
+	}{
+		{DataFile, writeJSON},
+		{CSVFile, writeCSV},
+		{MarkdownFile, writeMarkdown},
+	}
+	for _, file := range files {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err != nil {
+			return err
+		}
+		if err := file.write(f, data); err != nil {
+			return fmt.Errorf("%s: %w", file.name, err)
+		}
+	}
+
+	return z.Close()
+}
+
+func writeJSON(w io.Writer, data *types.ExportData) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+func writeCSV(w io.Writer, data *types.ExportData) error {
+	lists := listNames(data.Lists)
+
+	c := csv.NewWriter(w)
+	c.Write([]string{"id", "title", "content", "done", "priority", "due_at", "list", "tags", "parent_id",
+		"checklist", "recurrence", "created", "updated"})
+	for _, todo := range data.Todos {
+		checklist := []string{}
+		for _, item := range todo.Checklist {
+			checklist = append(checklist, checkbox(item.Done)+" "+item.Title)
+		}
+		c.Write([]string{
+			strconv.FormatInt(todo.ID, 10),
+			todo.Title,
+			todo.Content,
+			strconv.FormatBool(todo.Done),
+			todo.Priority,
+			formatTime(todo.DueAt),
+			lists[idOrZero(todo.ListID)],
+			strings.Join(tagNames(todo.Tags), ", "),
+			formatID(todo.ParentID),
+			strings.Join(checklist, "; "),
+			todo.Recurrence,
+			formatTime(&todo.Created),
+			formatTime(todo.Updated),
+		})
+	}
+	c.Flush()
+
+	return c.Error()
+}
+
+// writeMarkdown renders the todos as task lists, one section per list with the
+// subtasks nested under their todo.
+func writeMarkdown(w io.Writer, data *types.ExportData) error {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "# Todos of %s\n\nExported on %s.\n", data.User.Email, data.ExportedAt.UTC().Format(dateLayout))
+
+	lists := listNames(data.Lists)
+	known := map[int64]bool{}
+	for _, todo := range data.Todos {
+		known[todo.ID] = true
+	}
+	subtasks := map[int64][]*types.Todo{}
+	// Todos without a list are kept under the ID 0.
+	byList := map[int64][]*types.Todo{}
+	for _, todo := range data.Todos {
+		if todo.ParentID != nil && known[*todo.ParentID] {
+			subtasks[*todo.ParentID] = append(subtasks[*todo.ParentID], todo)
+			continue
+		}
+		listID := idOrZero(todo.ListID)
+		if _, ok := lists[listID]; !ok {
+			listID = 0
+		}
+		byList[listID] = append(byList[listID], todo)
+	}
+
+	sections := append(append([]*types.List{}, data.Lists...), &types.List{Name: "Without a list"})
+	for _, list := range sections {
+		todos := byList[list.ID]
+		if len(todos) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "\n## %s\n", list.Name)
+		if list.Description != "" {
+			fmt.Fprintf(b, "\n%s\n", list.Description)
+		}
+		b.WriteString("\n")
+		for _, todo := range todos {
+			writeMarkdownTodo(b, todo, subtasks, 0)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownTodo(b *strings.Builder, todo *types.Todo, subtasks map[int64][]*types.Todo, depth int) {
+	indent := strings.Repeat("  ", depth)
+	details := []string{}
+	if todo.DueAt != nil {
+		details = append(details, "due "+formatTime(todo.DueAt))
+	}
+	if todo.Priority != "" && todo.Priority != types.PriorityNormal {
+		details = append(details, todo.Priority+" priority")
+	}
+	if todo.Recurrence != "" {
+		details = append(details, "repeats "+todo.Recurrence)
+	}
+	for _, name := range tagNames(todo.Tags) {
+		details = append(details, "#"+name)
+	}
+
+	fmt.Fprintf(b, "%s- %s %s", indent, checkbox(todo.Done), todo.Title)
+	if len(details) > 0 {
+		fmt.Fprintf(b, " (%s)", strings.Join(details, ", "))
+	}
+	b.WriteString("\n")
+	if todo.Content != "" {
+		for _, line := range strings.Split(todo.Content, "\n") {
+			fmt.Fprintf(b, "%s  > %s\n", indent, line)
+		}
+	}
+	for _, item := range todo.Checklist {
+		fmt.Fprintf(b, "%s  - %s %s\n", indent, checkbox(item.Done), item.Title)
+	}
+	for _, subtask := range subtasks[todo.ID] {
+		writeMarkdownTodo(b, subtask, subtasks, depth+1)
+	}
+}
+
+func checkbox(done bool) string {
+	if done {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+func listNames(lists []*types.List) map[int64]string {
+	names := map[int64]string{}
+	for _, list := range lists {
+		names[list.ID] = list.Name
+	}
+	return names
+}
+
+func tagNames(tags []*types.Tag) []string {
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+func idOrZero(id *int64) int64 {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func formatID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(dateLayout)
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2023, 7, 14, 18, 0, 0, 0, time.UTC)
+	listID, parentID := int64(1), int64(10)
+	data := &types.ExportData{
+		ExportedAt: now,
+		User:       &types.User{ID: 1, Email: "user@domain.com", Role: types.RoleUser},
+		Lists:      []*types.List{{ID: listID, Name: "Inbox", Inbox: true}},
+		Tags:       []*types.Tag{{ID: 1, Name: "work"}},
+		Todos: []*types.Todo{
+			{ID: parentID, Title: "Write the report", Content: "First line\nSecond line", Created: now, ListID: &listID,
+				Priority: types.PriorityHigh, Tags: []*types.Tag{{ID: 1, Name: "work"}},
+				Checklist: []*types.ChecklistItem{{ID: 1, TodoID: parentID, Title: "Outline", Done: true}}},
+			{ID: 11, Title: "Find the numbers", Created: now, ListID: &listID, ParentID: &parentID, Priority: types.PriorityNormal},
+			{ID: 12, Title: "Loose todo, \"quoted\"", Created: now, Done: true, Priority: types.PriorityNormal},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(b)
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		var decoded types.ExportData
+		if err := json.Unmarshal([]byte(files[DataFile]), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.User.Email != "user@domain.com" || len(decoded.Todos) != 3 || len(decoded.Lists) != 1 {
+			t.Errorf("expected the data to round trip, got %+v", decoded)
+		}
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		records, err := csv.NewReader(strings.NewReader(files[CSVFile])).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 4 {
+			t.Fatalf("expected a header and 3 todos, got %d records", len(records))
+		}
+		first := records[1]
+		if first[1] != "Write the report" || first[2] != "First line\nSecond line" || first[6] != "Inbox" ||
+			first[7] != "work" || first[9] != "[x] Outline" {
+			t.Errorf("unexpected record %q", first)
+		}
+		if records[2][8] != "10" || records[3][1] != "Loose todo, \"quoted\"" {
+			t.Errorf("unexpected records %q and %q", records[2], records[3])
+		}
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		expected := `# Todos of user@domain.com
+
+Exported on 2023-07-14 18:00 UTC.
+
+## Inbox
+
+- [ ] Write the report (high priority, #work)
+  > First line
+  > Second line
+  - [x] Outline
+  - [ ] Find the numbers
+
+## Without a list
+
+- [x] Loose todo, "quoted"
+`
+		if files[MarkdownFile] != expected {
+			t.Errorf("expected\n%s\ngot\n%s", expected, files[MarkdownFile])
+		}
+	})
+}
+
+func TestFilename(t *testing.T) {
+	if name := Filename(time.Date(2023, 7, 14, 23, 0, 0, 0, time.FixedZone("CEST", -2*60*60))); name != "todo-export-2023-07-15.zip" {
+		t.Errorf("expected the UTC date in the name, got %s", name)
+	}
+}
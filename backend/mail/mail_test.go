@@ -0,0 +1,147 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpCatcher accepts a single SMTP session and returns the sender, recipients and data.
+func smtpCatcher(t *testing.T) (string, <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	caught := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "MAIL", "RCPT":
+				lines = append(lines, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if data == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(data, "\r\n"))
+				}
+				reply("250 OK")
+			case "QUIT":
+				reply("221 Bye")
+				caught <- lines
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return l.Addr().String(), caught
+}
+
+func TestSMTPMailer(t *testing.T) {
+	addr, caught := smtpCatcher(t)
+	mailer := NewSMTPMailer(addr, "", "", "todo@localhost")
+
+	err := mailer.Send(context.TODO(), &Message{
+		To:      "user@domain.com",
+		Subject: "Hello\r\nBcc: someone@domain.com",
+		Body:    "first line\nsecond line",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := <-caught
+	session := strings.Join(lines, "\n")
+	for _, want := range []string{"MAIL FROM:<todo@localhost>", "RCPT TO:<user@domain.com>", "Subject: HelloBcc: someone@domain.com", "first line\nsecond line"} {
+		if !strings.Contains(session, want) {
+			t.Errorf("expected the session to contain %q, got:\n%s", want, session)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("expected the subject not to add a header, got %q", line)
+		}
+	}
+}
+
+func TestSMTPMailerTimeout(t *testing.T) {
+	// The server accepts the connection but never greets.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = NewSMTPMailer(l.Addr().String(), "", "", "todo@localhost").Send(ctx, &Message{To: "user@domain.com", Subject: "Hello", Body: "Hello"})
+	if err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the send to give up with the context, took %s", elapsed)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	mailer := NewFileMailer(path, "todo@localhost")
+
+	for _, to := range []string{"first@domain.com", "second@domain.com"} {
+		if err := mailer.Send(context.TODO(), &Message{To: to, Subject: "Test", Body: "body"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: first@domain.com\r\n", "To: second@domain.com\r\n", "From: todo@localhost\r\n"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected the file to contain %q, got:\n%s", want, b)
+		}
+	}
+}
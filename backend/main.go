@@ -1,22 +1,34 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/thimc/go-svelte-todo/backend/api"
 	"github.com/thimc/go-svelte-todo/backend/api/middleware"
+	"github.com/thimc/go-svelte-todo/backend/mail"
+	"github.com/thimc/go-svelte-todo/backend/oidc"
 	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
 	"github.com/thimc/go-svelte-todo/backend/utils"
 
 	swagger "github.com/swaggo/http-swagger/v2"
 	_ "github.com/thimc/go-svelte-todo/backend/docs"
 )
 
+// The requests that are running when the API is stopped get this long to finish.
+const shutdownTimeout = 10 * time.Second
+
 // @title			Backend
 // @description		Go backend API using Gorilla Mux and PostgreSQL
 // @contact.name	Thim Cederlund
@@ -24,9 +36,21 @@ import (
 // @host			localhost:1234
 // @BasePath		/
 func main() {
-	if err := godotenv.Load(); err != nil {
+	if err := godotenv.Load(); err != nil && os.Getenv("STORE") != "memory" {
 		log.Fatal(err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "promote" {
+		if err := runPromote(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	listenAddr := os.Getenv("LISTEN_ADDRESS")
 
 	r := mux.NewRouter()
@@ -40,40 +64,163 @@ func main() {
 	log.Printf("Serving swagger docs on http://localhost%s/swagger/\n", listenAddr)
 
 	// stores
-	log.Printf("Connecting to the database..")
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		os.Getenv("PSQL_HOST"),
-		os.Getenv("PSQL_PORT"),
-		os.Getenv("PSQL_USERNAME"),
-		os.Getenv("PSQL_PASSWORD"),
-		os.Getenv("PSQL_DATABASE"),
-		os.Getenv("PSQL_SSL"))
-	databaseStore, err := store.NewPostgreTodoStore(connStr)
+	var (
+		databaseStore    store.TodoStorer
+		userStore        store.UserStorer
+		tagStore         store.TagStorer
+		listStore        store.ListStorer
+		checklistStore   store.ChecklistStorer
+		revisionStore    store.TodoRevisionStorer
+		tokenStore       store.TokenStorer
+		sessionStore     store.SessionStorer
+		accessTokenStore store.AccessTokenStorer
+		mfaStore         store.MFAStorer
+		authEventStore   store.AuthEventStorer
+		identityStore    store.IdentityStorer
+		exportStore      store.ExportStorer
+	)
+	switch driver := os.Getenv("STORE"); driver {
+	case "memory":
+		log.Printf("Using the in-memory store, nothing will be persisted..")
+		memoryStore := store.NewMemoryTodoStore()
+		databaseStore = memoryStore
+		userStore = store.NewMemoryUserStore(memoryStore)
+		tagStore = store.NewMemoryTagStore(memoryStore)
+		listStore = store.NewMemoryListStore(memoryStore)
+		checklistStore = store.NewMemoryChecklistStore(memoryStore)
+		revisionStore = store.NewMemoryRevisionStore(memoryStore)
+		tokenStore = store.NewMemoryTokenStore(memoryStore)
+		sessionStore = store.NewMemorySessionStore(memoryStore)
+		accessTokenStore = store.NewMemoryAccessTokenStore(memoryStore)
+		mfaStore = store.NewMemoryMFAStore(memoryStore)
+		authEventStore = store.NewMemoryAuthEventStore(memoryStore)
+		identityStore = store.NewMemoryIdentityStore(memoryStore)
+		exportStore = store.NewMemoryExportStore(memoryStore)
+	case "", "postgres":
+		postgreStore, err := newPostgreStore()
+		if err != nil {
+			log.Fatal(err)
+		}
+		migrator, err := store.NewMigrator(postgreStore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatalf("Refusing to serve: %s", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %d_%s", m.Version, m.Name)
+		}
+		databaseStore = postgreStore
+		userStore = store.NewPostgreUserStore(postgreStore)
+		tagStore = store.NewPostgreTagStore(postgreStore)
+		listStore = store.NewPostgreListStore(postgreStore)
+		checklistStore = store.NewPostgreChecklistStore(postgreStore)
+		revisionStore = store.NewPostgreRevisionStore(postgreStore)
+		tokenStore = store.NewPostgreTokenStore(postgreStore)
+		sessionStore = store.NewPostgreSessionStore(postgreStore)
+		accessTokenStore = store.NewPostgreAccessTokenStore(postgreStore)
+		mfaStore = store.NewPostgreMFAStore(postgreStore)
+		authEventStore = store.NewPostgreAuthEventStore(postgreStore)
+		identityStore = store.NewPostgreIdentityStore(postgreStore)
+		exportStore = store.NewPostgreExportStore(postgreStore)
+	default:
+		log.Fatalf("unknown STORE %q, expected \"postgres\" or \"memory\"", driver)
+	}
+	defer databaseStore.Close()
+
+	mailer, err := mail.NewMailerFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer databaseStore.Close()
-	userStore, err := store.NewPostgreUserStore(databaseStore)
+	appURL := os.Getenv("APP_URL")
+	if appURL == "" {
+		appURL = "http://localhost:5173"
+	}
+	unverifiedPolicy, err := types.ParseUnverifiedPolicy(os.Getenv("UNVERIFIED_POLICY"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Anybody could sign verification links with an empty key. The in-memory
+	// store forgets the users on exit anyway, so a temporary key does there.
+	verificationSecret := os.Getenv("VERIFICATION_SECRET")
+	if verificationSecret == "" && os.Getenv("STORE") != "memory" {
+		log.Fatal("VERIFICATION_SECRET needs to be set to sign the email verification links")
+	}
+	if verificationSecret == "" {
+		log.Printf("VERIFICATION_SECRET is not set, signing the verification links with a temporary key")
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatal(err)
+		}
+		verificationSecret = hex.EncodeToString(b)
+	}
+	deletionGracePeriod, err := types.ParseDeletionGracePeriod(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	trashRetention, err := types.ParseTrashRetention(os.Getenv("TRASH_RETENTION"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The providers redirect back to the frontend, which completes the login.
+	providers, err := oidc.ProvidersFromEnv(appURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	trustedProxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	r.Use(middleware.NewProxyMiddleware(trustedProxies).Middleware)
+	keys, err := middleware.KeySetFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	// handlers
 	todoHandler := api.NewTodoHandler(databaseStore)
-	authHandler := api.NewAuthHandler(userStore)
-	userHandler := api.NewUserHandler(userStore)
+	trashHandler := api.NewTrashHandler(databaseStore)
+	authEventHandler := api.NewAuthEventHandler(authEventStore)
+	verificationHandler := api.NewVerificationHandler(userStore, mailer, appURL, unverifiedPolicy, verificationSecret)
+	authHandler := api.NewAuthHandler(userStore, listStore, tokenStore, sessionStore, mfaStore, verificationHandler, authEventHandler, keys)
+	tagHandler := api.NewTagHandler(tagStore)
+	listHandler := api.NewListHandler(listStore, databaseStore)
+	checklistHandler := api.NewChecklistHandler(checklistStore)
+	revisionHandler := api.NewRevisionHandler(revisionStore, databaseStore)
+	sessionHandler := api.NewSessionHandler(sessionStore)
+	accessTokenHandler := api.NewAccessTokenHandler(accessTokenStore)
+	passwordHandler := api.NewPasswordHandler(userStore, tokenStore, sessionStore, mailer, appURL)
+	userHandler := api.NewUserHandler(userStore, sessionStore, passwordHandler, deletionGracePeriod)
+	mfaHandler := api.NewMFAHandler(mfaStore)
+	exportHandler := api.NewExportHandler(exportStore, userStore, databaseStore, listStore, tagStore, sessionStore, accessTokenStore, identityStore, authEventStore, mfaStore)
+	oauthHandler := api.NewOAuthHandler(providers, userStore, identityStore, accessTokenStore, authHandler)
+	jwksHandler := api.NewJWKSHandler(keys)
 
 	// routes
+	r.HandleFunc("/.well-known/jwks.json", utils.HandleAPIFunc(jwksHandler.HandleGetJWKS)).Methods(http.MethodGet)
 	route := r.PathPrefix("/api").Subrouter()
 	v1 := route.PathPrefix("/v1").Subrouter()
 
 	// middleware
-	jwt := middleware.NewJWTMiddleware(userStore)
-	v1.Use(jwt.Middleware)
+	jwt := middleware.NewJWTMiddleware(userStore, sessionStore, accessTokenStore, keys)
+	verified := middleware.NewVerifiedMiddleware(unverifiedPolicy)
+	v1.Use(jwt.Middleware, verified.Middleware)
 
 	route.HandleFunc("/health", utils.HandleAPIFunc(api.HandleHealthCheck)).Methods(http.MethodGet)
 	route.HandleFunc("/register", utils.HandleAPIFunc(authHandler.HandleRegister)).Methods(http.MethodPost)
 	route.HandleFunc("/login", utils.HandleAPIFunc(authHandler.HandleLogin)).Methods(http.MethodPost)
+	route.HandleFunc("/login/mfa", utils.HandleAPIFunc(authHandler.HandleLoginMFA)).Methods(http.MethodPost)
+	route.HandleFunc("/logout", utils.HandleAPIFunc(authHandler.HandleLogout)).Methods(http.MethodPost)
+	route.HandleFunc("/token/refresh", utils.HandleAPIFunc(authHandler.HandleRefreshToken)).Methods(http.MethodPost)
+	route.HandleFunc("/oauth/providers", utils.HandleAPIFunc(oauthHandler.HandleGetProviders)).Methods(http.MethodGet)
+	route.HandleFunc("/oauth/{provider}/start", utils.HandleAPIFunc(oauthHandler.HandleOAuthStart)).Methods(http.MethodGet)
+	route.HandleFunc("/oauth/{provider}/callback", utils.HandleAPIFunc(oauthHandler.HandleOAuthCallback)).Methods(http.MethodPost)
+	route.HandleFunc("/password/forgot", utils.HandleAPIFunc(passwordHandler.HandleForgotPassword)).Methods(http.MethodPost)
+	route.HandleFunc("/password/reset", utils.HandleAPIFunc(passwordHandler.HandleResetPassword)).Methods(http.MethodPost)
+	route.HandleFunc("/verify-email", utils.HandleAPIFunc(verificationHandler.HandleVerifyEmail)).Methods(http.MethodPost)
+	route.HandleFunc("/verify-email/resend", utils.HandleAPIFunc(verificationHandler.HandleResendVerification)).Methods(http.MethodPost)
 
 	proute := route.PathPrefix("/").Subrouter()
 	proute.Use(jwt.Middleware)
@@ -82,17 +229,99 @@ func main() {
 	// todo
 	v1.HandleFunc("/todos", utils.HandleAPIFunc(todoHandler.HandleGetTodos)).Methods(http.MethodGet)
 	v1.HandleFunc("/todos", utils.HandleAPIFunc(todoHandler.HandleInsertTodo)).Methods(http.MethodPost)
+	v1.HandleFunc("/todos/search", utils.HandleAPIFunc(todoHandler.HandleSearchTodos)).Methods(http.MethodGet)
 	v1.HandleFunc("/todos/{id}", utils.HandleAPIFunc(todoHandler.HandlePutTodo)).Methods(http.MethodPut)
 	v1.HandleFunc("/todos/{id}", utils.HandleAPIFunc(todoHandler.HandleGetTodoByID)).Methods(http.MethodGet)
 	v1.HandleFunc("/todos/{id}", utils.HandleAPIFunc(todoHandler.HandlePatchTodoByID)).Methods(http.MethodPatch)
 	v1.HandleFunc("/todos/{id}", utils.HandleAPIFunc(todoHandler.HandleDeleteTodoByID)).Methods(http.MethodDelete)
+	v1.HandleFunc("/todos/{id}/subtasks", utils.HandleAPIFunc(todoHandler.HandleGetSubtasks)).Methods(http.MethodGet)
+	v1.HandleFunc("/todos/{id}/occurrences", utils.HandleAPIFunc(todoHandler.HandleGetOccurrences)).Methods(http.MethodGet)
+	v1.HandleFunc("/todos/{id}/history", utils.HandleAPIFunc(revisionHandler.HandleGetHistory)).Methods(http.MethodGet)
+	v1.HandleFunc("/todos/{id}/history/{revisionId}/revert", utils.HandleAPIFunc(revisionHandler.HandleRevertTodo)).Methods(http.MethodPost)
+	v1.HandleFunc("/todos/{id}/restore", utils.HandleAPIFunc(trashHandler.HandleRestoreTodo)).Methods(http.MethodPost)
+	v1.HandleFunc("/trash", utils.HandleAPIFunc(trashHandler.HandleGetTrash)).Methods(http.MethodGet)
+	v1.HandleFunc("/trash", utils.HandleAPIFunc(trashHandler.HandleEmptyTrash)).Methods(http.MethodDelete)
+	v1.HandleFunc("/trash/{id}", utils.HandleAPIFunc(trashHandler.HandleDeleteTrashedTodo)).Methods(http.MethodDelete)
+	v1.HandleFunc("/todos/{id}/checklist", utils.HandleAPIFunc(checklistHandler.HandleInsertChecklistItem)).Methods(http.MethodPost)
+	v1.HandleFunc("/todos/{id}/checklist/{itemId}", utils.HandleAPIFunc(checklistHandler.HandlePatchChecklistItem)).Methods(http.MethodPatch)
+	v1.HandleFunc("/todos/{id}/checklist/{itemId}", utils.HandleAPIFunc(checklistHandler.HandleDeleteChecklistItem)).Methods(http.MethodDelete)
+
+	// tags
+	v1.HandleFunc("/tags", utils.HandleAPIFunc(tagHandler.HandleGetTags)).Methods(http.MethodGet)
+	v1.HandleFunc("/tags", utils.HandleAPIFunc(tagHandler.HandleInsertTag)).Methods(http.MethodPost)
+	v1.HandleFunc("/tags/{id}", utils.HandleAPIFunc(tagHandler.HandleGetTagByID)).Methods(http.MethodGet)
+	v1.HandleFunc("/tags/{id}", utils.HandleAPIFunc(tagHandler.HandlePatchTagByID)).Methods(http.MethodPatch)
+	v1.HandleFunc("/tags/{id}", utils.HandleAPIFunc(tagHandler.HandleDeleteTagByID)).Methods(http.MethodDelete)
 
-	// users
-	v1.HandleFunc("/users", utils.HandleAPIFunc(userHandler.HandleGetUsers)).Methods(http.MethodGet)
+	// lists
+	v1.HandleFunc("/lists", utils.HandleAPIFunc(listHandler.HandleGetLists)).Methods(http.MethodGet)
+	v1.HandleFunc("/lists", utils.HandleAPIFunc(listHandler.HandleInsertList)).Methods(http.MethodPost)
+	v1.HandleFunc("/lists/{id}", utils.HandleAPIFunc(listHandler.HandleGetListByID)).Methods(http.MethodGet)
+	v1.HandleFunc("/lists/{id}", utils.HandleAPIFunc(listHandler.HandlePatchListByID)).Methods(http.MethodPatch)
+	v1.HandleFunc("/lists/{id}", utils.HandleAPIFunc(listHandler.HandleDeleteListByID)).Methods(http.MethodDelete)
+	v1.HandleFunc("/lists/{id}/todos", utils.HandleAPIFunc(listHandler.HandleGetListTodos)).Methods(http.MethodGet)
+
+	// users, managing them needs the admin role
+	manageUsers := middleware.NewPermissionMiddleware(types.PermissionManageUsers)
+	admin := v1.NewRoute().Subrouter()
+	admin.Use(manageUsers.Middleware)
+	admin.HandleFunc("/users", utils.HandleAPIFunc(userHandler.HandleGetUsers)).Methods(http.MethodGet)
+	admin.HandleFunc("/users/{id}", utils.HandleAPIFunc(userHandler.HandleDeleteUserByID)).Methods(http.MethodDelete)
+	admin.HandleFunc("/users/{id}/role", utils.HandleAPIFunc(userHandler.HandlePutUserRole)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/disabled", utils.HandleAPIFunc(userHandler.HandlePutUserDisabled)).Methods(http.MethodPut)
+	admin.HandleFunc("/users/{id}/password/reset", utils.HandleAPIFunc(userHandler.HandleResetUserPassword)).Methods(http.MethodPost)
 	v1.HandleFunc("/users/{id}", utils.HandleAPIFunc(userHandler.HandleGetUserByID)).Methods(http.MethodGet)
 
+	v1.HandleFunc("/user", utils.HandleAPIFunc(userHandler.HandleDeleteUser)).Methods(http.MethodDelete)
 	v1.HandleFunc("/user/password", utils.HandleAPIFunc(userHandler.HandlePutUserPassword)).Methods(http.MethodPut)
+	v1.HandleFunc("/user/sessions", utils.HandleAPIFunc(sessionHandler.HandleGetSessions)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/sessions/{id}", utils.HandleAPIFunc(sessionHandler.HandleDeleteSession)).Methods(http.MethodDelete)
+	v1.HandleFunc("/user/tokens", utils.HandleAPIFunc(accessTokenHandler.HandleGetAccessTokens)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/tokens", utils.HandleAPIFunc(accessTokenHandler.HandleInsertAccessToken)).Methods(http.MethodPost)
+	v1.HandleFunc("/user/tokens/{id}", utils.HandleAPIFunc(accessTokenHandler.HandleDeleteAccessToken)).Methods(http.MethodDelete)
+	v1.HandleFunc("/user/events", utils.HandleAPIFunc(authEventHandler.HandleGetAuthEvents)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/export", utils.HandleAPIFunc(exportHandler.HandleExport)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/export/{id}", utils.HandleAPIFunc(exportHandler.HandleGetExport)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/export/{id}/download", utils.HandleAPIFunc(exportHandler.HandleDownloadExport)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/mfa", utils.HandleAPIFunc(mfaHandler.HandleGetMFA)).Methods(http.MethodGet)
+	v1.HandleFunc("/user/mfa", utils.HandleAPIFunc(mfaHandler.HandleEnrollMFA)).Methods(http.MethodPost)
+	v1.HandleFunc("/user/mfa/confirm", utils.HandleAPIFunc(mfaHandler.HandleConfirmMFA)).Methods(http.MethodPost)
+	v1.HandleFunc("/user/mfa/recovery-codes", utils.HandleAPIFunc(mfaHandler.HandleRegenerateRecoveryCodes)).Methods(http.MethodPost)
+	v1.HandleFunc("/user/mfa/disable", utils.HandleAPIFunc(mfaHandler.HandleDisableMFA)).Methods(http.MethodPost)
+
+	go purge(context.Background(), userStore, exportStore, databaseStore, trashRetention, purgeInterval)
+
+	// Stops on SIGINT or SIGTERM once the requests and the mails they send are done.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	server := &http.Server{Addr: listenAddr, Handler: r}
+	go func() {
+		log.Printf("Serving on %s...", listenAddr)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+	<-ctx.Done()
+
+	log.Printf("Shutting down..")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("error when shutting down: %s", err)
+	}
+	passwordHandler.Wait()
+	verificationHandler.Wait()
+}
+
+func newPostgreStore() (*store.PostgreTodoStore, error) {
+	log.Printf("Connecting to the database..")
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		os.Getenv("PSQL_HOST"),
+		os.Getenv("PSQL_PORT"),
+		os.Getenv("PSQL_USERNAME"),
+		os.Getenv("PSQL_PASSWORD"),
+		os.Getenv("PSQL_DATABASE"),
+		os.Getenv("PSQL_SSL"))
 
-	log.Printf("Serving on %s...", listenAddr)
-	log.Fatal(http.ListenAndServe(listenAddr, r))
+	return store.NewPostgreTodoStore(connStr)
 }
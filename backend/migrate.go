@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/thimc/go-svelte-todo/backend/store"
+)
+
+const migrateUsage = "usage: api migrate [up | down [steps] | status]"
+
+// runMigrate implements the “migrate“ subcommand.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(migrateUsage)
+	}
+
+	postgreStore, err := newPostgreStore()
+	if err != nil {
+		return err
+	}
+	defer postgreStore.Close()
+
+	migrator, err := store.NewMigrator(postgreStore)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Printf("Applied migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Printf("No pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			log.Printf("Rolled back migration %d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		version, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Schema version %d, latest known version %d", version, migrator.Latest())
+	default:
+		return fmt.Errorf(migrateUsage)
+	}
+
+	return nil
+}
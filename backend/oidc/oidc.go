@@ -0,0 +1,350 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// for the external identity providers that users can log in with.
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// The clocks of the provider and the API may differ by this much.
+const clockLeeway = time.Minute
+
+// The keys of the provider are fetched again for an unknown key ID at most once per interval.
+const keyRefreshInterval = time.Minute
+
+// Responses of the provider larger than this are rejected.
+const maxResponseSize = 1 << 20
+
+var providerName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+type Config struct {
+	// The name in the URLs of the API, like "company" in /api/oauth/company/start
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Where the provider sends the user back to with the code
+	RedirectURL string
+	Scopes      []string
+}
+
+// Metadata is the part of the discovery document that the flow needs.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a validated ID token.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider is an OpenID Connect provider, its discovery document and keys are
+// fetched when they are first needed.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu          sync.Mutex
+	metadata    *Metadata
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+func NewProvider(config Config, client *http.Client) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Provider{
+		config: config,
+		client: client,
+	}
+}
+
+// ProvidersFromEnv returns the providers named in OIDC_PROVIDERS, a comma separated
+// list. Every provider is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET and optionally OIDC_<NAME>_SCOPES, a space separated list.
+// The provider redirects to <redirectBase>/oauth/<name>/callback.
+func ProvidersFromEnv(redirectBase string) (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !providerName.MatchString(name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q, expected lower case letters, digits and dashes", name)
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		config := Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  strings.TrimSuffix(redirectBase, "/") + "/oauth/" + name + "/callback",
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID need to be set for the OIDC provider %s", prefix, prefix, name)
+		}
+		providers[name] = NewProvider(config, nil)
+	}
+	return providers, nil
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the URL of the provider that the user logs in at. The
+// challenge is the PKCE challenge of the verifier that is sent with the code.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.config.ClientID)
+	values.Set("redirect_uri", p.config.RedirectURL)
+	values.Set("scope", strings.Join(p.config.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", challenge)
+	values.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return metadata.AuthorizationEndpoint + sep + values.Encode(), nil
+}
+
+// Exchange redeems the code at the token endpoint and returns the claims of
+// the ID token, which needs to carry the nonce of the authorization request.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.config.RedirectURL)
+	values.Set("client_id", p.config.ClientID)
+	values.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var resp struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || resp.Error != "" {
+		return nil, fmt.Errorf("the token request failed with %d: %s %s", status, resp.Error, resp.ErrorDescription)
+	}
+	if resp.IDToken == "" {
+		return nil, fmt.Errorf("the token response has no ID token")
+	}
+
+	return p.VerifyIDToken(ctx, resp.IDToken, nonce)
+}
+
+// VerifyIDToken validates the signature, issuer, audience, lifetime and nonce of an ID token.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &jwt.Parser{ValidMethods: []string{jwt.SigningMethodRS256.Alg()}, SkipClaimsValidation: true}
+	tok, err := parser.Parse(raw, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	})
+	if err != nil || !tok.Valid {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	claims := tok.Claims.(jwt.MapClaims)
+	now := time.Now()
+	if !claims.VerifyIssuer(metadata.Issuer, true) {
+		return nil, fmt.Errorf("invalid ID token: unexpected issuer")
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, fmt.Errorf("invalid ID token: unexpected audience")
+	}
+	// Tokens for several audiences need to be issued to this client.
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, fmt.Errorf("invalid ID token: unexpected authorized party")
+	}
+	if !claims.VerifyExpiresAt(now.Add(-clockLeeway).Unix(), true) {
+		return nil, fmt.Errorf("invalid ID token: expired")
+	}
+	if !claims.VerifyIssuedAt(now.Add(clockLeeway).Unix(), false) || !claims.VerifyNotBefore(now.Add(clockLeeway).Unix(), false) {
+		return nil, fmt.Errorf("invalid ID token: not valid yet")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce == "" || tokenNonce != nonce {
+		return nil, fmt.Errorf("invalid ID token: unexpected nonce")
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("invalid ID token: missing subject")
+	}
+	email, _ := claims["email"].(string)
+	// Some providers send the boolean as a string.
+	verified := claims["email_verified"] == true || claims["email_verified"] == "true"
+
+	return &Claims{
+		Subject:       subject,
+		Email:         strings.ToLower(email),
+		EmailVerified: verified,
+	}, nil
+}
+
+// discover fetches the discovery document once, a failed fetch is retried by the next call.
+func (p *Provider) discover(ctx context.Context) (*Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata Metadata
+	status, err := p.do(req, &metadata)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("the discovery of %s failed with %d", p.config.Issuer, status)
+	}
+	if metadata.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("the discovery document is for the issuer %q instead of %q", metadata.Issuer, p.config.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, fmt.Errorf("the discovery document of %s is missing endpoints", p.config.Issuer)
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// key returns the signing key with the ID, the keys are fetched again if the
+// provider has rotated them.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.metadata.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetching the keys of %s failed with %d", p.config.Issuer, status)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+	p.keysFetched = time.Now()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// do sends the request and decodes the JSON response, returning its status code.
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+// PKCEChallenge returns the S256 code challenge of the verifier, see RFC 7636.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
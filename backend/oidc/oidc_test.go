@@ -0,0 +1,115 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/thimc/go-svelte-todo/backend/oidc"
+	"github.com/thimc/go-svelte-todo/backend/oidc/oidctest"
+)
+
+func TestExchange(t *testing.T) {
+	server := oidctest.NewServer("todo", "client-secret")
+	defer server.Close()
+	server.SetIdentity(oidctest.Identity{Subject: "1234", Email: "User@Domain.com", EmailVerified: true})
+
+	provider := oidc.NewProvider(server.Config("company", "http://localhost:5173/oauth/company/callback"), nil)
+	verifier := "a-verifier-that-is-long-enough-for-pkce-0123456789"
+
+	// authorize returns a code for the nonce and verifier.
+	authorize := func(t *testing.T, nonce, verifier string) string {
+		t.Helper()
+		authURL, err := provider.AuthCodeURL(context.TODO(), "state", nonce, oidc.PKCEChallenge(verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q := u.Query(); q.Get("code_challenge_method") != "S256" || q.Get("nonce") != nonce || !strings.Contains(q.Get("scope"), "openid") {
+			t.Fatalf("expected a PKCE authorization request with the nonce, got %s", authURL)
+		}
+		code, state, err := server.Authorize(authURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != "state" {
+			t.Fatalf("expected the state to be passed back, got %q", state)
+		}
+		return code
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		claims, err := provider.Exchange(context.TODO(), authorize(t, "nonce", verifier), verifier, "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.Subject != "1234" || claims.Email != "user@domain.com" || !claims.EmailVerified {
+			t.Errorf("expected the claims of the identity, got %+v", claims)
+		}
+	})
+
+	t.Run("Code reuse", func(t *testing.T) {
+		code := authorize(t, "nonce", verifier)
+		if _, err := provider.Exchange(context.TODO(), code, verifier, "nonce"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := provider.Exchange(context.TODO(), code, verifier, "nonce"); err == nil {
+			t.Error("expected a used code to be rejected")
+		}
+	})
+
+	t.Run("PKCE", func(t *testing.T) {
+		if _, err := provider.Exchange(context.TODO(), authorize(t, "nonce", verifier), "another-verifier", "nonce"); err == nil {
+			t.Error("expected a code with the wrong verifier to be rejected")
+		}
+	})
+
+	tests := []struct {
+		name   string
+		tamper func(jwt.MapClaims)
+		nonce  string
+	}{
+		{name: "Nonce", nonce: "another-nonce"},
+		{name: "Issuer", tamper: func(c jwt.MapClaims) { c["iss"] = "https://attacker.example.com" }},
+		{name: "Audience", tamper: func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{name: "Authorized party", tamper: func(c jwt.MapClaims) { c["aud"] = []string{"todo", "another-client"}; c["azp"] = "another-client" }},
+		{name: "Expired", tamper: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "Issued in the future", tamper: func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }},
+		{name: "Missing subject", tamper: func(c jwt.MapClaims) { delete(c, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Tamper(tt.tamper)
+			defer server.Tamper(nil)
+			nonce := "nonce"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			if _, err := provider.Exchange(context.TODO(), authorize(t, "nonce", verifier), verifier, nonce); err == nil {
+				t.Error("expected the ID token to be rejected")
+			}
+		})
+	}
+
+	t.Run("Unknown key", func(t *testing.T) {
+		server.SignWithUnknownKey(true)
+		defer server.SignWithUnknownKey(false)
+		if _, err := provider.Exchange(context.TODO(), authorize(t, "nonce", verifier), verifier, "nonce"); err == nil {
+			t.Error("expected an ID token signed with an unknown key to be rejected")
+		}
+	})
+
+	t.Run("Discovery", func(t *testing.T) {
+		config := server.Config("company", "http://localhost:5173/oauth/company/callback")
+		config.Issuer = server.URL + "/"
+		if _, err := oidc.NewProvider(config, nil).AuthCodeURL(context.TODO(), "state", "nonce", "challenge"); err == nil {
+			t.Error("expected a discovery document of another issuer to be rejected")
+		}
+	})
+}
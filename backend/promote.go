@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+const promoteUsage = "usage: api promote <email>"
+
+// runPromote implements the “promote“ subcommand, which makes a user an admin.
+// It creates the first admin, who can promote the other users through the API.
+func runPromote(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf(promoteUsage)
+	}
+
+	postgreStore, err := newPostgreStore()
+	if err != nil {
+		return err
+	}
+	defer postgreStore.Close()
+
+	ctx := context.Background()
+	userStore := store.NewPostgreUserStore(postgreStore)
+	user, err := userStore.GetUserByEmail(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if err := userStore.SetUserRoleByID(ctx, int64(user.ID), types.RoleAdmin); err != nil {
+		return err
+	}
+	log.Printf("Promoted %s to %s", user.Email, types.RoleAdmin)
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/store"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// Deleted accounts, expired exports and old trash are purged this often.
+const purgeInterval = time.Hour
+
+// purge deletes the accounts whose grace period is over, the exports older
+// than types.ExportTTL and the todos trashed longer than the retention, once
+// at startup and then every interval until the context is cancelled.
+func purge(ctx context.Context, userStore store.UserStorer, exportStore store.ExportStorer, todoStore store.TodoStorer, trashRetention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now()
+		purged, err := userStore.PurgeDeletedUsers(ctx, now)
+		if err != nil {
+			log.Printf("Purging deleted accounts: %s", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+		expired, err := exportStore.DeleteExpiredExports(ctx, now.Add(-types.ExportTTL))
+		if err != nil {
+			log.Printf("Purging expired exports: %s", err)
+		} else if expired > 0 {
+			log.Printf("Purged %d expired exports", expired)
+		}
+		trashed, err := todoStore.PurgeTrash(ctx, now.Add(-trashRetention))
+		if err != nil {
+			log.Printf("Purging the trash: %s", err)
+		} else if trashed > 0 {
+			log.Printf("Purged %d trashed todos", trashed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type AccessTokenStorer interface {
+	// Scoped to the user ID, tokens of other users are reported as unknown.
+	GetAccessTokens(context.Context, int) ([]*types.AccessToken, error)
+	InsertAccessToken(context.Context, *types.AccessToken) (*types.AccessToken, error)
+	DeleteAccessToken(context.Context, int64, int) error
+	// Used by the middleware to authenticate a request.
+	GetAccessTokenByHash(context.Context, string) (*types.AccessToken, error)
+	TouchAccessToken(context.Context, int64, time.Time) error
+}
+
+// The columns scanned by scanAccessToken, in order.
+const accessTokenColumns = `id, user_id, name, scopes, prefix, token_hash, created, expires_at, last_used`
+
+type PostgreAccessTokenStore struct {
+	db *sql.DB
+}
+
+func NewPostgreAccessTokenStore(s *PostgreTodoStore) *PostgreAccessTokenStore {
+	return &PostgreAccessTokenStore{
+		db: s.db,
+	}
+}
+
+func (s *PostgreAccessTokenStore) GetAccessTokens(ctx context.Context, userID int) ([]*types.AccessToken, error) {
+	tokens := []*types.AccessToken{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+accessTokenColumns+` FROM access_token WHERE user_id = $1 ORDER BY created, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Inserts a “*types.AccessToken“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreAccessTokenStore) InsertAccessToken(ctx context.Context, t *types.AccessToken) (*types.AccessToken, error) {
+	query := `INSERT INTO access_token(user_id, name, scopes, prefix, token_hash, created, expires_at)
+				VALUES                ($1,      $2,   $3,     $4,     $5,         $6,      $7) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, t.UserID, t.Name, pq.Array(t.Scopes), t.Prefix, t.TokenHash, t.Created.UTC(), utcTime(t.ExpiresAt)).Scan(&t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (s *PostgreAccessTokenStore) DeleteAccessToken(ctx context.Context, id int64, userID int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM access_token WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown access token ID: %d", id)
+	}
+
+	return nil
+}
+
+func (s *PostgreAccessTokenStore) GetAccessTokenByHash(ctx context.Context, hash string) (*types.AccessToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+accessTokenColumns+` FROM access_token WHERE token_hash = $1`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var token *types.AccessToken
+	for rows.Next() {
+		token, err = scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if token == nil {
+		return nil, fmt.Errorf("unknown access token")
+	}
+
+	return token, nil
+}
+
+func (s *PostgreAccessTokenStore) TouchAccessToken(ctx context.Context, id int64, lastUsed time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE access_token SET last_used = $1 WHERE id = $2`, lastUsed.UTC(), id)
+	return err
+}
+
+func scanAccessToken(rows *sql.Rows) (*types.AccessToken, error) {
+	var token types.AccessToken
+	err := rows.Scan(&token.ID, &token.UserID, &token.Name, pq.Array(&token.Scopes), &token.Prefix, &token.TokenHash,
+		&token.Created, &token.ExpiresAt, &token.LastUsed)
+	return &token, err
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type AuthEventStorer interface {
+	InsertAuthEvent(context.Context, *types.AuthEvent) (*types.AuthEvent, error)
+	// Returns at most limit events of the user, newest first.
+	GetAuthEvents(context.Context, int, int) ([]*types.AuthEvent, error)
+	// Counts the failed logins and two-factor codes of the email address since
+	// the time, failures before its last successful login are not counted.
+	GetAccountFailures(context.Context, string, time.Time) (*types.LoginFailures, error)
+	// Counts the failed logins and two-factor codes of the IP address since the time.
+	GetIPFailures(context.Context, string, time.Time) (*types.LoginFailures, error)
+}
+
+// The columns scanned by scanAuthEvent, in order.
+const authEventColumns = `id, user_id, email, event, ip, user_agent, created`
+
+type PostgreAuthEventStore struct {
+	db *sql.DB
+}
+
+func NewPostgreAuthEventStore(s *PostgreTodoStore) *PostgreAuthEventStore {
+	return &PostgreAuthEventStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.AuthEvent“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreAuthEventStore) InsertAuthEvent(ctx context.Context, e *types.AuthEvent) (*types.AuthEvent, error) {
+	query := `INSERT INTO auth_event(user_id, email, event, ip, user_agent, created)
+				VALUES              ($1,      $2,    $3,    $4, $5,         $6) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, e.UserID, e.Email, e.Event, e.IP, e.UserAgent, e.Created.UTC()).Scan(&e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *PostgreAuthEventStore) GetAuthEvents(ctx context.Context, userID int, limit int) ([]*types.AuthEvent, error) {
+	events := []*types.AuthEvent{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+authEventColumns+` FROM auth_event
+				WHERE user_id = $1 ORDER BY created DESC, id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event types.AuthEvent
+		err := rows.Scan(&event.ID, &event.UserID, &event.Email, &event.Event, &event.IP, &event.UserAgent, &event.Created)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *PostgreAuthEventStore) GetAccountFailures(ctx context.Context, email string, since time.Time) (*types.LoginFailures, error) {
+	query := `SELECT COUNT(*), MAX(created) FROM auth_event
+				WHERE email = $1 AND event IN ($2, $3) AND created > GREATEST($4,
+					(SELECT MAX(created) FROM auth_event WHERE email = $1 AND event = $5))`
+	return s.loginFailures(ctx, query, email, types.AuthEventLoginFailed, types.AuthEventMFAFailed, since.UTC(), types.AuthEventLoginSucceeded)
+}
+
+func (s *PostgreAuthEventStore) GetIPFailures(ctx context.Context, ip string, since time.Time) (*types.LoginFailures, error) {
+	query := `SELECT COUNT(*), MAX(created) FROM auth_event WHERE ip = $1 AND event IN ($2, $3) AND created > $4`
+	return s.loginFailures(ctx, query, ip, types.AuthEventLoginFailed, types.AuthEventMFAFailed, since.UTC())
+}
+
+func (s *PostgreAuthEventStore) loginFailures(ctx context.Context, query string, args ...any) (*types.LoginFailures, error) {
+	var (
+		failures types.LoginFailures
+		last     *time.Time
+	)
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&failures.Count, &last); err != nil {
+		return nil, err
+	}
+	if last != nil {
+		failures.Last = *last
+	}
+
+	return &failures, nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type ChecklistStorer interface {
+	// Every method is scoped to the todo ID and the user ID, items of todos
+	// created by other users are reported as unknown.
+	InsertChecklistItem(context.Context, int, *types.ChecklistItem) (*types.ChecklistItem, error)
+	PatchChecklistItem(context.Context, int64, int64, int, types.UpdateChecklistItemParams) (*types.ChecklistItem, error)
+	DeleteChecklistItem(context.Context, int64, int64, int) error
+}
+
+// The columns scanned by scanChecklistItem, in order.
+const checklistColumns = `id, todo_id, title, done, position`
+
+type PostgreChecklistStore struct {
+	db *sql.DB
+}
+
+func NewPostgreChecklistStore(s *PostgreTodoStore) *PostgreChecklistStore {
+	return &PostgreChecklistStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.ChecklistItem“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreChecklistStore) InsertChecklistItem(ctx context.Context, userID int, i *types.ChecklistItem) (*types.ChecklistItem, error) {
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `INSERT INTO todo_checklist_item(todo_id, title, done, position)
+					SELECT id, $2, $3, $4 FROM todo WHERE id = $1 AND created_by = $5 AND deleted_at IS NULL RETURNING id`
+		err := tx.QueryRowContext(ctx, query, i.TodoID, i.Title, i.Done, i.Position, userID).Scan(&i.ID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown todo ID: %d", i.TodoID)
+		}
+		if err != nil {
+			return err
+		}
+		return autoCompleteTodo(ctx, tx, i.TodoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (s *PostgreChecklistStore) PatchChecklistItem(ctx context.Context, todoID, id int64, userID int, i types.UpdateChecklistItemParams) (*types.ChecklistItem, error) {
+	var item *types.ChecklistItem
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `UPDATE todo_checklist_item i SET title = COALESCE($1, i.title), done = COALESCE($2, i.done), position = COALESCE($3, i.position)
+					FROM todo t
+					WHERE i.id = $4 AND i.todo_id = $5 AND t.id = i.todo_id AND t.created_by = $6 AND t.deleted_at IS NULL
+					RETURNING i.id, i.todo_id, i.title, i.done, i.position`
+		rows, err := tx.QueryContext(ctx, query, i.Title, i.Done, i.Position, id, todoID, userID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			item, err = scanChecklistItem(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+
+		if item == nil {
+			return fmt.Errorf("unknown checklist item ID: %d", id)
+		}
+		return autoCompleteTodo(ctx, tx, todoID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (s *PostgreChecklistStore) DeleteChecklistItem(ctx context.Context, todoID, id int64, userID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		query := `DELETE FROM todo_checklist_item i USING todo t
+					WHERE i.id = $1 AND i.todo_id = $2 AND t.id = i.todo_id AND t.created_by = $3 AND t.deleted_at IS NULL`
+		res, err := tx.ExecContext(ctx, query, id, todoID, userID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("unknown checklist item ID: %d", id)
+		}
+		// The remaining items might all be done.
+		return autoCompleteTodo(ctx, tx, todoID)
+	})
+}
+
+func scanChecklistItem(rows *sql.Rows) (*types.ChecklistItem, error) {
+	var item types.ChecklistItem
+	err := rows.Scan(&item.ID, &item.TodoID, &item.Title, &item.Done, &item.Position)
+	return &item, err
+}
+
+// loadTodoChecklists sets the checklist items of every todo.
+func loadTodoChecklists(ctx context.Context, q querier, todos ...*types.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	byID := map[int64]*types.Todo{}
+	ids := []int64{}
+	for _, todo := range todos {
+		todo.Checklist = []*types.ChecklistItem{}
+		byID[todo.ID] = todo
+		ids = append(ids, todo.ID)
+	}
+
+	query := `SELECT ` + checklistColumns + ` FROM todo_checklist_item WHERE todo_id = ANY($1) ORDER BY position, id`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return err
+		}
+		byID[item.TodoID].Checklist = append(byID[item.TodoID].Checklist, item)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type ExportStorer interface {
+	InsertExport(context.Context, *types.Export) (*types.Export, error)
+	// Scoped to the user ID, exports of other users are reported as unknown.
+	// The ZIP file is only loaded by GetExportData.
+	GetExportByID(context.Context, int64, int) (*types.Export, error)
+	GetExportData(context.Context, int64, int) ([]byte, error)
+	// Returns the newest pending export of the user created after the time, nil if there is none.
+	GetPendingExport(context.Context, int, time.Time) (*types.Export, error)
+	// Finishes a pending export with its ZIP file.
+	CompleteExport(context.Context, int64, []byte) error
+	// Finishes a pending export with the reason it failed.
+	FailExport(context.Context, int64, string) error
+	// Deletes the exports created before the time and returns how many were deleted.
+	DeleteExpiredExports(context.Context, time.Time) (int, error)
+}
+
+// The columns scanned by scanExport, in order.
+const exportColumns = `id, user_id, status, error, created, finished, size`
+
+type PostgreExportStore struct {
+	db *sql.DB
+}
+
+func NewPostgreExportStore(s *PostgreTodoStore) *PostgreExportStore {
+	return &PostgreExportStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.Export“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreExportStore) InsertExport(ctx context.Context, e *types.Export) (*types.Export, error) {
+	query := `INSERT INTO user_export(user_id, status, created)
+				VALUES               ($1,      $2,     $3) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, e.UserID, e.Status, e.Created.UTC()).Scan(&e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (s *PostgreExportStore) GetExportByID(ctx context.Context, id int64, userID int) (*types.Export, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+exportColumns+` FROM user_export WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var export *types.Export
+	for rows.Next() {
+		export, err = scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if export == nil {
+		return nil, fmt.Errorf("unknown export ID: %d", id)
+	}
+
+	return export, rows.Err()
+}
+
+func (s *PostgreExportStore) GetExportData(ctx context.Context, id int64, userID int) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM user_export WHERE id = $1 AND user_id = $2 AND status = $3`,
+		id, userID, types.ExportReady).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown export ID: %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (s *PostgreExportStore) GetPendingExport(ctx context.Context, userID int, since time.Time) (*types.Export, error) {
+	query := `SELECT ` + exportColumns + ` FROM user_export WHERE user_id = $1 AND status = $2 AND created > $3
+				ORDER BY created DESC, id DESC LIMIT 1`
+	rows, err := s.db.QueryContext(ctx, query, userID, types.ExportPending, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var export *types.Export
+	for rows.Next() {
+		export, err = scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return export, rows.Err()
+}
+
+func (s *PostgreExportStore) CompleteExport(ctx context.Context, id int64, data []byte) error {
+	query := `UPDATE user_export SET status = $1, finished = $2, size = $3, data = $4 WHERE id = $5 AND status = $6`
+	return s.finishExport(ctx, id, query, types.ExportReady, time.Now().UTC(), len(data), data, id, types.ExportPending)
+}
+
+func (s *PostgreExportStore) FailExport(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE user_export SET status = $1, finished = $2, error = $3 WHERE id = $4 AND status = $5`
+	return s.finishExport(ctx, id, query, types.ExportFailed, time.Now().UTC(), reason, id, types.ExportPending)
+}
+
+func (s *PostgreExportStore) finishExport(ctx context.Context, id int64, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown pending export ID: %d", id)
+	}
+
+	return nil
+}
+
+func (s *PostgreExportStore) DeleteExpiredExports(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM user_export WHERE created < $1`, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(affected), nil
+}
+
+func scanExport(rows *sql.Rows) (*types.Export, error) {
+	var export types.Export
+	err := rows.Scan(&export.ID, &export.UserID, &export.Status, &export.Error, &export.Created, &export.Finished, &export.Size)
+	return &export, err
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type IdentityStorer interface {
+	// Returns the identity of the subject at the provider.
+	GetIdentity(context.Context, string, string) (*types.Identity, error)
+	InsertIdentity(context.Context, *types.Identity) (*types.Identity, error)
+	// Returns the identities linked to the user, oldest first.
+	GetIdentities(context.Context, int) ([]*types.Identity, error)
+}
+
+type PostgreIdentityStore struct {
+	db *sql.DB
+}
+
+func NewPostgreIdentityStore(s *PostgreTodoStore) *PostgreIdentityStore {
+	return &PostgreIdentityStore{
+		db: s.db,
+	}
+}
+
+func (s *PostgreIdentityStore) GetIdentity(ctx context.Context, provider, subject string) (*types.Identity, error) {
+	var identity types.Identity
+	err := s.db.QueryRowContext(ctx, `SELECT id, user_id, provider, subject, email, created FROM user_identity
+				WHERE provider = $1 AND subject = $2`, provider, subject).
+		Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.Created)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown identity")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// Inserts a “*types.Identity“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreIdentityStore) InsertIdentity(ctx context.Context, i *types.Identity) (*types.Identity, error) {
+	query := `INSERT INTO user_identity(user_id, provider, subject, email, created)
+				VALUES                 ($1,      $2,       $3,      $4,    $5) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, i.UserID, i.Provider, i.Subject, i.Email, i.Created.UTC()).Scan(&i.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (s *PostgreIdentityStore) GetIdentities(ctx context.Context, userID int) ([]*types.Identity, error) {
+	identities := []*types.Identity{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, user_id, provider, subject, email, created FROM user_identity
+				WHERE user_id = $1 ORDER BY created, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var identity types.Identity
+		if err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.Created); err != nil {
+			return nil, err
+		}
+		identities = append(identities, &identity)
+	}
+
+	return identities, rows.Err()
+}
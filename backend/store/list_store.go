@@ -0,0 +1,200 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type ListStorer interface {
+	// Every method is scoped to the user ID, lists created by other users are reported as unknown.
+	// Archived lists are only returned by GetLists if the bool is set.
+	GetLists(context.Context, int, bool) ([]*types.List, error)
+	GetListByID(context.Context, int64, int) (*types.List, error)
+	InsertList(context.Context, *types.List) (*types.List, error)
+	PatchListByID(context.Context, int64, int, types.UpdateListParams) (*types.List, error)
+	DeleteListByID(context.Context, int64, int) error
+}
+
+// The columns scanned by scanList, in order.
+const listColumns = `id, name, description, archived, position, inbox, created, created_by`
+
+type PostgreListStore struct {
+	db *sql.DB
+}
+
+func NewPostgreListStore(s *PostgreTodoStore) *PostgreListStore {
+	return &PostgreListStore{
+		db: s.db,
+	}
+}
+
+func (s *PostgreListStore) GetLists(ctx context.Context, userID int, archived bool) ([]*types.List, error) {
+	lists := []*types.List{}
+
+	query := `SELECT ` + listColumns + ` FROM todo_list WHERE created_by = $1 AND (NOT archived OR $2) ORDER BY position, id`
+	rows, err := s.db.QueryContext(ctx, query, userID, archived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, rows.Err()
+}
+
+func (s *PostgreListStore) GetListByID(ctx context.Context, id int64, userID int) (*types.List, error) {
+	var list *types.List
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+listColumns+` FROM todo_list WHERE id = $1 AND created_by = $2`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		list, err = scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if list == nil {
+		return nil, fmt.Errorf("unknown list ID: %d", id)
+	}
+
+	return list, nil
+}
+
+// Inserts a “*types.List“ and mutates the “ID“ and “Created“ properties to those from Postgre.
+func (s *PostgreListStore) InsertList(ctx context.Context, l *types.List) (*types.List, error) {
+	query := `INSERT INTO todo_list(name, description, archived, position, inbox, created, created_by)
+				VALUES             ($1,   $2,          $3,       $4,       $5,    NOW(),   $6)
+				ON CONFLICT DO NOTHING RETURNING id, created`
+	rows, err := s.db.QueryContext(ctx, query, l.Name, l.Description, l.Archived, l.Position, l.Inbox, l.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Only the unique inbox index can conflict.
+	if !rows.Next() {
+		return nil, fmt.Errorf("inbox exists already")
+	}
+	if err := rows.Scan(&l.ID, &l.Created); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (s *PostgreListStore) PatchListByID(ctx context.Context, id int64, userID int, l types.UpdateListParams) (*types.List, error) {
+	var list *types.List
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+listColumns+` FROM todo_list WHERE id = $1 AND created_by = $2 FOR UPDATE`, id, userID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			list, err = scanList(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+
+		if list == nil {
+			return fmt.Errorf("unknown list ID: %d", id)
+		}
+		if list.Inbox && l.Archived != nil && *l.Archived {
+			return fmt.Errorf("the inbox can't be archived")
+		}
+
+		query := `UPDATE todo_list SET name = COALESCE($1, name), description = COALESCE($2, description),
+					archived = COALESCE($3, archived), position = COALESCE($4, position)
+					WHERE id = $5 RETURNING ` + listColumns
+		rows, err = tx.QueryContext(ctx, query, l.Name, l.Description, l.Archived, l.Position, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			list, err = scanList(rows)
+			if err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Deletes the list and moves its todos to the inbox of the user.
+func (s *PostgreListStore) DeleteListByID(ctx context.Context, id int64, userID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var inbox bool
+		err := tx.QueryRowContext(ctx, `SELECT inbox FROM todo_list WHERE id = $1 AND created_by = $2 FOR UPDATE`, id, userID).Scan(&inbox)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown list ID: %d", id)
+		}
+		if err != nil {
+			return err
+		}
+		if inbox {
+			return fmt.Errorf("the inbox can't be deleted")
+		}
+
+		query := `UPDATE todo SET list_id = (SELECT id FROM todo_list WHERE created_by = $2 AND inbox)
+					WHERE list_id = $1`
+		if _, err := tx.ExecContext(ctx, query, id, userID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM todo_list WHERE id = $1`, id)
+		return err
+	})
+}
+
+func scanList(rows *sql.Rows) (*types.List, error) {
+	var list types.List
+	err := rows.Scan(&list.ID, &list.Name, &list.Description, &list.Archived, &list.Position, &list.Inbox, &list.Created, &list.CreatedBy)
+	return &list, err
+}
+
+// todoListID returns the list a todo of the user is stored in, the inbox
+// if listID is nil. Users without an inbox keep their todos outside of lists.
+func todoListID(ctx context.Context, q querier, listID *int64, userID int) (*int64, error) {
+	var id int64
+	if listID == nil {
+		err := q.QueryRowContext(ctx, `SELECT id FROM todo_list WHERE created_by = $1 AND inbox`, userID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &id, nil
+	}
+
+	err := q.QueryRowContext(ctx, `SELECT id FROM todo_list WHERE id = $1 AND created_by = $2`, *listID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown list ID: %d", *listID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
@@ -0,0 +1,483 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// memoryDB holds every table of the in-memory stores behind a single lock so
+// that the stores sharing it behave like they would against one database.
+type memoryDB struct {
+	mu sync.RWMutex
+
+	todos      map[int64]*types.Todo
+	nextTodoID int64
+
+	todoRevisions      map[int64]*types.TodoRevision
+	nextTodoRevisionID int64
+
+	users      map[int]*types.User
+	nextUserID int
+	// user ID to the time the last verification mail was sent
+	verificationSentAt map[int]time.Time
+	// user ID to the time the last password reset mail was sent
+	passwordResetSentAt map[int]time.Time
+
+	tags      map[int64]*types.Tag
+	nextTagID int64
+	// todo ID to the IDs of its tags
+	todoTags map[int64][]int64
+
+	lists      map[int64]*types.List
+	nextListID int64
+
+	checklist           map[int64]*types.ChecklistItem
+	nextChecklistItemID int64
+
+	refreshTokens      map[int64]*types.RefreshToken
+	nextRefreshTokenID int64
+
+	sessions      map[int64]*types.Session
+	nextSessionID int64
+
+	accessTokens      map[int64]*types.AccessToken
+	nextAccessTokenID int64
+
+	passwordResetTokens      map[int64]*types.PasswordResetToken
+	nextPasswordResetTokenID int64
+
+	mfa map[int]*types.MFA
+	// user ID to the hashes of the recovery codes and whether they were used
+	recoveryCodes map[int]map[string]bool
+
+	authEvents      map[int64]*types.AuthEvent
+	nextAuthEventID int64
+
+	identities     map[int64]*types.Identity
+	nextIdentityID int64
+
+	exports      map[int64]*types.Export
+	nextExportID int64
+}
+
+func newMemoryDB() *memoryDB {
+	return &memoryDB{
+		todos:     map[int64]*types.Todo{},
+		users:     map[int]*types.User{},
+		tags:      map[int64]*types.Tag{},
+		todoTags:  map[int64][]int64{},
+		lists:     map[int64]*types.List{},
+		checklist: map[int64]*types.ChecklistItem{},
+
+		refreshTokens: map[int64]*types.RefreshToken{},
+		sessions:      map[int64]*types.Session{},
+		accessTokens:  map[int64]*types.AccessToken{},
+
+		passwordResetTokens: map[int64]*types.PasswordResetToken{},
+		verificationSentAt:  map[int]time.Time{},
+		passwordResetSentAt: map[int]time.Time{},
+
+		mfa:           map[int]*types.MFA{},
+		recoveryCodes: map[int]map[string]bool{},
+		authEvents:    map[int64]*types.AuthEvent{},
+		identities:    map[int64]*types.Identity{},
+		exports:       map[int64]*types.Export{},
+		todoRevisions: map[int64]*types.TodoRevision{},
+	}
+}
+
+// todo returns a deep copy of the todo with its tags, checklist and progress,
+// the caller needs to hold the lock.
+func (db *memoryDB) todo(t *types.Todo) *types.Todo {
+	todo := copyTodo(t)
+	todo.Tags = []*types.Tag{}
+	for _, id := range db.todoTags[t.ID] {
+		tag := *db.tags[id]
+		todo.Tags = append(todo.Tags, &tag)
+	}
+	sort.Slice(todo.Tags, func(i, j int) bool {
+		return todo.Tags[i].Name < todo.Tags[j].Name
+	})
+	todo.Checklist = db.todoChecklist(t.ID)
+	done, total := db.todoProgress(t.ID)
+	todo.Progress = types.NewTodoProgress(done, total)
+	return todo
+}
+
+// todoChecklist returns copies of the checklist items sorted by their position,
+// the caller needs to hold the lock.
+func (db *memoryDB) todoChecklist(todoID int64) []*types.ChecklistItem {
+	items := []*types.ChecklistItem{}
+	for _, item := range db.checklist {
+		if item.TodoID == todoID {
+			i := *item
+			items = append(items, &i)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Position != items[j].Position {
+			return items[i].Position < items[j].Position
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// todoProgress counts the done and all subtasks and checklist items of the todo,
+// the caller needs to hold the lock.
+func (db *memoryDB) todoProgress(todoID int64) (done, total int) {
+	for _, todo := range db.todos {
+		if todo.ParentID != nil && *todo.ParentID == todoID && todo.DeletedAt == nil {
+			if todo.Done {
+				done++
+			}
+			total++
+		}
+	}
+	for _, item := range db.checklist {
+		if item.TodoID == todoID {
+			if item.Done {
+				done++
+			}
+			total++
+		}
+	}
+	return done, total
+}
+
+// checkTodoParent mirrors the function of the PostgreSQL store, the caller needs to hold the lock.
+func (db *memoryDB) checkTodoParent(todoID, parentID int64, userID int) error {
+	if parent, ok := db.todos[parentID]; !ok || parent.CreatedBy != userID || parent.DeletedAt != nil {
+		return fmt.Errorf("%w: unknown parent ID: %d", ErrInvalidParent, parentID)
+	}
+
+	ancestors := 0
+	for id := &parentID; id != nil; id = db.todos[*id].ParentID {
+		if *id == todoID {
+			return fmt.Errorf("%w: a todo can't be nested below itself or its subtasks", ErrInvalidParent)
+		}
+		ancestors++
+	}
+	height := 0
+	if todoID != 0 {
+		height = db.todoHeight(todoID)
+	}
+	if ancestors+1+height > types.MaxTodoDepth {
+		return fmt.Errorf("%w: todos can only be nested %d levels deep", ErrInvalidParent, types.MaxTodoDepth)
+	}
+
+	return nil
+}
+
+// todoHeight returns the number of subtask levels below the todo, the caller needs to hold the lock.
+func (db *memoryDB) todoHeight(todoID int64) int {
+	height := 0
+	for _, todo := range db.todos {
+		if todo.ParentID != nil && *todo.ParentID == todoID {
+			if h := db.todoHeight(todo.ID) + 1; h > height {
+				height = h
+			}
+		}
+	}
+	return height
+}
+
+// insertTodo mirrors the function of the PostgreSQL store, the caller needs to hold the write lock.
+func (db *memoryDB) insertTodo(t *types.Todo) error {
+	tagIDs := t.TagIDs()
+	if err := db.checkTags(t.CreatedBy, tagIDs); err != nil {
+		return err
+	}
+	listID, err := db.todoListID(t.ListID, t.CreatedBy)
+	if err != nil {
+		return err
+	}
+	t.ListID = listID
+	if t.ParentID != nil {
+		if err := db.checkTodoParent(0, *t.ParentID, t.CreatedBy); err != nil {
+			return err
+		}
+	}
+
+	db.nextTodoID++
+	t.ID = db.nextTodoID
+	t.Created = time.Now().UTC()
+	t.DueAt = utcTime(t.DueAt)
+	db.todos[t.ID] = copyTodo(t)
+	if err := db.setTodoTags(t.ID, t.CreatedBy, tagIDs); err != nil {
+		return err
+	}
+	if err := db.autoCompleteTodo(t.ID); err != nil {
+		return err
+	}
+	*t = *db.todo(db.todos[t.ID])
+	return db.insertTodoRevision(nil, t, t.CreatedBy)
+}
+
+// spawnNextOccurrence mirrors the function of the PostgreSQL store, the caller
+// needs to hold the write lock.
+func (db *memoryDB) spawnNextOccurrence(todo *types.Todo) error {
+	if todo.Recurrence == "" {
+		return nil
+	}
+	next, err := db.todo(todo).NextOccurrence(time.Now())
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		if err := db.insertTodo(next); err != nil {
+			return err
+		}
+	}
+	todo.Recurrence = ""
+	return nil
+}
+
+// autoCompleteTodo mirrors the function of the PostgreSQL store, the caller needs to hold the write lock.
+func (db *memoryDB) autoCompleteTodo(id int64) error {
+	for todo, ok := db.todos[id]; ok; todo, ok = db.todos[id] {
+		if todo.AutoComplete && !todo.Done {
+			if done, total := db.todoProgress(id); total > 0 && done == total {
+				todo.Done = true
+				if err := db.spawnNextOccurrence(todo); err != nil {
+					return err
+				}
+			}
+		}
+		if todo.ParentID == nil {
+			return nil
+		}
+		id = *todo.ParentID
+	}
+	return nil
+}
+
+// trashTodo moves the todo with its subtasks to the trash, subtasks trashed before keep
+// their time. The caller needs to hold the write lock.
+func (db *memoryDB) trashTodo(id int64, now time.Time) {
+	for _, todo := range db.todos {
+		if todo.ParentID != nil && *todo.ParentID == id && todo.DeletedAt == nil {
+			db.trashTodo(todo.ID, now)
+		}
+	}
+	db.todos[id].DeletedAt = &now
+}
+
+// restoreTodo restores the todo with the subtasks that were trashed along with it,
+// the caller needs to hold the write lock.
+func (db *memoryDB) restoreTodo(id int64, deletedAt time.Time) {
+	for _, todo := range db.todos {
+		if todo.ParentID != nil && *todo.ParentID == id && todo.DeletedAt != nil && todo.DeletedAt.Equal(deletedAt) {
+			db.restoreTodo(todo.ID, deletedAt)
+		}
+	}
+	db.todos[id].DeletedAt = nil
+}
+
+// deleteTrashedTodos deletes the trashed todos matching the filter and returns how many,
+// the caller needs to hold the write lock.
+func (db *memoryDB) deleteTrashedTodos(match func(*types.Todo) bool) int {
+	ids := []int64{}
+	for id, todo := range db.todos {
+		if todo.DeletedAt != nil && match(todo) {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range ids {
+		db.deleteTodo(id)
+	}
+	return len(ids)
+}
+
+// deleteTodo deletes the todo with its subtasks, tags and checklist, the caller needs to hold the write lock.
+func (db *memoryDB) deleteTodo(id int64) {
+	for _, todo := range db.todos {
+		if todo.ParentID != nil && *todo.ParentID == id {
+			db.deleteTodo(todo.ID)
+		}
+	}
+	for itemID, item := range db.checklist {
+		if item.TodoID == id {
+			delete(db.checklist, itemID)
+		}
+	}
+	for revisionID, revision := range db.todoRevisions {
+		if revision.TodoID == id {
+			delete(db.todoRevisions, revisionID)
+		}
+	}
+	delete(db.todos, id)
+	delete(db.todoTags, id)
+}
+
+// insertTodoRevision mirrors the function of the PostgreSQL store, the caller
+// needs to hold the write lock.
+func (db *memoryDB) insertTodoRevision(before, after *types.Todo, changedBy int) error {
+	changes, err := types.DiffTodos(before, after)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	db.nextTodoRevisionID++
+	db.todoRevisions[db.nextTodoRevisionID] = &types.TodoRevision{
+		ID:        db.nextTodoRevisionID,
+		TodoID:    after.ID,
+		ChangedBy: changedBy,
+		Created:   time.Now().UTC(),
+		Changes:   changes,
+	}
+	return nil
+}
+
+// deleteUser deletes the user with the todos, tags and lists of the user, the
+// caller needs to hold the write lock.
+func (db *memoryDB) deleteUser(id int) {
+	for todoID, todo := range db.todos {
+		if todo.CreatedBy == id {
+			db.deleteTodo(todoID)
+		}
+	}
+	for tagID, tag := range db.tags {
+		if tag.CreatedBy == id {
+			delete(db.tags, tagID)
+		}
+	}
+	for listID, list := range db.lists {
+		if list.CreatedBy == id {
+			delete(db.lists, listID)
+		}
+	}
+	delete(db.users, id)
+	delete(db.verificationSentAt, id)
+	delete(db.passwordResetSentAt, id)
+	delete(db.mfa, id)
+	delete(db.recoveryCodes, id)
+	// Like the foreign keys in PostgreSQL, the tokens and sessions of the user cascade.
+	for tokenID, token := range db.refreshTokens {
+		if token.UserID == id {
+			delete(db.refreshTokens, tokenID)
+		}
+	}
+	for sessionID, session := range db.sessions {
+		if session.UserID == id {
+			delete(db.sessions, sessionID)
+		}
+	}
+	for tokenID, token := range db.accessTokens {
+		if token.UserID == id {
+			delete(db.accessTokens, tokenID)
+		}
+	}
+	for tokenID, token := range db.passwordResetTokens {
+		if token.UserID == id {
+			delete(db.passwordResetTokens, tokenID)
+		}
+	}
+	for identityID, identity := range db.identities {
+		if identity.UserID == id {
+			delete(db.identities, identityID)
+		}
+	}
+	for eventID, event := range db.authEvents {
+		if event.UserID != nil && *event.UserID == id {
+			delete(db.authEvents, eventID)
+		}
+	}
+	for exportID, export := range db.exports {
+		if export.UserID == id {
+			delete(db.exports, exportID)
+		}
+	}
+}
+
+// checkTags returns an error unless every tag belongs to the user, the caller needs to hold the lock.
+func (db *memoryDB) checkTags(userID int, tagIDs []int64) error {
+	for _, id := range tagIDs {
+		if tag, ok := db.tags[id]; !ok || tag.CreatedBy != userID {
+			return fmt.Errorf("unknown tag ID in %v", uniqueIDs(tagIDs))
+		}
+	}
+	return nil
+}
+
+// setTodoTags replaces the tags of the todo, the caller needs to hold the write lock.
+func (db *memoryDB) setTodoTags(todoID int64, userID int, tagIDs []int64) error {
+	if err := db.checkTags(userID, tagIDs); err != nil {
+		return err
+	}
+	unique := uniqueIDs(tagIDs)
+	if len(unique) == 0 {
+		delete(db.todoTags, todoID)
+		return nil
+	}
+	db.todoTags[todoID] = unique
+	return nil
+}
+
+// hasTags reports whether the todo is tagged with any (or all) of the tag names.
+func (db *memoryDB) hasTags(todoID int64, names []string, all bool) bool {
+	tagged := map[string]bool{}
+	for _, id := range db.todoTags[todoID] {
+		tagged[db.tags[id].Name] = true
+	}
+	for _, name := range names {
+		if tagged[name] && !all {
+			return true
+		}
+		if !tagged[name] && all {
+			return false
+		}
+	}
+	return all
+}
+
+// revokeSession revokes the session and its refresh tokens, the caller needs to hold the write lock.
+func (db *memoryDB) revokeSession(session *types.Session, now time.Time) {
+	session.RevokedAt = &now
+	for _, token := range db.refreshTokens {
+		if token.SessionID == session.ID && token.RevokedAt == nil {
+			revokedAt := now
+			token.RevokedAt = &revokedAt
+		}
+	}
+}
+
+// setRecoveryCodes replaces the recovery codes of the user, the caller needs to hold the write lock.
+func (db *memoryDB) setRecoveryCodes(userID int, codeHashes []string) {
+	codes := map[string]bool{}
+	for _, hash := range codeHashes {
+		codes[hash] = false
+	}
+	db.recoveryCodes[userID] = codes
+}
+
+// todoListID returns the list a todo of the user is stored in, the inbox if listID
+// is nil. Users without an inbox keep their todos outside of lists.
+// The caller needs to hold the lock.
+func (db *memoryDB) todoListID(listID *int64, userID int) (*int64, error) {
+	if listID != nil {
+		if list, ok := db.lists[*listID]; !ok || list.CreatedBy != userID {
+			return nil, fmt.Errorf("unknown list ID: %d", *listID)
+		}
+		id := *listID
+		return &id, nil
+	}
+	if inbox := db.inbox(userID); inbox != nil {
+		id := inbox.ID
+		return &id, nil
+	}
+	return nil, nil
+}
+
+// inbox returns the inbox of the user or nil, the caller needs to hold the lock.
+func (db *memoryDB) inbox(userID int) *types.List {
+	for _, list := range db.lists {
+		if list.CreatedBy == userID && list.Inbox {
+			return list
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryAccessTokenStore is a thread-safe, in-process implementation of AccessTokenStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryAccessTokenStore struct {
+	db *memoryDB
+}
+
+func NewMemoryAccessTokenStore(s *MemoryTodoStore) *MemoryAccessTokenStore {
+	return &MemoryAccessTokenStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryAccessTokenStore) GetAccessTokens(ctx context.Context, userID int) ([]*types.AccessToken, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	tokens := []*types.AccessToken{}
+	for _, token := range s.db.accessTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, copyAccessToken(token))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].Created.Equal(tokens[j].Created) {
+			return tokens[i].Created.Before(tokens[j].Created)
+		}
+		return tokens[i].ID < tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+// Inserts a “*types.AccessToken“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryAccessTokenStore) InsertAccessToken(ctx context.Context, t *types.AccessToken) (*types.AccessToken, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.nextAccessTokenID++
+	t.ID = s.db.nextAccessTokenID
+	s.db.accessTokens[t.ID] = copyAccessToken(t)
+
+	return t, nil
+}
+
+func (s *MemoryAccessTokenStore) DeleteAccessToken(ctx context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	token, ok := s.db.accessTokens[id]
+	if !ok || token.UserID != userID {
+		return fmt.Errorf("unknown access token ID: %d", id)
+	}
+	delete(s.db.accessTokens, id)
+
+	return nil
+}
+
+func (s *MemoryAccessTokenStore) GetAccessTokenByHash(ctx context.Context, hash string) (*types.AccessToken, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, token := range s.db.accessTokens {
+		if token.TokenHash == hash {
+			return copyAccessToken(token), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown access token")
+}
+
+func (s *MemoryAccessTokenStore) TouchAccessToken(ctx context.Context, id int64, lastUsed time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if token, ok := s.db.accessTokens[id]; ok {
+		t := lastUsed.UTC()
+		token.LastUsed = &t
+	}
+
+	return nil
+}
+
+func copyAccessToken(t *types.AccessToken) *types.AccessToken {
+	token := *t
+	token.Scopes = append([]string{}, t.Scopes...)
+	token.ExpiresAt = copyTime(t.ExpiresAt)
+	token.LastUsed = copyTime(t.LastUsed)
+	return &token
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryAuthEventStore is a thread-safe, in-process implementation of AuthEventStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryAuthEventStore struct {
+	db *memoryDB
+}
+
+func NewMemoryAuthEventStore(s *MemoryTodoStore) *MemoryAuthEventStore {
+	return &MemoryAuthEventStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.AuthEvent“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryAuthEventStore) InsertAuthEvent(ctx context.Context, e *types.AuthEvent) (*types.AuthEvent, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.nextAuthEventID++
+	e.ID = s.db.nextAuthEventID
+	s.db.authEvents[e.ID] = copyAuthEvent(e)
+
+	return e, nil
+}
+
+func (s *MemoryAuthEventStore) GetAuthEvents(ctx context.Context, userID int, limit int) ([]*types.AuthEvent, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	events := []*types.AuthEvent{}
+	for _, event := range s.db.authEvents {
+		if event.UserID != nil && *event.UserID == userID {
+			events = append(events, copyAuthEvent(event))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].Created.Equal(events[j].Created) {
+			return events[i].Created.After(events[j].Created)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+func (s *MemoryAuthEventStore) GetAccountFailures(ctx context.Context, email string, since time.Time) (*types.LoginFailures, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, event := range s.db.authEvents {
+		if event.Email == email && event.Event == types.AuthEventLoginSucceeded && event.Created.After(since) {
+			since = event.Created
+		}
+	}
+
+	return s.db.loginFailures(func(e *types.AuthEvent) bool {
+		return e.Email == email && e.Created.After(since)
+	}), nil
+}
+
+func (s *MemoryAuthEventStore) GetIPFailures(ctx context.Context, ip string, since time.Time) (*types.LoginFailures, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return s.db.loginFailures(func(e *types.AuthEvent) bool {
+		return e.IP == ip && e.Created.After(since)
+	}), nil
+}
+
+// loginFailures counts the failed logins and two-factor codes that match, the caller needs to hold the lock.
+func (db *memoryDB) loginFailures(match func(*types.AuthEvent) bool) *types.LoginFailures {
+	failures := &types.LoginFailures{}
+	for _, event := range db.authEvents {
+		if event.Event != types.AuthEventLoginFailed && event.Event != types.AuthEventMFAFailed {
+			continue
+		}
+		if match(event) {
+			failures.Count++
+			if event.Created.After(failures.Last) {
+				failures.Last = event.Created
+			}
+		}
+	}
+	return failures
+}
+
+func copyAuthEvent(e *types.AuthEvent) *types.AuthEvent {
+	event := *e
+	if e.UserID != nil {
+		userID := *e.UserID
+		event.UserID = &userID
+	}
+	return &event
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryChecklistStore is a thread-safe, in-process implementation of ChecklistStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryChecklistStore struct {
+	db *memoryDB
+}
+
+func NewMemoryChecklistStore(s *MemoryTodoStore) *MemoryChecklistStore {
+	return &MemoryChecklistStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.ChecklistItem“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryChecklistStore) InsertChecklistItem(ctx context.Context, userID int, i *types.ChecklistItem) (*types.ChecklistItem, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if todo, ok := s.db.todos[i.TodoID]; !ok || todo.CreatedBy != userID || todo.DeletedAt != nil {
+		return nil, fmt.Errorf("unknown todo ID: %d", i.TodoID)
+	}
+
+	s.db.nextChecklistItemID++
+	i.ID = s.db.nextChecklistItemID
+	item := *i
+	s.db.checklist[i.ID] = &item
+	if err := s.db.autoCompleteTodo(i.TodoID); err != nil {
+		return nil, err
+	}
+
+	return i, nil
+}
+
+func (s *MemoryChecklistStore) PatchChecklistItem(ctx context.Context, todoID, id int64, userID int, i types.UpdateChecklistItemParams) (*types.ChecklistItem, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	item, ok := s.ownedItem(todoID, id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown checklist item ID: %d", id)
+	}
+
+	if i.Title != nil {
+		item.Title = *i.Title
+	}
+	if i.Done != nil {
+		item.Done = *i.Done
+	}
+	if i.Position != nil {
+		item.Position = *i.Position
+	}
+	if err := s.db.autoCompleteTodo(todoID); err != nil {
+		return nil, err
+	}
+	patched := *item
+
+	return &patched, nil
+}
+
+func (s *MemoryChecklistStore) DeleteChecklistItem(ctx context.Context, todoID, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.ownedItem(todoID, id, userID); !ok {
+		return fmt.Errorf("unknown checklist item ID: %d", id)
+	}
+	delete(s.db.checklist, id)
+	// The remaining items might all be done.
+	return s.db.autoCompleteTodo(todoID)
+}
+
+// ownedItem expects the caller to hold the lock.
+func (s *MemoryChecklistStore) ownedItem(todoID, id int64, userID int) (*types.ChecklistItem, bool) {
+	item, ok := s.db.checklist[id]
+	if !ok || item.TodoID != todoID {
+		return nil, false
+	}
+	if todo, ok := s.db.todos[todoID]; !ok || todo.CreatedBy != userID || todo.DeletedAt != nil {
+		return nil, false
+	}
+	return item, true
+}
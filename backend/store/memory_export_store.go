@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryExportStore is a thread-safe, in-process implementation of ExportStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryExportStore struct {
+	db *memoryDB
+}
+
+func NewMemoryExportStore(s *MemoryTodoStore) *MemoryExportStore {
+	return &MemoryExportStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.Export“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryExportStore) InsertExport(ctx context.Context, e *types.Export) (*types.Export, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[e.UserID]; !ok {
+		return nil, fmt.Errorf("unknown user ID: %d", e.UserID)
+	}
+	s.db.nextExportID++
+	e.ID = s.db.nextExportID
+	s.db.exports[e.ID] = copyExport(e)
+
+	return e, nil
+}
+
+func (s *MemoryExportStore) GetExportByID(ctx context.Context, id int64, userID int) (*types.Export, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	export, ok := s.db.exports[id]
+	if !ok || export.UserID != userID {
+		return nil, fmt.Errorf("unknown export ID: %d", id)
+	}
+	e := copyExport(export)
+	e.Data = nil
+
+	return e, nil
+}
+
+func (s *MemoryExportStore) GetExportData(ctx context.Context, id int64, userID int) ([]byte, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	export, ok := s.db.exports[id]
+	if !ok || export.UserID != userID || export.Status != types.ExportReady {
+		return nil, fmt.Errorf("unknown export ID: %d", id)
+	}
+
+	return append([]byte{}, export.Data...), nil
+}
+
+func (s *MemoryExportStore) GetPendingExport(ctx context.Context, userID int, since time.Time) (*types.Export, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	var pending *types.Export
+	for _, export := range s.db.exports {
+		if export.UserID != userID || export.Status != types.ExportPending || !export.Created.After(since) {
+			continue
+		}
+		if pending == nil || export.Created.After(pending.Created) ||
+			(export.Created.Equal(pending.Created) && export.ID > pending.ID) {
+			pending = export
+		}
+	}
+	if pending == nil {
+		return nil, nil
+	}
+	e := copyExport(pending)
+	e.Data = nil
+
+	return e, nil
+}
+
+func (s *MemoryExportStore) CompleteExport(ctx context.Context, id int64, data []byte) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	export, ok := s.db.exports[id]
+	if !ok || export.Status != types.ExportPending {
+		return fmt.Errorf("unknown pending export ID: %d", id)
+	}
+	now := time.Now().UTC()
+	export.Status = types.ExportReady
+	export.Finished = &now
+	export.Size = len(data)
+	export.Data = append([]byte{}, data...)
+
+	return nil
+}
+
+func (s *MemoryExportStore) FailExport(ctx context.Context, id int64, reason string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	export, ok := s.db.exports[id]
+	if !ok || export.Status != types.ExportPending {
+		return fmt.Errorf("unknown pending export ID: %d", id)
+	}
+	now := time.Now().UTC()
+	export.Status = types.ExportFailed
+	export.Finished = &now
+	export.Error = reason
+
+	return nil
+}
+
+func (s *MemoryExportStore) DeleteExpiredExports(ctx context.Context, before time.Time) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	deleted := 0
+	for id, export := range s.db.exports {
+		if export.Created.Before(before) {
+			delete(s.db.exports, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+func copyExport(e *types.Export) *types.Export {
+	export := *e
+	export.Finished = copyTime(e.Finished)
+	export.Data = append([]byte(nil), e.Data...)
+	return &export
+}
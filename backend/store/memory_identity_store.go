@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryIdentityStore is a thread-safe, in-process implementation of IdentityStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryIdentityStore struct {
+	db *memoryDB
+}
+
+func NewMemoryIdentityStore(s *MemoryTodoStore) *MemoryIdentityStore {
+	return &MemoryIdentityStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryIdentityStore) GetIdentity(ctx context.Context, provider, subject string) (*types.Identity, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, identity := range s.db.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			i := *identity
+			return &i, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown identity")
+}
+
+// Inserts a “*types.Identity“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryIdentityStore) InsertIdentity(ctx context.Context, i *types.Identity) (*types.Identity, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.db.users[i.UserID]; !ok {
+		return nil, fmt.Errorf("unknown user ID: %d", i.UserID)
+	}
+	// Mirrors the unique constraint of PostgreSQL.
+	for _, identity := range s.db.identities {
+		if identity.Provider == i.Provider && identity.Subject == i.Subject {
+			return nil, fmt.Errorf("the identity is linked already")
+		}
+	}
+	s.db.nextIdentityID++
+	i.ID = s.db.nextIdentityID
+	identity := *i
+	s.db.identities[i.ID] = &identity
+
+	return i, nil
+}
+
+func (s *MemoryIdentityStore) GetIdentities(ctx context.Context, userID int) ([]*types.Identity, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	identities := []*types.Identity{}
+	for _, identity := range s.db.identities {
+		if identity.UserID == userID {
+			i := *identity
+			identities = append(identities, &i)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		if !identities[i].Created.Equal(identities[j].Created) {
+			return identities[i].Created.Before(identities[j].Created)
+		}
+		return identities[i].ID < identities[j].ID
+	})
+
+	return identities, nil
+}
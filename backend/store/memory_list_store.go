@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryListStore is a thread-safe, in-process implementation of ListStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryListStore struct {
+	db *memoryDB
+}
+
+func NewMemoryListStore(s *MemoryTodoStore) *MemoryListStore {
+	return &MemoryListStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryListStore) GetLists(ctx context.Context, userID int, archived bool) ([]*types.List, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	lists := []*types.List{}
+	for _, list := range s.db.lists {
+		if list.CreatedBy == userID && (!list.Archived || archived) {
+			l := *list
+			lists = append(lists, &l)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Position != lists[j].Position {
+			return lists[i].Position < lists[j].Position
+		}
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists, nil
+}
+
+func (s *MemoryListStore) GetListByID(ctx context.Context, id int64, userID int) (*types.List, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	list, ok := s.ownedList(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown list ID: %d", id)
+	}
+	l := *list
+
+	return &l, nil
+}
+
+// Inserts a “*types.List“ and mutates the “ID“ and “Created“ properties to those generated.
+func (s *MemoryListStore) InsertList(ctx context.Context, l *types.List) (*types.List, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if l.Inbox && s.db.inbox(l.CreatedBy) != nil {
+		return nil, fmt.Errorf("inbox exists already")
+	}
+
+	s.db.nextListID++
+	l.ID = s.db.nextListID
+	l.Created = time.Now().UTC()
+	list := *l
+	s.db.lists[l.ID] = &list
+
+	return l, nil
+}
+
+func (s *MemoryListStore) PatchListByID(ctx context.Context, id int64, userID int, l types.UpdateListParams) (*types.List, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	list, ok := s.ownedList(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown list ID: %d", id)
+	}
+	if list.Inbox && l.Archived != nil && *l.Archived {
+		return nil, fmt.Errorf("the inbox can't be archived")
+	}
+
+	if l.Name != nil {
+		list.Name = *l.Name
+	}
+	if l.Description != nil {
+		list.Description = *l.Description
+	}
+	if l.Archived != nil {
+		list.Archived = *l.Archived
+	}
+	if l.Position != nil {
+		list.Position = *l.Position
+	}
+	patched := *list
+
+	return &patched, nil
+}
+
+// Deletes the list and moves its todos to the inbox of the user.
+func (s *MemoryListStore) DeleteListByID(ctx context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	list, ok := s.ownedList(id, userID)
+	if !ok {
+		return fmt.Errorf("unknown list ID: %d", id)
+	}
+	if list.Inbox {
+		return fmt.Errorf("the inbox can't be deleted")
+	}
+
+	inbox, _ := s.db.todoListID(nil, userID)
+	for _, todo := range s.db.todos {
+		if todo.ListID != nil && *todo.ListID == id {
+			todo.ListID = copyID(inbox)
+		}
+	}
+	delete(s.db.lists, id)
+
+	return nil
+}
+
+// ownedList expects the caller to hold the lock.
+func (s *MemoryListStore) ownedList(id int64, userID int) (*types.List, bool) {
+	list, ok := s.db.lists[id]
+	if !ok || list.CreatedBy != userID {
+		return nil, false
+	}
+	return list, true
+}
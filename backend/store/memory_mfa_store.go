@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryMFAStore is a thread-safe, in-process implementation of MFAStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryMFAStore struct {
+	db *memoryDB
+}
+
+func NewMemoryMFAStore(s *MemoryTodoStore) *MemoryMFAStore {
+	return &MemoryMFAStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryMFAStore) GetMFA(ctx context.Context, userID int) (*types.MFA, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	if mfa, ok := s.db.mfa[userID]; ok {
+		m := *mfa
+		return &m, nil
+	}
+
+	return &types.MFA{UserID: userID}, nil
+}
+
+func (s *MemoryMFAStore) SetMFASecret(ctx context.Context, userID int, secret string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if mfa, ok := s.db.mfa[userID]; ok && mfa.Enabled {
+		return fmt.Errorf("two-factor authentication is enabled already")
+	}
+	s.db.mfa[userID] = &types.MFA{
+		UserID:  userID,
+		Secret:  secret,
+		Created: time.Now().UTC(),
+	}
+
+	return nil
+}
+
+func (s *MemoryMFAStore) EnableMFA(ctx context.Context, userID int, codeHashes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	mfa, ok := s.db.mfa[userID]
+	if !ok || mfa.Enabled {
+		return fmt.Errorf("two-factor authentication is not pending")
+	}
+	mfa.Enabled = true
+	s.db.setRecoveryCodes(userID, codeHashes)
+
+	return nil
+}
+
+func (s *MemoryMFAStore) DisableMFA(ctx context.Context, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	delete(s.db.mfa, userID)
+	delete(s.db.recoveryCodes, userID)
+
+	return nil
+}
+
+func (s *MemoryMFAStore) UseTOTPCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	mfa, ok := s.db.mfa[userID]
+	if !ok || mfa.LastCounter >= counter {
+		return false, nil
+	}
+	mfa.LastCounter = counter
+
+	return true, nil
+}
+
+func (s *MemoryMFAStore) SetRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if mfa, ok := s.db.mfa[userID]; !ok || !mfa.Enabled {
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+	s.db.setRecoveryCodes(userID, codeHashes)
+
+	return nil
+}
+
+func (s *MemoryMFAStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	codes := s.db.recoveryCodes[userID]
+	if used, ok := codes[hash]; !ok || used {
+		return false, nil
+	}
+	codes[hash] = true
+
+	return true, nil
+}
+
+func (s *MemoryMFAStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	count := 0
+	for _, used := range s.db.recoveryCodes[userID] {
+		if !used {
+			count++
+		}
+	}
+
+	return count, nil
+}
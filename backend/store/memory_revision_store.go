@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryRevisionStore is a thread-safe, in-process implementation of TodoRevisionStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryRevisionStore struct {
+	db *memoryDB
+}
+
+func NewMemoryRevisionStore(s *MemoryTodoStore) *MemoryRevisionStore {
+	return &MemoryRevisionStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryRevisionStore) GetTodoRevisions(ctx context.Context, todoID int64, userID int) ([]*types.TodoRevision, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	if todo, ok := s.db.todos[todoID]; !ok || todo.CreatedBy != userID || todo.DeletedAt != nil {
+		return nil, fmt.Errorf("unknown todo ID: %d", todoID)
+	}
+
+	revisions := []*types.TodoRevision{}
+	for _, revision := range s.db.todoRevisions {
+		if revision.TodoID != todoID {
+			continue
+		}
+		r := *revision
+		r.Changes = []*types.TodoChange{}
+		for _, change := range revision.Changes {
+			c := *change
+			r.Changes = append(r.Changes, &c)
+		}
+		revisions = append(revisions, &r)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].ID > revisions[j].ID
+	})
+
+	return revisions, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemorySessionStore is a thread-safe, in-process implementation of SessionStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemorySessionStore struct {
+	db *memoryDB
+}
+
+func NewMemorySessionStore(s *MemoryTodoStore) *MemorySessionStore {
+	return &MemorySessionStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.Session“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemorySessionStore) CreateSession(ctx context.Context, session *types.Session) (*types.Session, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.nextSessionID++
+	session.ID = s.db.nextSessionID
+	s.db.sessions[session.ID] = copySession(session)
+
+	return session, nil
+}
+
+func (s *MemorySessionStore) GetSessions(ctx context.Context, userID int) ([]*types.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	sessions := []*types.Session{}
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeen.Equal(sessions[j].LastSeen) {
+			return sessions[i].LastSeen.After(sessions[j].LastSeen)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+
+	return sessions, nil
+}
+
+func (s *MemorySessionStore) GetSessionByID(ctx context.Context, id int64, userID int) (*types.Session, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	session, ok := s.db.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return nil, fmt.Errorf("unknown session ID: %d", id)
+	}
+
+	return copySession(session), nil
+}
+
+func (s *MemorySessionStore) TouchSession(ctx context.Context, id int64, lastSeen time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if session, ok := s.db.sessions[id]; ok {
+		session.LastSeen = lastSeen.UTC()
+	}
+
+	return nil
+}
+
+func (s *MemorySessionStore) RevokeSession(ctx context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	session, ok := s.db.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return fmt.Errorf("unknown session ID: %d", id)
+	}
+	s.db.revokeSession(session, time.Now().UTC())
+
+	return nil
+}
+
+func (s *MemorySessionStore) RevokeUserSessions(ctx context.Context, userID int, except int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, session := range s.db.sessions {
+		if session.UserID == userID && session.ID != except && session.RevokedAt == nil {
+			s.db.revokeSession(session, now)
+		}
+	}
+
+	return nil
+}
+
+func copySession(s *types.Session) *types.Session {
+	session := *s
+	session.RevokedAt = copyTime(s.RevokedAt)
+	return &session
+}
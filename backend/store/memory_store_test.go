@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+func TestMemoryTodoStoreConcurrentInserts(t *testing.T) {
+	s := NewMemoryTodoStore()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			todo := &types.Todo{Title: fmt.Sprintf("title %d", i), Content: "content", CreatedBy: 1}
+			if _, err := s.InsertTodo(context.TODO(), todo); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	page, err := s.GetTodos(context.TODO(), types.TodoQuery{UserID: 1, Limit: 100, Sort: types.TodoSortCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 50 || len(page.Todos) != 50 {
+		t.Fatalf("expected 50 todos, got %d", len(page.Todos))
+	}
+	ids := map[int64]bool{}
+	for _, todo := range page.Todos {
+		ids[todo.ID] = true
+	}
+	for i := range page.Todos {
+		if !ids[int64(i+1)] {
+			t.Fatalf("expected a todo with ID %d", i+1)
+		}
+	}
+}
+
+func TestMemoryTodoStoreUnknownID(t *testing.T) {
+	s := NewMemoryTodoStore()
+
+	if _, err := s.GetTodoByID(context.TODO(), 1, 1); err == nil {
+		t.Error("expected an error when fetching an unknown todo")
+	}
+	if err := s.DeleteTodoByID(context.TODO(), 1, 1); err == nil {
+		t.Error("expected an error when deleting an unknown todo")
+	}
+	title := "title"
+	if _, err := s.PatchTodoByID(context.TODO(), 1, 1, types.UpdateTodoParams{Title: &title}); err == nil {
+		t.Error("expected an error when patching an unknown todo")
+	}
+}
+
+func TestMemoryUserStoreDuplicateEmail(t *testing.T) {
+	s := NewMemoryUserStore(NewMemoryTodoStore())
+
+	if _, err := s.CreateUser(context.TODO(), &types.User{Email: "user@domain.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateUser(context.TODO(), &types.User{Email: "user@domain.com"}); err == nil {
+		t.Fatal("expected an error when creating a user with an existing email")
+	}
+}
+
+func TestMemoryUserStoreReturnsCopies(t *testing.T) {
+	s := NewMemoryUserStore(NewMemoryTodoStore())
+
+	user, err := s.CreateUser(context.TODO(), &types.User{Email: "user@domain.com", EncryptedPassword: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetUserByEmail(context.TODO(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.EncryptedPassword = ""
+
+	got, err = s.GetUserByID(context.TODO(), int64(user.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.EncryptedPassword != "secret" {
+		t.Fatalf("expected the stored password to be untouched, got %q", got.EncryptedPassword)
+	}
+}
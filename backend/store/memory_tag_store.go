@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryTagStore is a thread-safe, in-process implementation of TagStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryTagStore struct {
+	db *memoryDB
+}
+
+func NewMemoryTagStore(s *MemoryTodoStore) *MemoryTagStore {
+	return &MemoryTagStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryTagStore) GetTags(ctx context.Context, userID int) ([]*types.Tag, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	tags := []*types.Tag{}
+	for _, tag := range s.db.tags {
+		if tag.CreatedBy == userID {
+			t := *tag
+			tags = append(tags, &t)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (s *MemoryTagStore) GetTagByID(ctx context.Context, id int64, userID int) (*types.Tag, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	tag, ok := s.ownedTag(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown tag ID: %d", id)
+	}
+	t := *tag
+
+	return &t, nil
+}
+
+// Inserts a “*types.Tag“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryTagStore) InsertTag(ctx context.Context, t *types.Tag) (*types.Tag, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.nameTaken(t.Name, t.CreatedBy, 0) {
+		return nil, fmt.Errorf("%w: %s", ErrTagExists, t.Name)
+	}
+
+	s.db.nextTagID++
+	t.ID = s.db.nextTagID
+	tag := *t
+	s.db.tags[t.ID] = &tag
+
+	return t, nil
+}
+
+func (s *MemoryTagStore) PatchTagByID(ctx context.Context, id int64, userID int, t types.UpdateTagParams) (*types.Tag, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	tag, ok := s.ownedTag(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown tag ID: %d", id)
+	}
+	if t.Name != nil && s.nameTaken(*t.Name, userID, id) {
+		return nil, fmt.Errorf("%w: %s", ErrTagExists, *t.Name)
+	}
+
+	if t.Name != nil {
+		tag.Name = *t.Name
+	}
+	if t.Color != nil {
+		tag.Color = *t.Color
+	}
+	patched := *tag
+
+	return &patched, nil
+}
+
+// Deletes the tag and removes it from every todo.
+func (s *MemoryTagStore) DeleteTagByID(ctx context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.ownedTag(id, userID); !ok {
+		return fmt.Errorf("unknown tag ID: %d", id)
+	}
+	delete(s.db.tags, id)
+	for todoID, tagIDs := range s.db.todoTags {
+		remaining := []int64{}
+		for _, tagID := range tagIDs {
+			if tagID != id {
+				remaining = append(remaining, tagID)
+			}
+		}
+		s.db.todoTags[todoID] = remaining
+	}
+
+	return nil
+}
+
+// ownedTag expects the caller to hold the lock.
+func (s *MemoryTagStore) ownedTag(id int64, userID int) (*types.Tag, bool) {
+	tag, ok := s.db.tags[id]
+	if !ok || tag.CreatedBy != userID {
+		return nil, false
+	}
+	return tag, true
+}
+
+// nameTaken reports whether another tag of the user has the name, the caller needs to hold the lock.
+func (s *MemoryTagStore) nameTaken(name string, userID int, except int64) bool {
+	for _, tag := range s.db.tags {
+		if tag.CreatedBy == userID && tag.Name == name && tag.ID != except {
+			return true
+		}
+	}
+	return false
+}
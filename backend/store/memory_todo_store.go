@@ -0,0 +1,512 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryTodoStore is a thread-safe, in-process implementation of TodoStorer.
+// It is meant for tests and local development and loses everything on exit.
+type MemoryTodoStore struct {
+	db *memoryDB
+}
+
+func NewMemoryTodoStore() *MemoryTodoStore {
+	return &MemoryTodoStore{
+		db: newMemoryDB(),
+	}
+}
+
+func (s *MemoryTodoStore) Close() error {
+	return nil
+}
+
+func (s *MemoryTodoStore) GetTodos(ctx context.Context, q types.TodoQuery) (*types.TodoPage, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	matching := []*types.Todo{}
+	for _, todo := range s.db.todos {
+		if s.matchesTodoQuery(q, todo) {
+			matching = append(matching, todo)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return q.Less(matching[i], matching[j])
+	})
+
+	page := &types.TodoPage{Todos: []*types.Todo{}, Total: len(matching)}
+	for _, todo := range matching {
+		if !q.AfterCursor(todo) {
+			continue
+		}
+		if len(page.Todos) == q.Limit {
+			page.Next = q.CursorFor(page.Todos[q.Limit-1])
+			break
+		}
+		page.Todos = append(page.Todos, s.db.todo(todo))
+	}
+
+	return page, nil
+}
+
+// SearchTodos is a naive fallback for the full-text search of the PostgreSQL store. Every
+// word of the query needs to be a case-insensitive substring of the title or the content.
+func (s *MemoryTodoStore) SearchTodos(ctx context.Context, userID int, search string, limit int) ([]*types.TodoSearchResult, error) {
+	terms := strings.Fields(strings.ToLower(search))
+	results := []*types.TodoSearchResult{}
+	if len(terms) == 0 {
+		return results, nil
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	highlight := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, todo := range s.db.todos {
+		if todo.CreatedBy != userID || todo.DeletedAt != nil {
+			continue
+		}
+		rank, ok := memorySearchRank(todo, terms)
+		if !ok {
+			continue
+		}
+		results = append(results, &types.TodoSearchResult{
+			Todo:    s.db.todo(todo),
+			Rank:    rank,
+			Snippet: memorySnippet(highlight, todo.Title+" - "+todo.Content),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Todo.ID > results[j].Todo.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// memorySnippet escapes the HTML in the text and wraps the matches in <b></b>.
+func memorySnippet(highlight *regexp.Regexp, text string) string {
+	var (
+		snippet strings.Builder
+		last    int
+	)
+	for _, match := range highlight.FindAllStringIndex(text, -1) {
+		snippet.WriteString(html.EscapeString(text[last:match[0]]))
+		snippet.WriteString("<b>" + html.EscapeString(text[match[0]:match[1]]) + "</b>")
+		last = match[1]
+	}
+	snippet.WriteString(html.EscapeString(text[last:]))
+	return snippet.String()
+}
+
+// memorySearchRank weighs matches in the title higher than in the content,
+// like the weights of the tsvector in the PostgreSQL store.
+func memorySearchRank(t *types.Todo, terms []string) (float64, bool) {
+	var (
+		rank    float64
+		title   = strings.ToLower(t.Title)
+		content = strings.ToLower(t.Content)
+	)
+	for _, term := range terms {
+		inTitle, inContent := strings.Count(title, term), strings.Count(content, term)
+		if inTitle+inContent == 0 {
+			return 0, false
+		}
+		rank += float64(inTitle) + 0.4*float64(inContent)
+	}
+	return rank, true
+}
+
+// matchesTodoQuery mirrors the filters of the PostgreSQL store, the cursor excluded.
+// The caller needs to hold the lock.
+func (s *MemoryTodoStore) matchesTodoQuery(q types.TodoQuery, t *types.Todo) bool {
+	if t.CreatedBy != q.UserID || t.DeletedAt != nil {
+		return false
+	}
+	if q.Done != nil && t.Done != *q.Done {
+		return false
+	}
+	if q.CreatedAfter != nil && !t.Created.After(*q.CreatedAfter) {
+		return false
+	}
+	if q.CreatedBy != nil && t.CreatedBy != *q.CreatedBy {
+		return false
+	}
+	if q.DueFrom != nil && (t.DueAt == nil || t.DueAt.Before(*q.DueFrom)) {
+		return false
+	}
+	if q.DueBefore != nil && (t.DueAt == nil || !t.DueAt.Before(*q.DueBefore)) {
+		return false
+	}
+	if q.ListID != nil && (t.ListID == nil || *t.ListID != *q.ListID) {
+		return false
+	}
+	if q.ParentID != nil && (t.ParentID == nil || *t.ParentID != *q.ParentID) {
+		return false
+	}
+	if len(q.Tags) > 0 && !s.db.hasTags(t.ID, q.Tags, q.TagsAll) {
+		return false
+	}
+	return true
+}
+
+func (s *MemoryTodoStore) GetTodoByID(ctx context.Context, id int64, userID int) (*types.Todo, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	todo, ok := s.ownedTodo(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown ID: %d", id)
+	}
+
+	return s.db.todo(todo), nil
+}
+
+// Inserts a “*types.Todo“ and mutates the “ID“ property to that of the generated ID.
+// Todos without a list are put in the inbox of the user.
+func (s *MemoryTodoStore) InsertTodo(ctx context.Context, t *types.Todo) (*types.Todo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if err := s.db.insertTodo(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (s *MemoryTodoStore) UpdateTodoByID(ctx context.Context, t types.UpdateTodoParams, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	todo, ok := s.ownedTodo(id, userID)
+	if !ok {
+		return fmt.Errorf("unknown todo ID: %d", id)
+	}
+	before := s.db.todo(todo)
+	var tagIDs []int64
+	if t.TagIDs != nil {
+		tagIDs = *t.TagIDs
+	}
+	if err := s.db.checkTags(userID, tagIDs); err != nil {
+		return err
+	}
+	listID, err := s.db.todoListID(t.ListID, userID)
+	if err != nil {
+		return err
+	}
+	if t.ParentID != nil {
+		if err := s.db.checkTodoParent(id, *t.ParentID, userID); err != nil {
+			return err
+		}
+	}
+
+	// Mirrors the PostgreSQL store where omitted fields are written as NULL.
+	updated := &types.Todo{ID: todo.ID, Priority: types.PriorityNormal, ListID: listID}
+	if t.Title != nil {
+		updated.Title = *t.Title
+	}
+	if t.Content != nil {
+		updated.Content = *t.Content
+	}
+	if t.Created != nil {
+		updated.Created = *t.Created
+	}
+	if t.Updated != nil {
+		updated.Updated = copyTime(t.Updated)
+	}
+	if t.CreatedBy != nil {
+		updated.CreatedBy = *t.CreatedBy
+	}
+	if t.UpdatedBy != nil {
+		updatedBy := int64(*t.UpdatedBy)
+		updated.UpdatedBy = &updatedBy
+	}
+	if t.Done != nil {
+		updated.Done = *t.Done
+	}
+	updated.DueAt = utcTime(t.DueAt)
+	if t.Priority != nil {
+		updated.Priority = *t.Priority
+	}
+	updated.ParentID = copyID(t.ParentID)
+	if t.AutoComplete != nil {
+		updated.AutoComplete = *t.AutoComplete
+	}
+	if t.Recurrence != nil {
+		updated.Recurrence = *t.Recurrence
+	}
+	s.db.todos[id] = updated
+
+	if err := s.db.setTodoTags(id, userID, tagIDs); err != nil {
+		return err
+	}
+	if err := s.db.autoCompleteTodo(id); err != nil {
+		return err
+	}
+	if todo.ParentID != nil {
+		if err := s.db.autoCompleteTodo(*todo.ParentID); err != nil {
+			return err
+		}
+	}
+	return s.db.insertTodoRevision(before, s.db.todo(updated), todoEditor(t, userID))
+}
+
+func (s *MemoryTodoStore) DeleteTodoByID(ctx context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	todo, ok := s.ownedTodo(id, userID)
+	if !ok {
+		return fmt.Errorf("unknown id: %d", id)
+	}
+	s.db.trashTodo(id, time.Now().UTC())
+	if todo.ParentID != nil {
+		return s.db.autoCompleteTodo(*todo.ParentID)
+	}
+
+	return nil
+}
+
+func (s *MemoryTodoStore) PatchTodoByID(ctx context.Context, id int64, userID int, t types.UpdateTodoParams) (*types.Todo, error) {
+	if t.Empty() {
+		return nil, fmt.Errorf("nothing to patch")
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	todo, ok := s.ownedTodo(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown ID: %d", id)
+	}
+	before := s.db.todo(todo)
+	if t.ListID != nil {
+		if _, err := s.db.todoListID(t.ListID, userID); err != nil {
+			return nil, err
+		}
+	}
+	if t.ParentID != nil {
+		if err := s.db.checkTodoParent(id, *t.ParentID, userID); err != nil {
+			return nil, err
+		}
+	}
+	if t.TagIDs != nil {
+		if err := s.db.setTodoTags(id, userID, *t.TagIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.Title != nil {
+		todo.Title = *t.Title
+	}
+	if t.Content != nil {
+		todo.Content = *t.Content
+	}
+	if t.Created != nil {
+		todo.Created = *t.Created
+	}
+	if t.Updated != nil {
+		todo.Updated = copyTime(t.Updated)
+	}
+	if t.CreatedBy != nil {
+		todo.CreatedBy = *t.CreatedBy
+	}
+	if t.UpdatedBy != nil {
+		updatedBy := int64(*t.UpdatedBy)
+		todo.UpdatedBy = &updatedBy
+	}
+	if t.Done != nil {
+		todo.Done = *t.Done
+	}
+	if t.DueAt != nil {
+		todo.DueAt = utcTime(t.DueAt)
+	}
+	if t.Priority != nil {
+		todo.Priority = *t.Priority
+	}
+	if t.ListID != nil {
+		todo.ListID = copyID(t.ListID)
+	}
+	oldParentID := todo.ParentID
+	if t.ParentID != nil {
+		todo.ParentID = copyID(t.ParentID)
+	}
+	if t.AutoComplete != nil {
+		todo.AutoComplete = *t.AutoComplete
+	}
+	if t.Recurrence != nil {
+		todo.Recurrence = *t.Recurrence
+	}
+
+	if err := s.db.autoCompleteTodo(id); err != nil {
+		return nil, err
+	}
+	if oldParentID != nil {
+		if err := s.db.autoCompleteTodo(*oldParentID); err != nil {
+			return nil, err
+		}
+	}
+	if !before.Done && todo.Done {
+		if err := s.db.spawnNextOccurrence(todo); err != nil {
+			return nil, err
+		}
+	}
+
+	patched := s.db.todo(todo)
+	if err := s.db.insertTodoRevision(before, patched, todoEditor(t, userID)); err != nil {
+		return nil, err
+	}
+
+	return patched, nil
+}
+
+func (s *MemoryTodoStore) GetTrash(ctx context.Context, userID int) ([]*types.Todo, error) {
+	return s.getTrashedTodos(userID, true), nil
+}
+
+func (s *MemoryTodoStore) GetTrashedTodos(ctx context.Context, userID int) ([]*types.Todo, error) {
+	return s.getTrashedTodos(userID, false), nil
+}
+
+// getTrashedTodos mirrors the function of the PostgreSQL store.
+func (s *MemoryTodoStore) getTrashedTodos(userID int, topLevel bool) []*types.Todo {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	todos := []*types.Todo{}
+	for _, todo := range s.db.todos {
+		if todo.CreatedBy != userID || todo.DeletedAt == nil {
+			continue
+		}
+		if topLevel && todo.ParentID != nil && s.db.todos[*todo.ParentID].DeletedAt != nil {
+			continue
+		}
+		todos = append(todos, s.db.todo(todo))
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].DeletedAt.Equal(*todos[j].DeletedAt) {
+			return todos[i].DeletedAt.After(*todos[j].DeletedAt)
+		}
+		return todos[i].ID > todos[j].ID
+	})
+
+	return todos
+}
+
+func (s *MemoryTodoStore) RestoreTodoByID(ctx context.Context, id int64, userID int) (*types.Todo, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	todo, ok := s.trashedTodo(id, userID)
+	if !ok {
+		return nil, fmt.Errorf("unknown trashed todo ID: %d", id)
+	}
+	if todo.ParentID != nil && s.db.todos[*todo.ParentID].DeletedAt != nil {
+		return nil, ErrTrashedParent
+	}
+	s.db.restoreTodo(id, *todo.DeletedAt)
+	if todo.ParentID != nil {
+		if err := s.db.autoCompleteTodo(*todo.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.db.todo(todo), nil
+}
+
+func (s *MemoryTodoStore) DeleteTrashedTodoByID(ctx context.Context, id int64, userID int) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if _, ok := s.trashedTodo(id, userID); !ok {
+		return fmt.Errorf("unknown trashed todo ID: %d", id)
+	}
+	s.db.deleteTodo(id)
+
+	return nil
+}
+
+func (s *MemoryTodoStore) EmptyTrash(ctx context.Context, userID int) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.deleteTrashedTodos(func(t *types.Todo) bool {
+		return t.CreatedBy == userID
+	}), nil
+}
+
+func (s *MemoryTodoStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	return s.db.deleteTrashedTodos(func(t *types.Todo) bool {
+		return t.DeletedAt.Before(before)
+	}), nil
+}
+
+// ownedTodo returns the todo unless it is trashed, the caller needs to hold the lock.
+func (s *MemoryTodoStore) ownedTodo(id int64, userID int) (*types.Todo, bool) {
+	todo, ok := s.db.todos[id]
+	if !ok || todo.CreatedBy != userID || todo.DeletedAt != nil {
+		return nil, false
+	}
+	return todo, true
+}
+
+// trashedTodo expects the caller to hold the lock.
+func (s *MemoryTodoStore) trashedTodo(id int64, userID int) (*types.Todo, bool) {
+	todo, ok := s.db.todos[id]
+	if !ok || todo.CreatedBy != userID || todo.DeletedAt == nil {
+		return nil, false
+	}
+	return todo, true
+}
+
+// copyTodo returns a deep copy so callers never share memory with the store.
+func copyTodo(t *types.Todo) *types.Todo {
+	todo := *t
+	todo.Updated = copyTime(t.Updated)
+	todo.DueAt = copyTime(t.DueAt)
+	todo.DeletedAt = copyTime(t.DeletedAt)
+	todo.ListID = copyID(t.ListID)
+	todo.ParentID = copyID(t.ParentID)
+	if t.UpdatedBy != nil {
+		updatedBy := *t.UpdatedBy
+		todo.UpdatedBy = &updatedBy
+	}
+	return &todo
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyID(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
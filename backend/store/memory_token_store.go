@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryTokenStore is a thread-safe, in-process implementation of TokenStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryTokenStore struct {
+	db *memoryDB
+}
+
+func NewMemoryTokenStore(s *MemoryTodoStore) *MemoryTokenStore {
+	return &MemoryTokenStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.RefreshToken“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryTokenStore) CreateRefreshToken(ctx context.Context, t *types.RefreshToken) (*types.RefreshToken, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.nextRefreshTokenID++
+	t.ID = s.db.nextRefreshTokenID
+	s.db.refreshTokens[t.ID] = copyRefreshToken(t)
+
+	return t, nil
+}
+
+func (s *MemoryTokenStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, token := range s.db.refreshTokens {
+		if token.TokenHash == hash {
+			return copyRefreshToken(token), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown refresh token")
+}
+
+func (s *MemoryTokenStore) UseRefreshToken(ctx context.Context, id int64) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	token, ok := s.db.refreshTokens[id]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	token.UsedAt = &now
+
+	return true, nil
+}
+
+// Inserts a “*types.PasswordResetToken“ and mutates the “ID“ property to that of the generated ID.
+func (s *MemoryTokenStore) CreatePasswordResetToken(ctx context.Context, t *types.PasswordResetToken) (*types.PasswordResetToken, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.nextPasswordResetTokenID++
+	t.ID = s.db.nextPasswordResetTokenID
+	s.db.passwordResetTokens[t.ID] = copyPasswordResetToken(t)
+
+	return t, nil
+}
+
+func (s *MemoryTokenStore) GetPasswordResetTokenByHash(ctx context.Context, hash string) (*types.PasswordResetToken, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	for _, token := range s.db.passwordResetTokens {
+		if token.TokenHash == hash {
+			return copyPasswordResetToken(token), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown password reset token")
+}
+
+func (s *MemoryTokenStore) UsePasswordResetToken(ctx context.Context, id int64) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now().UTC()
+	token, ok := s.db.passwordResetTokens[id]
+	if !ok || token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return false, nil
+	}
+	for _, t := range s.db.passwordResetTokens {
+		if t.UserID == token.UserID && t.UsedAt == nil {
+			usedAt := now
+			t.UsedAt = &usedAt
+		}
+	}
+
+	return true, nil
+}
+
+func copyRefreshToken(t *types.RefreshToken) *types.RefreshToken {
+	token := *t
+	token.UsedAt = copyTime(t.UsedAt)
+	token.RevokedAt = copyTime(t.RevokedAt)
+	return &token
+}
+
+func copyPasswordResetToken(t *types.PasswordResetToken) *types.PasswordResetToken {
+	token := *t
+	token.UsedAt = copyTime(t.UsedAt)
+	return &token
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// MemoryUserStore is a thread-safe, in-process implementation of UserStorer.
+// It shares its tables with the MemoryTodoStore it was created from.
+type MemoryUserStore struct {
+	db *memoryDB
+}
+
+func NewMemoryUserStore(s *MemoryTodoStore) *MemoryUserStore {
+	return &MemoryUserStore{
+		db: s.db,
+	}
+}
+
+func (s *MemoryUserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	users := []*types.User{}
+	for _, user := range s.db.users {
+		users = append(users, copyUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+
+	return users, nil
+}
+
+func (s *MemoryUserStore) GetUserByID(ctx context.Context, id int64) (*types.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return nil, fmt.Errorf("unknown ID: %d", id)
+	}
+
+	return copyUser(user), nil
+}
+
+func (s *MemoryUserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	user := s.userByEmail(email)
+	if user == nil {
+		return nil, fmt.Errorf("unknown email: %s", email)
+	}
+
+	return copyUser(user), nil
+}
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, u *types.User) (*types.User, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if s.userByEmail(u.Email) != nil {
+		return nil, fmt.Errorf("user exists already")
+	}
+
+	s.db.nextUserID++
+	u.ID = s.db.nextUserID
+	s.db.users[u.ID] = copyUser(u)
+
+	return u, nil
+}
+
+func (s *MemoryUserStore) UpdateUserPasswordByID(ctx context.Context, password string, id int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+	user.EncryptedPassword = password
+
+	return nil
+}
+
+func (s *MemoryUserStore) SetUserVerifiedByID(ctx context.Context, id int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+	user.Verified = true
+
+	return nil
+}
+
+func (s *MemoryUserStore) SetUserRoleByID(ctx context.Context, id int64, role string) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+	user.Role = role
+
+	return nil
+}
+
+func (s *MemoryUserStore) SetUserDisabledByID(ctx context.Context, id int64, disabled bool) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+	user.Disabled = disabled
+
+	return nil
+}
+
+func (s *MemoryUserStore) MarkVerificationSent(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now().UTC()
+	sentAt, ok := s.db.verificationSentAt[int(id)]
+	if ok && now.Sub(sentAt) < interval {
+		return false, nil
+	}
+	s.db.verificationSentAt[int(id)] = now
+
+	return true, nil
+}
+
+func (s *MemoryUserStore) MarkPasswordResetSent(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	now := time.Now().UTC()
+	sentAt, ok := s.db.passwordResetSentAt[int(id)]
+	if ok && now.Sub(sentAt) < interval {
+		return false, nil
+	}
+	s.db.passwordResetSentAt[int(id)] = now
+
+	return true, nil
+}
+
+func (s *MemoryUserStore) ScheduleUserDeletion(ctx context.Context, id int64, at time.Time) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	user, ok := s.db.users[int(id)]
+	if !ok {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+	at = at.UTC()
+	user.DeleteAt = &at
+
+	return nil
+}
+
+func (s *MemoryUserStore) CancelUserDeletion(ctx context.Context, id int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if user, ok := s.db.users[int(id)]; ok {
+		user.DeleteAt = nil
+	}
+
+	return nil
+}
+
+func (s *MemoryUserStore) DeleteUserByID(ctx context.Context, id int64) error {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	s.db.deleteUser(int(id))
+
+	return nil
+}
+
+func (s *MemoryUserStore) PurgeDeletedUsers(ctx context.Context, now time.Time) (int, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	purged := 0
+	for id, user := range s.db.users {
+		if user.DeleteAt != nil && !user.DeleteAt.After(now) {
+			s.db.deleteUser(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// userByEmail expects the caller to hold the lock.
+func (s *MemoryUserStore) userByEmail(email string) *types.User {
+	for _, user := range s.db.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
+
+func copyUser(u *types.User) *types.User {
+	user := *u
+	user.DeleteAt = copyTime(u.DeleteAt)
+	return &user
+}
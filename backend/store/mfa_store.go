@@ -0,0 +1,157 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type MFAStorer interface {
+	// Users without two-factor authentication get a disabled MFA without a secret.
+	GetMFA(context.Context, int) (*types.MFA, error)
+	// Starts the enrollment with a new secret, fails if two-factor authentication is enabled.
+	SetMFASecret(context.Context, int, string) error
+	// Enables a pending enrollment with the hashes of its recovery codes.
+	EnableMFA(context.Context, int, []string) error
+	// Removes the secret and the recovery codes of the user.
+	DisableMFA(context.Context, int) error
+	// Records the time step of a used code, false if it or a later one was used already.
+	UseTOTPCounter(context.Context, int, int64) (bool, error)
+	// Replaces the recovery codes of an enabled two-factor authentication.
+	SetRecoveryCodes(context.Context, int, []string) error
+	// Spends a recovery code by its hash, false if it is unknown or used already.
+	UseRecoveryCode(context.Context, int, string) (bool, error)
+	CountRecoveryCodes(context.Context, int) (int, error)
+}
+
+type PostgreMFAStore struct {
+	db *sql.DB
+}
+
+func NewPostgreMFAStore(s *PostgreTodoStore) *PostgreMFAStore {
+	return &PostgreMFAStore{
+		db: s.db,
+	}
+}
+
+func (s *PostgreMFAStore) GetMFA(ctx context.Context, userID int) (*types.MFA, error) {
+	mfa := types.MFA{UserID: userID}
+	err := s.db.QueryRowContext(ctx, `SELECT secret, enabled, last_counter, created FROM user_mfa WHERE user_id = $1`, userID).
+		Scan(&mfa.Secret, &mfa.Enabled, &mfa.LastCounter, &mfa.Created)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return &mfa, nil
+}
+
+func (s *PostgreMFAStore) SetMFASecret(ctx context.Context, userID int, secret string) error {
+	query := `INSERT INTO user_mfa(user_id, secret, created) VALUES ($1, $2, $3)
+				ON CONFLICT (user_id) DO UPDATE SET secret = $2, created = $3, last_counter = 0 WHERE user_mfa.enabled = false`
+	res, err := s.db.ExecContext(ctx, query, userID, secret, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("two-factor authentication is enabled already")
+	}
+
+	return nil
+}
+
+func (s *PostgreMFAStore) EnableMFA(ctx context.Context, userID int, codeHashes []string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE user_mfa SET enabled = true WHERE user_id = $1 AND enabled = false`, userID)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return fmt.Errorf("two-factor authentication is not pending")
+		}
+
+		return setRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+}
+
+func (s *PostgreMFAStore) DisableMFA(ctx context.Context, userID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_code WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+func (s *PostgreMFAStore) UseTOTPCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE user_mfa SET last_counter = $1 WHERE user_id = $2 AND last_counter < $1`, counter, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (s *PostgreMFAStore) SetRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var enabled bool
+		err := tx.QueryRowContext(ctx, `SELECT enabled FROM user_mfa WHERE user_id = $1 FOR UPDATE`, userID).Scan(&enabled)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if !enabled {
+			return fmt.Errorf("two-factor authentication is not enabled")
+		}
+
+		return setRecoveryCodes(ctx, tx, userID, codeHashes)
+	})
+}
+
+// setRecoveryCodes replaces the recovery codes of the user within the transaction.
+func setRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_code WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO mfa_recovery_code(user_id, code_hash) VALUES ($1, $2)`, userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PostgreMFAStore) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE mfa_recovery_code SET used_at = $1
+				WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`, time.Now().UTC(), userID, hash)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+func (s *PostgreMFAStore) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mfa_recovery_code WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("expected at least one embedded migration")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected migration version %d, got %d", i+1, m.Version)
+		}
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("expected migration %d_%s to have both up and down statements", m.Version, m.Name)
+		}
+	}
+}
+
+func TestParseMigrationsFail(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{
+			name: "Missing down",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "Gap in versions",
+			files: fstest.MapFS{
+				"m/0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/0001_a.down.sql": {Data: []byte("SELECT 1;")},
+				"m/0003_c.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/0003_c.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "Invalid version",
+			files: fstest.MapFS{
+				"m/first_a.up.sql":   {Data: []byte("SELECT 1;")},
+				"m/first_a.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "Unknown file",
+			files: fstest.MapFS{
+				"m/README.md": {Data: []byte("hello")},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseMigrations(tt.files, "m"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS todo (
+	id SERIAL PRIMARY KEY,
+	title VARCHAR(100),
+	content VARCHAR(1000),
+	created TIMESTAMP,
+	updated TIMESTAMP,
+	created_by INTEGER,
+	updated_by INTEGER,
+	done BOOLEAN
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS todo_user (
+	id SERIAL PRIMARY KEY,
+	email VARCHAR(100) UNIQUE,
+	encrypted_password VARCHAR(100)
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS todo_created_by_created_idx;
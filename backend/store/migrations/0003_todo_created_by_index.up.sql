@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS todo_created_by_created_idx ON todo (created_by, created, id);
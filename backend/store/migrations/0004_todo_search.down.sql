@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS todo_search_idx;
+
+ALTER TABLE todo DROP COLUMN IF EXISTS search;
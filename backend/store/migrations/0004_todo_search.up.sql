@@ -0,0 +1,7 @@
+ALTER TABLE todo ADD COLUMN IF NOT EXISTS search tsvector
+	GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+		setweight(to_tsvector('english', COALESCE(content, '')), 'B')
+	) STORED;
+
+CREATE INDEX IF NOT EXISTS todo_search_idx ON todo USING GIN (search);
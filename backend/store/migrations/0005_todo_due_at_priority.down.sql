@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS todo_created_by_due_at_idx;
+
+ALTER TABLE todo
+	DROP COLUMN IF EXISTS priority,
+	DROP COLUMN IF EXISTS due_at;
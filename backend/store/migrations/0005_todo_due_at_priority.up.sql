@@ -0,0 +1,6 @@
+ALTER TABLE todo
+	ADD COLUMN IF NOT EXISTS due_at TIMESTAMP,
+	ADD COLUMN IF NOT EXISTS priority VARCHAR(10) NOT NULL DEFAULT 'normal'
+		CHECK (priority IN ('low', 'normal', 'high', 'urgent'));
+
+CREATE INDEX IF NOT EXISTS todo_created_by_due_at_idx ON todo (created_by, due_at);
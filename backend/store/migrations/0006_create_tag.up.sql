@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS tag (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(50) NOT NULL,
+	color VARCHAR(7) NOT NULL DEFAULT '#808080',
+	created_by INTEGER NOT NULL,
+	UNIQUE (created_by, name)
+);
+
+CREATE TABLE IF NOT EXISTS todo_tag (
+	todo_id INTEGER NOT NULL REFERENCES todo (id) ON DELETE CASCADE,
+	tag_id INTEGER NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+	PRIMARY KEY (todo_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS todo_tag_tag_id_idx ON todo_tag (tag_id);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS todo_list (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	description VARCHAR(1000) NOT NULL DEFAULT '',
+	archived BOOLEAN NOT NULL DEFAULT false,
+	position INTEGER NOT NULL DEFAULT 0,
+	inbox BOOLEAN NOT NULL DEFAULT false,
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	created_by INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS todo_list_inbox_idx ON todo_list (created_by) WHERE inbox;
+
+ALTER TABLE todo ADD COLUMN IF NOT EXISTS list_id INTEGER REFERENCES todo_list (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS todo_list_id_idx ON todo (list_id);
+
+-- Every existing user gets an inbox holding their existing todos.
+INSERT INTO todo_list (name, inbox, created_by)
+	SELECT 'Inbox', true, id FROM todo_user
+	ON CONFLICT DO NOTHING;
+
+UPDATE todo SET list_id = todo_list.id
+	FROM todo_list
+	WHERE todo_list.created_by = todo.created_by AND todo_list.inbox AND todo.list_id IS NULL;
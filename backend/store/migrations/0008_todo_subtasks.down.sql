@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS todo_checklist_item;
+
+DROP INDEX IF EXISTS todo_parent_id_idx;
+
+ALTER TABLE todo DROP COLUMN IF EXISTS auto_complete;
+ALTER TABLE todo DROP COLUMN IF EXISTS parent_id;
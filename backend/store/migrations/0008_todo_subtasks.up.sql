@@ -0,0 +1,14 @@
+ALTER TABLE todo ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES todo (id) ON DELETE CASCADE;
+ALTER TABLE todo ADD COLUMN IF NOT EXISTS auto_complete BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS todo_parent_id_idx ON todo (parent_id);
+
+CREATE TABLE IF NOT EXISTS todo_checklist_item (
+	id SERIAL PRIMARY KEY,
+	todo_id INTEGER NOT NULL REFERENCES todo (id) ON DELETE CASCADE,
+	title VARCHAR(200) NOT NULL,
+	done BOOLEAN NOT NULL DEFAULT false,
+	position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS todo_checklist_item_todo_id_idx ON todo_checklist_item (todo_id);
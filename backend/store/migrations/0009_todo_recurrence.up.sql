@@ -0,0 +1 @@
+ALTER TABLE todo ADD COLUMN IF NOT EXISTS recurrence VARCHAR(200) NOT NULL DEFAULT '';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS refresh_token (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	family_id VARCHAR(64) NOT NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS refresh_token_family_id_idx ON refresh_token (family_id);
+CREATE INDEX IF NOT EXISTS refresh_token_user_id_idx ON refresh_token (user_id);
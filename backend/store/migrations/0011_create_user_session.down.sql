@@ -0,0 +1,7 @@
+DELETE FROM refresh_token;
+DROP INDEX IF EXISTS refresh_token_session_id_idx;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS session_id;
+ALTER TABLE refresh_token ADD COLUMN IF NOT EXISTS family_id VARCHAR(64) NOT NULL;
+CREATE INDEX IF NOT EXISTS refresh_token_family_id_idx ON refresh_token (family_id);
+
+DROP TABLE IF EXISTS user_session;
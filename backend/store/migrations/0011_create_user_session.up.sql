@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_session (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	user_agent VARCHAR(500) NOT NULL DEFAULT '',
+	ip VARCHAR(45) NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	last_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_session_user_id_idx ON user_session (user_id);
+
+-- The session replaces the token family, refresh tokens issued before it have no session to belong to.
+DELETE FROM refresh_token;
+DROP INDEX IF EXISTS refresh_token_family_id_idx;
+ALTER TABLE refresh_token DROP COLUMN IF EXISTS family_id;
+ALTER TABLE refresh_token ADD COLUMN IF NOT EXISTS session_id INTEGER NOT NULL REFERENCES user_session (id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS refresh_token_session_id_idx ON refresh_token (session_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS access_token (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	name VARCHAR(100) NOT NULL,
+	scopes TEXT[] NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP,
+	last_used TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS access_token_user_id_idx ON access_token (user_id);
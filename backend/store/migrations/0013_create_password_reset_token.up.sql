@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS password_reset_token (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	token_hash CHAR(64) NOT NULL UNIQUE,
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS password_reset_token_user_id_idx ON password_reset_token (user_id);
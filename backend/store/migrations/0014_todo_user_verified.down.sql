@@ -0,0 +1,2 @@
+ALTER TABLE todo_user DROP COLUMN IF EXISTS verification_sent_at;
+ALTER TABLE todo_user DROP COLUMN IF EXISTS verified;
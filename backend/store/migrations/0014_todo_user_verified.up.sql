@@ -0,0 +1,5 @@
+ALTER TABLE todo_user ADD COLUMN IF NOT EXISTS verified BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE todo_user ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMP;
+
+-- Users that registered before the verification mails existed keep their access.
+UPDATE todo_user SET verified = true;
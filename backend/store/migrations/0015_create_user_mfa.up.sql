@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_mfa (
+	user_id INTEGER PRIMARY KEY REFERENCES todo_user (id) ON DELETE CASCADE,
+	secret VARCHAR(64) NOT NULL,
+	enabled BOOLEAN NOT NULL DEFAULT false,
+	last_counter BIGINT NOT NULL DEFAULT 0,
+	created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS mfa_recovery_code (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	code_hash CHAR(64) NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS mfa_recovery_code_user_id_idx ON mfa_recovery_code (user_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS auth_event (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INTEGER REFERENCES todo_user (id) ON DELETE CASCADE,
+	email TEXT NOT NULL,
+	event VARCHAR(32) NOT NULL,
+	ip VARCHAR(45) NOT NULL,
+	user_agent VARCHAR(500) NOT NULL,
+	created TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS auth_event_email_idx ON auth_event (email, created);
+CREATE INDEX IF NOT EXISTS auth_event_ip_idx ON auth_event (ip, created);
+CREATE INDEX IF NOT EXISTS auth_event_user_id_idx ON auth_event (user_id, created);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS user_identity (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	provider VARCHAR(64) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identity_user_id_idx ON user_identity (user_id);
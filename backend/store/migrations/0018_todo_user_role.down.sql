@@ -0,0 +1,2 @@
+ALTER TABLE todo_user DROP COLUMN IF EXISTS disabled;
+ALTER TABLE todo_user DROP COLUMN IF EXISTS role;
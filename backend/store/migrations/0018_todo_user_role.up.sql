@@ -0,0 +1,3 @@
+ALTER TABLE todo_user ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user'
+	CHECK (role IN ('user', 'admin'));
+ALTER TABLE todo_user ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+ALTER TABLE todo_user DROP COLUMN IF EXISTS delete_at;
@@ -0,0 +1 @@
+ALTER TABLE todo_user ADD COLUMN IF NOT EXISTS delete_at TIMESTAMP;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_export (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES todo_user (id) ON DELETE CASCADE,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+	error TEXT NOT NULL DEFAULT '',
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	finished TIMESTAMP,
+	size INTEGER NOT NULL DEFAULT 0,
+	data BYTEA
+);
+
+CREATE INDEX IF NOT EXISTS user_export_user_id_idx ON user_export (user_id);
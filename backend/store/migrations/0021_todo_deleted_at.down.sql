@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS todo_deleted_at_idx;
+ALTER TABLE todo DROP COLUMN IF EXISTS deleted_at;
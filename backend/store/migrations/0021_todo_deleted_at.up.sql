@@ -0,0 +1,3 @@
+ALTER TABLE todo ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS todo_deleted_at_idx ON todo (deleted_at) WHERE deleted_at IS NOT NULL;
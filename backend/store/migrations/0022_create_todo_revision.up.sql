@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS todo_revision (
+	id SERIAL PRIMARY KEY,
+	todo_id INTEGER NOT NULL REFERENCES todo (id) ON DELETE CASCADE,
+	changed_by INTEGER NOT NULL,
+	created TIMESTAMP NOT NULL DEFAULT NOW(),
+	changes JSONB NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS todo_revision_todo_id_idx ON todo_revision (todo_id);
@@ -0,0 +1 @@
+ALTER TABLE todo_user DROP COLUMN IF EXISTS password_reset_sent_at;
@@ -0,0 +1 @@
+ALTER TABLE todo_user ADD COLUMN IF NOT EXISTS password_reset_sent_at TIMESTAMP;
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type TodoRevisionStorer interface {
+	// Returns the revisions of the todo, newest first. The revisions are
+	// recorded by the TodoStorer whenever a todo is inserted or changed.
+	GetTodoRevisions(context.Context, int64, int) ([]*types.TodoRevision, error)
+}
+
+type PostgreRevisionStore struct {
+	db *sql.DB
+}
+
+func NewPostgreRevisionStore(s *PostgreTodoStore) *PostgreRevisionStore {
+	return &PostgreRevisionStore{
+		db: s.db,
+	}
+}
+
+func (s *PostgreRevisionStore) GetTodoRevisions(ctx context.Context, todoID int64, userID int) ([]*types.TodoRevision, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NULL)`
+	if err := s.db.QueryRowContext(ctx, query, todoID, userID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("unknown todo ID: %d", todoID)
+	}
+
+	query = `SELECT id, todo_id, changed_by, created, changes FROM todo_revision WHERE todo_id = $1 ORDER BY id DESC`
+	rows, err := s.db.QueryContext(ctx, query, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*types.TodoRevision{}
+	for rows.Next() {
+		var (
+			revision types.TodoRevision
+			changes  []byte
+		)
+		if err := rows.Scan(&revision.ID, &revision.TodoID, &revision.ChangedBy, &revision.Created, &changes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(changes, &revision.Changes); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// lockTodo locks the todo of the user for the rest of the transaction and
+// returns it with its tags, the state a revision is compared against.
+func lockTodo(ctx context.Context, q querier, id int64, userID int) (*types.Todo, error) {
+	var todo types.Todo
+	query := `SELECT ` + todoColumns + ` FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NULL FOR UPDATE`
+	err := q.QueryRowContext(ctx, query, id, userID).Scan(todoFields(&todo)...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("unknown todo ID: %d", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := loadTodoTags(ctx, q, &todo); err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// insertTodoRevision records the fields that changed between before and after,
+// nothing if none did. before is nil if the todo was just inserted.
+func insertTodoRevision(ctx context.Context, q querier, before, after *types.Todo, changedBy int) error {
+	changes, err := types.DiffTodos(before, after)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, `INSERT INTO todo_revision(todo_id, changed_by, changes) VALUES ($1, $2, $3)`, after.ID, changedBy, string(b))
+
+	return err
+}
+
+// todoEditor returns the user that makes the change, the owner unless the
+// params name another one.
+func todoEditor(t types.UpdateTodoParams, userID int) int {
+	if t.UpdatedBy != nil {
+		return *t.UpdatedBy
+	}
+	return userID
+}
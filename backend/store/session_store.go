@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type SessionStorer interface {
+	CreateSession(context.Context, *types.Session) (*types.Session, error)
+	// Every method below is scoped to the user ID and only sees sessions that haven't been revoked.
+	GetSessions(context.Context, int) ([]*types.Session, error)
+	GetSessionByID(context.Context, int64, int) (*types.Session, error)
+	TouchSession(context.Context, int64, time.Time) error
+	// Revoking a session revokes its refresh tokens as well.
+	RevokeSession(context.Context, int64, int) error
+	// Revokes every session of the user but the one with the given ID, which can be 0.
+	RevokeUserSessions(context.Context, int, int64) error
+}
+
+// The columns scanned by scanSession, in order.
+const sessionColumns = `id, user_id, user_agent, ip, created, last_seen, revoked_at`
+
+type PostgreSessionStore struct {
+	db *sql.DB
+}
+
+func NewPostgreSessionStore(s *PostgreTodoStore) *PostgreSessionStore {
+	return &PostgreSessionStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.Session“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreSessionStore) CreateSession(ctx context.Context, session *types.Session) (*types.Session, error) {
+	query := `INSERT INTO user_session(user_id, user_agent, ip, created, last_seen)
+				VALUES                ($1,      $2,         $3, $4,      $5) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, session.UserID, session.UserAgent, session.IP, session.Created.UTC(), session.LastSeen.UTC()).Scan(&session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *PostgreSessionStore) GetSessions(ctx context.Context, userID int) ([]*types.Session, error) {
+	sessions := []*types.Session{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM user_session
+				WHERE user_id = $1 AND revoked_at IS NULL ORDER BY last_seen DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (s *PostgreSessionStore) GetSessionByID(ctx context.Context, id int64, userID int) (*types.Session, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sessionColumns+` FROM user_session
+				WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var session *types.Session
+	for rows.Next() {
+		session, err = scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if session == nil {
+		return nil, fmt.Errorf("unknown session ID: %d", id)
+	}
+
+	return session, nil
+}
+
+func (s *PostgreSessionStore) TouchSession(ctx context.Context, id int64, lastSeen time.Time) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE user_session SET last_seen = $1 WHERE id = $2`, lastSeen.UTC(), id)
+	return err
+}
+
+func (s *PostgreSessionStore) RevokeSession(ctx context.Context, id int64, userID int) error {
+	now := time.Now().UTC()
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE user_session SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+			now, id, userID)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return fmt.Errorf("unknown session ID: %d", id)
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE refresh_token SET revoked_at = $1 WHERE session_id = $2 AND revoked_at IS NULL`, now, id)
+		return err
+	})
+}
+
+func (s *PostgreSessionStore) RevokeUserSessions(ctx context.Context, userID int, except int64) error {
+	now := time.Now().UTC()
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `UPDATE user_session SET revoked_at = $1 WHERE user_id = $2 AND id <> $3 AND revoked_at IS NULL`,
+			now, userID, except)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE refresh_token SET revoked_at = $1 WHERE user_id = $2 AND session_id <> $3 AND revoked_at IS NULL`,
+			now, userID, except)
+		return err
+	})
+}
+
+func scanSession(rows *sql.Rows) (*types.Session, error) {
+	var session types.Session
+	err := rows.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.Created, &session.LastSeen, &session.RevokedAt)
+	return &session, err
+}
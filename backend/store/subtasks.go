@@ -0,0 +1,170 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// ErrInvalidParent is wrapped by the errors about todos that can't be nested
+// below the requested parent.
+var ErrInvalidParent = errors.New("invalid parent")
+
+// ErrTrashedParent is returned when restoring a subtask whose todo is still in the trash.
+var ErrTrashedParent = errors.New("the parent todo is in the trash, restore it first")
+
+// checkTodoParent returns an error unless the parent belongs to the user and the todo
+// (0 for a new todo) can be nested below it without a cycle or exceeding “types.MaxTodoDepth“.
+func checkTodoParent(ctx context.Context, q querier, todoID, parentID int64, userID int) error {
+	query := `WITH RECURSIVE ancestors AS (
+					SELECT id, parent_id FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NULL
+					UNION
+					SELECT t.id, t.parent_id FROM todo t JOIN ancestors a ON t.id = a.parent_id
+				)
+				SELECT id FROM ancestors`
+	rows, err := q.QueryContext(ctx, query, parentID, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ancestors := 0
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return err
+		}
+		if id == todoID {
+			return fmt.Errorf("%w: a todo can't be nested below itself or its subtasks", ErrInvalidParent)
+		}
+		ancestors++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if ancestors == 0 {
+		return fmt.Errorf("%w: unknown parent ID: %d", ErrInvalidParent, parentID)
+	}
+
+	height := 0
+	if todoID != 0 {
+		query := `WITH RECURSIVE descendants AS (
+						SELECT id, 0 AS depth FROM todo WHERE id = $1
+						UNION ALL
+						SELECT t.id, d.depth + 1 FROM todo t JOIN descendants d ON t.parent_id = d.id
+					)
+					SELECT MAX(depth) FROM descendants`
+		var max sql.NullInt64
+		if err := q.QueryRowContext(ctx, query, todoID).Scan(&max); err != nil {
+			return err
+		}
+		height = int(max.Int64)
+	}
+	if ancestors+1+height > types.MaxTodoDepth {
+		return fmt.Errorf("%w: todos can only be nested %d levels deep", ErrInvalidParent, types.MaxTodoDepth)
+	}
+
+	return nil
+}
+
+// autoCompleteTodo marks the todo and its ancestors as done if they have auto
+// complete set and every subtask and checklist item below them is done. The
+// recurring todos it completes spawn their next occurrence.
+func autoCompleteTodo(ctx context.Context, q querier, id int64) error {
+	for {
+		query := `UPDATE todo p SET done = true
+					WHERE p.id = $1 AND p.auto_complete AND NOT p.done
+					AND NOT EXISTS (SELECT 1 FROM todo c WHERE c.parent_id = p.id AND c.deleted_at IS NULL AND NOT c.done)
+					AND NOT EXISTS (SELECT 1 FROM todo_checklist_item i WHERE i.todo_id = p.id AND NOT i.done)
+					AND (EXISTS (SELECT 1 FROM todo c WHERE c.parent_id = p.id AND c.deleted_at IS NULL)
+						OR EXISTS (SELECT 1 FROM todo_checklist_item i WHERE i.todo_id = p.id))
+					RETURNING ` + todoColumns
+		var todo types.Todo
+		err := q.QueryRowContext(ctx, query, id).Scan(todoFields(&todo)...)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && todo.Recurrence != "" {
+			if err := loadTodoDetails(ctx, q, &todo); err != nil {
+				return err
+			}
+			if err := spawnNextOccurrence(ctx, q, &todo); err != nil {
+				return err
+			}
+		}
+
+		var parentID sql.NullInt64
+		err = q.QueryRowContext(ctx, `SELECT parent_id FROM todo WHERE id = $1`, id).Scan(&parentID)
+		if err == sql.ErrNoRows || !parentID.Valid {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		id = parentID.Int64
+	}
+}
+
+// loadTodoProgress sets the progress of every todo, the checklists need to be loaded.
+func loadTodoProgress(ctx context.Context, q querier, todos ...*types.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	byID := map[int64]*types.Todo{}
+	ids := []int64{}
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+		ids = append(ids, todo.ID)
+	}
+
+	subtasks := map[int64][2]int{}
+	query := `SELECT parent_id, COUNT(*) FILTER (WHERE done), COUNT(*) FROM todo
+				WHERE parent_id = ANY($1) AND deleted_at IS NULL GROUP BY parent_id`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			parentID    int64
+			done, total int
+		)
+		if err := rows.Scan(&parentID, &done, &total); err != nil {
+			return err
+		}
+		subtasks[parentID] = [2]int{done, total}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, todo := range byID {
+		done, total := subtasks[id][0], subtasks[id][1]
+		for _, item := range todo.Checklist {
+			if item.Done {
+				done++
+			}
+			total++
+		}
+		todo.Progress = types.NewTodoProgress(done, total)
+	}
+
+	return nil
+}
+
+// loadTodoDetails sets the tags, checklists and progress of every todo.
+func loadTodoDetails(ctx context.Context, q querier, todos ...*types.Todo) error {
+	if err := loadTodoTags(ctx, q, todos...); err != nil {
+		return err
+	}
+	if err := loadTodoChecklists(ctx, q, todos...); err != nil {
+		return err
+	}
+	return loadTodoProgress(ctx, q, todos...)
+}
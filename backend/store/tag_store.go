@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+// ErrTagExists is wrapped by the errors about tag names the user has already.
+var ErrTagExists = errors.New("tag exists already")
+
+type TagStorer interface {
+	// Every method is scoped to the user ID, tags created by other users are reported as unknown.
+	GetTags(context.Context, int) ([]*types.Tag, error)
+	GetTagByID(context.Context, int64, int) (*types.Tag, error)
+	InsertTag(context.Context, *types.Tag) (*types.Tag, error)
+	PatchTagByID(context.Context, int64, int, types.UpdateTagParams) (*types.Tag, error)
+	DeleteTagByID(context.Context, int64, int) error
+}
+
+type PostgreTagStore struct {
+	db *sql.DB
+}
+
+func NewPostgreTagStore(s *PostgreTodoStore) *PostgreTagStore {
+	return &PostgreTagStore{
+		db: s.db,
+	}
+}
+
+func (s *PostgreTagStore) GetTags(ctx context.Context, userID int) ([]*types.Tag, error) {
+	tags := []*types.Tag{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, color, created_by FROM tag WHERE created_by = $1 ORDER BY name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *PostgreTagStore) GetTagByID(ctx context.Context, id int64, userID int) (*types.Tag, error) {
+	var tag *types.Tag
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, name, color, created_by FROM tag WHERE id = $1 AND created_by = $2`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		tag, err = scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tag == nil {
+		return nil, fmt.Errorf("unknown tag ID: %d", id)
+	}
+
+	return tag, nil
+}
+
+// Inserts a “*types.Tag“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreTagStore) InsertTag(ctx context.Context, t *types.Tag) (*types.Tag, error) {
+	query := `INSERT INTO tag(name, color, created_by) VALUES ($1, $2, $3)
+				ON CONFLICT (created_by, name) DO NOTHING RETURNING id`
+	rows, err := s.db.QueryContext(ctx, query, t.Name, t.Color, t.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, fmt.Errorf("%w: %s", ErrTagExists, t.Name)
+	}
+	if err := rows.Scan(&t.ID); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (s *PostgreTagStore) PatchTagByID(ctx context.Context, id int64, userID int, t types.UpdateTagParams) (*types.Tag, error) {
+	query := `UPDATE tag SET name = COALESCE($1, name), color = COALESCE($2, color)
+				WHERE id = $3 AND created_by = $4 RETURNING id, name, color, created_by`
+	rows, err := s.db.QueryContext(ctx, query, t.Name, t.Color, id, userID)
+	if err != nil {
+		return nil, tagNameError(err, t.Name)
+	}
+	defer rows.Close()
+
+	var tag *types.Tag
+	for rows.Next() {
+		tag, err = scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// The unique violation of the name can be reported while reading the rows.
+	if err := rows.Err(); err != nil {
+		return nil, tagNameError(err, t.Name)
+	}
+
+	if tag == nil {
+		return nil, fmt.Errorf("unknown tag ID: %d", id)
+	}
+
+	return tag, nil
+}
+
+// Deletes the tag and removes it from every todo.
+func (s *PostgreTagStore) DeleteTagByID(ctx context.Context, id int64, userID int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tag WHERE id = $1 AND created_by = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown tag ID: %d", id)
+	}
+
+	return nil
+}
+
+// tagNameError wraps ErrTagExists if the error is the unique violation of the tag name.
+func tagNameError(err error, name *string) error {
+	if err, ok := err.(*pq.Error); ok && err.Code == "23505" && name != nil {
+		return fmt.Errorf("%w: %s", ErrTagExists, *name)
+	}
+	return err
+}
+
+func scanTag(rows *sql.Rows) (*types.Tag, error) {
+	var tag types.Tag
+	err := rows.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.CreatedBy)
+	return &tag, err
+}
+
+// setTodoTags replaces the tags of the todo, every tag needs to belong to the user.
+func setTodoTags(ctx context.Context, q querier, todoID int64, userID int, tagIDs []int64) error {
+	if _, err := q.ExecContext(ctx, `DELETE FROM todo_tag WHERE todo_id = $1`, todoID); err != nil {
+		return err
+	}
+	if len(tagIDs) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO todo_tag(todo_id, tag_id)
+				SELECT $1, id FROM tag WHERE id = ANY($2) AND created_by = $3`
+	res, err := q.ExecContext(ctx, query, todoID, pq.Array(tagIDs), userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if unique := uniqueIDs(tagIDs); affected != int64(len(unique)) {
+		return fmt.Errorf("unknown tag ID in %v", unique)
+	}
+
+	return nil
+}
+
+// loadTodoTags sets the tags of every todo.
+func loadTodoTags(ctx context.Context, q querier, todos ...*types.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	byID := map[int64]*types.Todo{}
+	ids := []int64{}
+	for _, todo := range todos {
+		todo.Tags = []*types.Tag{}
+		byID[todo.ID] = todo
+		ids = append(ids, todo.ID)
+	}
+
+	query := `SELECT tt.todo_id, t.id, t.name, t.color, t.created_by
+				FROM todo_tag tt JOIN tag t ON t.id = tt.tag_id
+				WHERE tt.todo_id = ANY($1) ORDER BY t.name`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			todoID int64
+			tag    types.Tag
+		)
+		if err := rows.Scan(&todoID, &tag.ID, &tag.Name, &tag.Color, &tag.CreatedBy); err != nil {
+			return err
+		}
+		byID[todoID].Tags = append(byID[todoID].Tags, &tag)
+	}
+
+	return rows.Err()
+}
+
+func uniqueIDs(ids []int64) []int64 {
+	seen := map[int64]bool{}
+	unique := []int64{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
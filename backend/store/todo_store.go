@@ -4,25 +4,50 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"html"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/thimc/go-svelte-todo/backend/types"
 )
 
 type TodoStorer interface {
-	GetTodos(context.Context) ([]*types.Todo, error)
-	GetTodoByID(context.Context, int64) (*types.Todo, error)
+	// Every method but InsertTodo is scoped to the user ID passed after the
+	// todo ID (or in the query), todos created by other users are reported as unknown.
+	GetTodos(context.Context, types.TodoQuery) (*types.TodoPage, error)
+	GetTodoByID(context.Context, int64, int) (*types.Todo, error)
 	InsertTodo(context.Context, *types.Todo) (*types.Todo, error)
-	UpdateTodoByID(context.Context, types.UpdateTodoParams, int64) error
-	DeleteTodoByID(context.Context, int64) error
-	PatchTodoByID(context.Context, int64, types.UpdateTodoParams) error
+	UpdateTodoByID(context.Context, types.UpdateTodoParams, int64, int) error
+	// Moves the todo with its subtasks to the trash, the methods above and
+	// below treat trashed todos as unknown.
+	DeleteTodoByID(context.Context, int64, int) error
+	// Completing a recurring todo creates its next occurrence in the same transaction.
+	PatchTodoByID(context.Context, int64, int, types.UpdateTodoParams) (*types.Todo, error)
+	// Returns at most limit todos matching the search query, best match first.
+	SearchTodos(ctx context.Context, userID int, query string, limit int) ([]*types.TodoSearchResult, error)
+
+	// Returns the trashed todos whose parent isn't trashed, most recently deleted first.
+	GetTrash(context.Context, int) ([]*types.Todo, error)
+	// Returns every trashed todo, subtasks trashed with their parent included, most recently deleted first.
+	GetTrashedTodos(context.Context, int) ([]*types.Todo, error)
+	// Restores a trashed todo with the subtasks that were trashed along with it.
+	RestoreTodoByID(context.Context, int64, int) (*types.Todo, error)
+	// Deletes a trashed todo with its subtasks for good.
+	DeleteTrashedTodoByID(context.Context, int64, int) error
+	// Deletes every trashed todo of the user for good and returns how many were deleted.
+	EmptyTrash(context.Context, int) (int, error)
+	// Deletes the todos of every user that were trashed before the time and returns how many were deleted.
+	PurgeTrash(context.Context, time.Time) (int, error)
 
 	Close() error
 }
 
+// The columns scanned by scanTodo, in order.
+const todoColumns = `id, title, content, created, updated, created_by, updated_by, done, due_at, priority, list_id, parent_id, auto_complete, recurrence, deleted_at`
+
 type PostgreTodoStore struct {
 	db *sql.DB
 }
@@ -35,59 +60,136 @@ func NewPostgreTodoStore(connectionStr string) (*PostgreTodoStore, error) {
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
-	store := &PostgreTodoStore{
-		db: db,
-	}
-	if err := store.init(); err != nil {
-		return nil, err
-	}
-
-	return store, nil
-}
 
-func (s *PostgreTodoStore) init() error {
-	query := `CREATE TABLE IF NOT EXISTS todo (
-		id SERIAL PRIMARY KEY,
-		title VARCHAR(100),
-		content VARCHAR(1000),
-		created TIMESTAMP,
-		updated TIMESTAMP,
-		created_by INTEGER,
-		updated_by INTEGER,
-		done BOOLEAN
-	);`
-	_, err := s.db.Exec(query)
-
-	return err
+	// The schema is managed by the Migrator.
+	return &PostgreTodoStore{
+		db: db,
+	}, nil
 }
 
 func (s *PostgreTodoStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *PostgreTodoStore) GetTodos(ctx context.Context) ([]*types.Todo, error) {
-	todos := []*types.Todo{}
+// The ORDER BY expression of every sort field, see “types.TodoQuery“.
+var todoSortExpressions = map[string]string{
+	types.TodoSortCreated: `created`,
+	types.TodoSortUpdated: `COALESCE(updated, created)`,
+	types.TodoSortTitle:   `title COLLATE "C"`,
+	types.TodoSortDone:    `done`,
+}
+
+func (s *PostgreTodoStore) GetTodos(ctx context.Context, q types.TodoQuery) (*types.TodoPage, error) {
+	var (
+		args  queryArgs
+		where = todoWhere(q, &args)
+	)
 
-	rows, err := s.db.QueryContext(ctx, `SELECT * FROM todo`)
+	page := &types.TodoPage{Todos: []*types.Todo{}}
+	countQuery := `SELECT COUNT(*) FROM todo WHERE ` + strings.Join(where, " AND ")
+	if err := s.db.QueryRowContext(ctx, countQuery, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	sortExpr := todoSortExpressions[q.Sort]
+	if sortExpr == "" {
+		return nil, fmt.Errorf("unknown sort field: %s", q.Sort)
+	}
+	direction, comparison := "ASC", ">"
+	if q.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if q.Cursor != nil {
+		value, err := todoCursorValue(q)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", sortExpr, comparison, args.add(value), args.add(q.Cursor.ID)))
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM todo WHERE %s ORDER BY %s %s, id %s LIMIT %s`,
+		todoColumns, strings.Join(where, " AND "), sortExpr, direction, direction, args.add(q.Limit+1))
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		todo, err := scanTodo(rows)
 		if err != nil {
 			return nil, err
 		}
-		todos = append(todos, todo)
+		page.Todos = append(page.Todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return todos, nil
+	// One more todo than requested is fetched to know whether there is a next page.
+	if len(page.Todos) > q.Limit {
+		page.Todos = page.Todos[:q.Limit]
+		page.Next = q.CursorFor(page.Todos[q.Limit-1])
+	}
+	if err := loadTodoDetails(ctx, s.db, page.Todos...); err != nil {
+		return nil, err
+	}
+
+	return page, nil
 }
 
-func (s *PostgreTodoStore) GetTodoByID(ctx context.Context, id int64) (*types.Todo, error) {
+// todoWhere returns the conditions of the filters in “types.TodoQuery“, the cursor excluded.
+func todoWhere(q types.TodoQuery, args *queryArgs) []string {
+	where := []string{"created_by = " + args.add(q.UserID), "deleted_at IS NULL"}
+	if q.Done != nil {
+		where = append(where, "done = "+args.add(*q.Done))
+	}
+	if q.CreatedAfter != nil {
+		where = append(where, "created > "+args.add(*q.CreatedAfter))
+	}
+	if q.CreatedBy != nil {
+		where = append(where, "created_by = "+args.add(*q.CreatedBy))
+	}
+	if q.DueFrom != nil {
+		where = append(where, "due_at >= "+args.add(*q.DueFrom))
+	}
+	if q.DueBefore != nil {
+		where = append(where, "due_at < "+args.add(*q.DueBefore))
+	}
+	if q.ListID != nil {
+		where = append(where, "list_id = "+args.add(*q.ListID))
+	}
+	if q.ParentID != nil {
+		where = append(where, "parent_id = "+args.add(*q.ParentID))
+	}
+	if len(q.Tags) > 0 {
+		tagged := `SELECT COUNT(DISTINCT t.name) FROM todo_tag tt JOIN tag t ON t.id = tt.tag_id
+					WHERE tt.todo_id = todo.id AND t.name = ANY(` + args.add(pq.Array(q.Tags)) + `)`
+		if q.TagsAll {
+			where = append(where, fmt.Sprintf("(%s) = %s", tagged, args.add(len(uniqueStrings(q.Tags)))))
+		} else {
+			where = append(where, fmt.Sprintf("(%s) > 0", tagged))
+		}
+	}
+	return where
+}
+
+// todoCursorValue converts the cursor value to the type of the sort column.
+func todoCursorValue(q types.TodoQuery) (any, error) {
+	switch q.Sort {
+	case types.TodoSortCreated, types.TodoSortUpdated:
+		return time.Parse(time.RFC3339Nano, q.Cursor.Value)
+	case types.TodoSortDone:
+		return strconv.ParseBool(q.Cursor.Value)
+	default:
+		return q.Cursor.Value, nil
+	}
+}
+
+func (s *PostgreTodoStore) GetTodoByID(ctx context.Context, id int64, userID int) (*types.Todo, error) {
 	var todo *types.Todo
 
-	rows, err := s.db.QueryContext(ctx, `SELECT * FROM todo WHERE id = $1 LIMIT 1`, id)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+todoColumns+` FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NULL LIMIT 1`, id, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -103,113 +205,467 @@ func (s *PostgreTodoStore) GetTodoByID(ctx context.Context, id int64) (*types.To
 		return nil, fmt.Errorf("unknown ID: %d", id)
 	}
 
+	if err := loadTodoDetails(ctx, s.db, todo); err != nil {
+		return nil, err
+	}
+
 	return todo, nil
 }
 
 // Inserts a “*types.Todo“ and mutates the “ID“ property to that of the ID from Postgre.
+// The placeholder tags of the todo are replaced by the stored tags and todos
+// without a list are put in the inbox of the user.
 func (s *PostgreTodoStore) InsertTodo(ctx context.Context, t *types.Todo) (*types.Todo, error) {
-	query := `INSERT INTO todo(title, content, created, created_by, done)
-				VALUES        ($1,    $2,      NOW(),   $3,         $4) RETURNING id`
-	rows, err := s.db.QueryContext(ctx, query, t.Title, t.Content, t.CreatedBy, t.Done)
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return insertTodo(ctx, tx, t)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	for rows.Next() {
-		err := rows.Scan(&t.ID)
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	return t, nil
 }
 
-func (s *PostgreTodoStore) UpdateTodoByID(ctx context.Context, t types.UpdateTodoParams, id int64) error {
-	query := `UPDATE todo SET title = $1, content = $2, created = $3, updated = $4, created_by = $5, updated_by = $6, done = $7
-				WHERE id = $8`
-	res, err := s.db.ExecContext(ctx, query, t.Title, t.Content, t.Created, t.Updated, t.CreatedBy, t.UpdatedBy, t.Done, id)
-
-	affected, err := res.RowsAffected()
+// insertTodo inserts the todo with its tags and sets its ID and details.
+func insertTodo(ctx context.Context, q querier, t *types.Todo) error {
+	listID, err := todoListID(ctx, q, t.ListID, t.CreatedBy)
 	if err != nil {
 		return err
 	}
-	if affected == 0 {
-		return fmt.Errorf("unknown todo ID: %d", id)
+	t.ListID = listID
+	if t.ParentID != nil {
+		if err := checkTodoParent(ctx, q, 0, *t.ParentID, t.CreatedBy); err != nil {
+			return err
+		}
 	}
 
-	return err
-}
-
-func (s *PostgreTodoStore) DeleteTodoByID(ctx context.Context, id int64) error {
-	todo, err := s.GetTodoByID(ctx, id)
+	query := `INSERT INTO todo(title, content, created, created_by, done, due_at, priority, list_id, parent_id, auto_complete, recurrence)
+				VALUES        ($1,    $2,      NOW(),   $3,         $4,   $5,     $6,       $7,      $8,        $9,            $10) RETURNING id`
+	err = q.QueryRowContext(ctx, query, t.Title, t.Content, t.CreatedBy, t.Done, utcTime(t.DueAt), t.Priority, t.ListID,
+		t.ParentID, t.AutoComplete, t.Recurrence).Scan(&t.ID)
 	if err != nil {
 		return err
 	}
-	if todo == nil {
-		return fmt.Errorf("unknown id: %d", id)
-	}
-	res, err := s.db.ExecContext(ctx, `DELETE FROM todo WHERE id = $1`, id)
-	affected, err := res.RowsAffected()
-	if err != nil {
+	if err := setTodoTags(ctx, q, t.ID, t.CreatedBy, t.TagIDs()); err != nil {
 		return err
 	}
-	if affected == 0 {
-		return fmt.Errorf("unknown todo ID: %d", id)
+	if err := loadTodoDetails(ctx, q, t); err != nil {
+		return err
 	}
-	return err
+	return insertTodoRevision(ctx, q, nil, t, t.CreatedBy)
 }
 
-func (s *PostgreTodoStore) PatchTodoByID(ctx context.Context, id int64, t types.UpdateTodoParams) error {
+func (s *PostgreTodoStore) UpdateTodoByID(ctx context.Context, t types.UpdateTodoParams, id int64, userID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := lockTodo(ctx, tx, id, userID)
+		if err != nil {
+			return err
+		}
+
+		// Replacing a todo without a list puts it in the inbox.
+		listID, err := todoListID(ctx, tx, t.ListID, userID)
+		if err != nil {
+			return err
+		}
+		if t.ParentID != nil {
+			if err := checkTodoParent(ctx, tx, id, *t.ParentID, userID); err != nil {
+				return err
+			}
+		}
+
+		query := `UPDATE todo SET title = $1, content = $2, created = $3, updated = $4, created_by = $5, updated_by = $6, done = $7,
+					due_at = $8, priority = COALESCE($9, 'normal'), list_id = $10, parent_id = $11, auto_complete = COALESCE($12, false),
+					recurrence = COALESCE($13, '')
+					WHERE id = $14 AND created_by = $15 AND deleted_at IS NULL`
+		_, err = tx.ExecContext(ctx, query, t.Title, t.Content, utcTime(t.Created), utcTime(t.Updated), t.CreatedBy, t.UpdatedBy, t.Done,
+			utcTime(t.DueAt), t.Priority, listID, t.ParentID, t.AutoComplete, t.Recurrence, id, userID)
+		if err != nil {
+			return err
+		}
+
+		// Replacing a todo without tags removes them.
+		var tagIDs []int64
+		if t.TagIDs != nil {
+			tagIDs = *t.TagIDs
+		}
+		if err := setTodoTags(ctx, tx, id, userID, tagIDs); err != nil {
+			return err
+		}
+
+		if err := autoCompleteTodo(ctx, tx, id); err != nil {
+			return err
+		}
+		if before.ParentID != nil {
+			if err := autoCompleteTodo(ctx, tx, *before.ParentID); err != nil {
+				return err
+			}
+		}
+
+		var after types.Todo
+		if err := tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todo WHERE id = $1`, id).Scan(todoFields(&after)...); err != nil {
+			return err
+		}
+		if err := loadTodoTags(ctx, tx, &after); err != nil {
+			return err
+		}
+		return insertTodoRevision(ctx, tx, before, &after, todoEditor(t, userID))
+	})
+}
+
+func (s *PostgreTodoStore) DeleteTodoByID(ctx context.Context, id int64, userID int) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var parentID sql.NullInt64
+		err := tx.QueryRowContext(ctx, `SELECT parent_id FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NULL FOR UPDATE`,
+			id, userID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown todo ID: %d", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		// The subtasks are trashed along with the todo, those trashed before keep their time.
+		query := `WITH RECURSIVE subtree AS (
+						SELECT id FROM todo WHERE id = $1
+						UNION ALL
+						SELECT t.id FROM todo t JOIN subtree s ON t.parent_id = s.id
+					)
+					UPDATE todo SET deleted_at = $2 WHERE id IN (SELECT id FROM subtree) AND deleted_at IS NULL`
+		if _, err := tx.ExecContext(ctx, query, id, time.Now().UTC()); err != nil {
+			return err
+		}
+		// The remaining subtasks of the parent might all be done.
+		if parentID.Valid {
+			return autoCompleteTodo(ctx, tx, parentID.Int64)
+		}
+		return nil
+	})
+}
+
+// Applies every non-nil field of “types.UpdateTodoParams“ and returns the updated todo.
+// The columns come from the “sql“ struct tags, the values are always passed as placeholders.
+func (s *PostgreTodoStore) PatchTodoByID(ctx context.Context, id int64, userID int, t types.UpdateTodoParams) (*types.Todo, error) {
 	var (
-		sb  strings.Builder
-		ref = reflect.Indirect(reflect.ValueOf(t))
+		set  []string
+		args queryArgs
+		ref  = reflect.ValueOf(t)
 	)
 	for i := 0; i < ref.NumField(); i++ {
-		field := ref.Type().Field(i)
-		tag := field.Tag.Get("sql")
+		tag := ref.Type().Field(i).Tag.Get("sql")
 		if tag == "" {
 			continue
 		}
 
 		val := ref.Field(i)
-		if val.IsNil() {
+		if val.Kind() != reflect.Pointer || val.IsNil() {
 			continue
 		}
-		fieldValue := val.Elem().Interface()
+		value := val.Elem().Interface()
+		if ts, ok := value.(time.Time); ok {
+			value = ts.UTC()
+		}
+		set = append(set, fmt.Sprintf("%s = %s", tag, args.add(value)))
+	}
+	if len(set) == 0 && t.TagIDs == nil {
+		return nil, fmt.Errorf("nothing to patch")
+	}
+
+	var todo types.Todo
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		before, err := lockTodo(ctx, tx, id, userID)
+		if err != nil {
+			return err
+		}
+		if t.ListID != nil {
+			if _, err := todoListID(ctx, tx, t.ListID, userID); err != nil {
+				return err
+			}
+		}
+		if t.ParentID != nil {
+			if err := checkTodoParent(ctx, tx, id, *t.ParentID, userID); err != nil {
+				return err
+			}
+		}
+
+		if len(set) > 0 {
+			query := fmt.Sprintf("UPDATE todo SET %s WHERE id = %s", strings.Join(set, ", "), args.add(id))
+			if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+				return err
+			}
+		}
+		if t.TagIDs != nil {
+			if err := setTodoTags(ctx, tx, id, userID, *t.TagIDs); err != nil {
+				return err
+			}
+		}
 
-		switch val.Elem().Kind() {
-		case reflect.Bool:
-			sb.WriteString(fmt.Sprintf("%s = %v", tag, fieldValue))
-		case reflect.Struct:
-			date := fieldValue.(time.Time)
-			sb.WriteString(fmt.Sprintf("%s = '%s'", tag, date.Format("2006-01-02 15:04:05.99-07")))
-		case reflect.String:
-			sb.WriteString(fmt.Sprintf("%s = '%v'", tag, fieldValue))
-		case reflect.Uint, reflect.Uint8, reflect.Uint32, reflect.Uint64,
-			reflect.Int, reflect.Int8, reflect.Int32, reflect.Int64,
-			reflect.Float32, reflect.Float64:
-			sb.WriteString(fmt.Sprintf("%s = %v", tag, fieldValue))
+		if err := autoCompleteTodo(ctx, tx, id); err != nil {
+			return err
 		}
-		sb.WriteString(", ")
+		if t.ParentID != nil && before.ParentID != nil {
+			if err := autoCompleteTodo(ctx, tx, *before.ParentID); err != nil {
+				return err
+			}
+		}
+		// Auto complete might have marked the todo itself as done.
+		if err := tx.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todo WHERE id = $1`, id).Scan(todoFields(&todo)...); err != nil {
+			return err
+		}
+		if err := loadTodoDetails(ctx, tx, &todo); err != nil {
+			return err
+		}
+		if !before.Done && todo.Done {
+			if err := spawnNextOccurrence(ctx, tx, &todo); err != nil {
+				return err
+			}
+		}
+		return insertTodoRevision(ctx, tx, before, &todo, todoEditor(t, userID))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &todo, nil
+}
+
+// spawnNextOccurrence creates the next todo of a recurring todo that was just
+// completed. The series continues with the new todo, so the recurrence of the
+// completed one ends.
+func spawnNextOccurrence(ctx context.Context, q querier, todo *types.Todo) error {
+	if todo.Recurrence == "" {
+		return nil
+	}
+	next, err := todo.NextOccurrence(time.Now())
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		if err := insertTodo(ctx, q, next); err != nil {
+			return err
+		}
+	}
+
+	if _, err := q.ExecContext(ctx, `UPDATE todo SET recurrence = '' WHERE id = $1`, todo.ID); err != nil {
+		return err
+	}
+	todo.Recurrence = ""
+	return nil
+}
+
+// ts_headline marks the matches of a snippet with these control characters, which
+// are removed from the todos first, so the snippet can be escaped before the
+// matches are wrapped in <b></b>.
+const (
+	snippetStartSel = "\x02"
+	snippetStopSel  = "\x03"
+)
+
+var snippetReplacer = strings.NewReplacer(snippetStartSel, "<b>", snippetStopSel, "</b>")
+
+// Ranks the todos with the tsvector of the title and content, see the todo_search migration.
+func (s *PostgreTodoStore) SearchTodos(ctx context.Context, userID int, search string, limit int) ([]*types.TodoSearchResult, error) {
+	query := `SELECT ` + todoColumns + `,
+			ts_rank(search, q) AS rank,
+			ts_headline('english', translate(COALESCE(title, '') || ' - ' || COALESCE(content, ''), $4, ''), q, $5) AS snippet
+		FROM todo, websearch_to_tsquery('english', $2) q
+		WHERE created_by = $1 AND deleted_at IS NULL AND search @@ q
+		ORDER BY rank DESC, id DESC
+		LIMIT $3`
+	options := fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=20, MinWords=5", snippetStartSel, snippetStopSel)
+	rows, err := s.db.QueryContext(ctx, query, userID, search, limit, snippetStartSel+snippetStopSel, options)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*types.TodoSearchResult{}
+	for rows.Next() {
+		var (
+			result types.TodoSearchResult
+			todo   types.Todo
+		)
+		err := rows.Scan(append(todoFields(&todo), &result.Rank, &result.Snippet)...)
+		if err != nil {
+			return nil, err
+		}
+		result.Snippet = snippetReplacer.Replace(html.EscapeString(result.Snippet))
+		result.Todo = &todo
+		results = append(results, &result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	todos := []*types.Todo{}
+	for _, result := range results {
+		todos = append(todos, result.Todo)
+	}
+	if err := loadTodoDetails(ctx, s.db, todos...); err != nil {
+		return nil, err
 	}
-	query := fmt.Sprintf("UPDATE todo SET %s WHERE id = %d", sb.String()[:len(sb.String())-2], id)
-	res, err := s.db.ExecContext(ctx, query)
 
+	return results, nil
+}
+
+func (s *PostgreTodoStore) GetTrash(ctx context.Context, userID int) ([]*types.Todo, error) {
+	return s.getTrashedTodos(ctx, userID, true)
+}
+
+func (s *PostgreTodoStore) GetTrashedTodos(ctx context.Context, userID int) ([]*types.Todo, error) {
+	return s.getTrashedTodos(ctx, userID, false)
+}
+
+// getTrashedTodos returns the trashed todos of the user, without the subtasks
+// of trashed todos if topLevel is set.
+func (s *PostgreTodoStore) getTrashedTodos(ctx context.Context, userID int, topLevel bool) ([]*types.Todo, error) {
+	query := `SELECT ` + todoColumns + ` FROM todo t
+				WHERE created_by = $1 AND deleted_at IS NOT NULL
+				AND (NOT $2 OR NOT EXISTS (SELECT 1 FROM todo p WHERE p.id = t.parent_id AND p.deleted_at IS NOT NULL))
+				ORDER BY deleted_at DESC, id DESC`
+	rows, err := s.db.QueryContext(ctx, query, userID, topLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*types.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadTodoDetails(ctx, s.db, todos...); err != nil {
+		return nil, err
+	}
+
+	return todos, nil
+}
+
+func (s *PostgreTodoStore) RestoreTodoByID(ctx context.Context, id int64, userID int) (*types.Todo, error) {
+	var todo *types.Todo
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var (
+			deletedAt time.Time
+			parentID  sql.NullInt64
+		)
+		err := tx.QueryRowContext(ctx, `SELECT deleted_at, parent_id FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NOT NULL FOR UPDATE`,
+			id, userID).Scan(&deletedAt, &parentID)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("unknown trashed todo ID: %d", id)
+		}
+		if err != nil {
+			return err
+		}
+		if parentID.Valid {
+			var parentTrashed bool
+			if err := tx.QueryRowContext(ctx, `SELECT deleted_at IS NOT NULL FROM todo WHERE id = $1`, parentID.Int64).Scan(&parentTrashed); err != nil {
+				return err
+			}
+			if parentTrashed {
+				return ErrTrashedParent
+			}
+		}
+
+		// Subtasks that were trashed before the todo stay in the trash.
+		query := `WITH RECURSIVE subtree AS (
+						SELECT id FROM todo WHERE id = $1
+						UNION ALL
+						SELECT t.id FROM todo t JOIN subtree s ON t.parent_id = s.id WHERE t.deleted_at = $2
+					)
+					UPDATE todo SET deleted_at = NULL WHERE id IN (SELECT id FROM subtree)`
+		if _, err := tx.ExecContext(ctx, query, id, deletedAt); err != nil {
+			return err
+		}
+		if parentID.Valid {
+			if err := autoCompleteTodo(ctx, tx, parentID.Int64); err != nil {
+				return err
+			}
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+todoColumns+` FROM todo WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			todo, err = scanTodo(rows)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		return loadTodoDetails(ctx, tx, todo)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+// The subtasks are trashed as well, so they are deleted along with the todo.
+func (s *PostgreTodoStore) DeleteTrashedTodoByID(ctx context.Context, id int64, userID int) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM todo WHERE id = $1 AND created_by = $2 AND deleted_at IS NOT NULL`, id, userID)
+	if err != nil {
+		return err
+	}
 	affected, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
 	if affected == 0 {
-		return fmt.Errorf("unknown ID: %d", id)
+		return fmt.Errorf("unknown trashed todo ID: %d", id)
+	}
+
+	return nil
+}
+
+func (s *PostgreTodoStore) EmptyTrash(ctx context.Context, userID int) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM todo WHERE created_by = $1 AND deleted_at IS NOT NULL`, userID)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := res.RowsAffected()
+
+	return int(affected), err
+}
+
+func (s *PostgreTodoStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM todo WHERE deleted_at < $1`, before.UTC())
+	if err != nil {
+		return 0, err
 	}
+	affected, err := res.RowsAffected()
 
-	return err
+	return int(affected), err
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
 }
 
 func scanTodo(rows *sql.Rows) (*types.Todo, error) {
 	var todo types.Todo
-	err := rows.Scan(
+	err := rows.Scan(todoFields(&todo)...)
+	return &todo, err
+}
+
+// todoFields returns the scan destinations of todoColumns.
+func todoFields(todo *types.Todo) []any {
+	return []any{
 		&todo.ID,
 		&todo.Title,
 		&todo.Content,
@@ -217,6 +673,13 @@ func scanTodo(rows *sql.Rows) (*types.Todo, error) {
 		&todo.Updated,
 		&todo.CreatedBy,
 		&todo.UpdatedBy,
-		&todo.Done)
-	return &todo, err
+		&todo.Done,
+		&todo.DueAt,
+		&todo.Priority,
+		&todo.ListID,
+		&todo.ParentID,
+		&todo.AutoComplete,
+		&todo.Recurrence,
+		&todo.DeletedAt,
+	}
 }
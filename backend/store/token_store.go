@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/thimc/go-svelte-todo/backend/types"
+)
+
+type TokenStorer interface {
+	CreateRefreshToken(context.Context, *types.RefreshToken) (*types.RefreshToken, error)
+	GetRefreshTokenByHash(context.Context, string) (*types.RefreshToken, error)
+	// Marks the token as exchanged, returns false if it was used or revoked already.
+	UseRefreshToken(context.Context, int64) (bool, error)
+
+	CreatePasswordResetToken(context.Context, *types.PasswordResetToken) (*types.PasswordResetToken, error)
+	GetPasswordResetTokenByHash(context.Context, string) (*types.PasswordResetToken, error)
+	// Marks the token and every other unused token of its user as used, returns
+	// false if the token was used already or has expired.
+	UsePasswordResetToken(context.Context, int64) (bool, error)
+}
+
+// The columns scanned by scanRefreshToken, in order.
+const refreshTokenColumns = `id, user_id, session_id, token_hash, created, expires_at, used_at, revoked_at`
+
+// The columns scanned by scanPasswordResetToken, in order.
+const passwordResetTokenColumns = `id, user_id, token_hash, created, expires_at, used_at`
+
+type PostgreTokenStore struct {
+	db *sql.DB
+}
+
+func NewPostgreTokenStore(s *PostgreTodoStore) *PostgreTokenStore {
+	return &PostgreTokenStore{
+		db: s.db,
+	}
+}
+
+// Inserts a “*types.RefreshToken“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreTokenStore) CreateRefreshToken(ctx context.Context, t *types.RefreshToken) (*types.RefreshToken, error) {
+	query := `INSERT INTO refresh_token(user_id, session_id, token_hash, created, expires_at)
+				VALUES                 ($1,      $2,         $3,         $4,      $5) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, t.UserID, t.SessionID, t.TokenHash, t.Created.UTC(), t.ExpiresAt.UTC()).Scan(&t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (s *PostgreTokenStore) GetRefreshTokenByHash(ctx context.Context, hash string) (*types.RefreshToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+refreshTokenColumns+` FROM refresh_token WHERE token_hash = $1`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var token *types.RefreshToken
+	for rows.Next() {
+		token, err = scanRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if token == nil {
+		return nil, fmt.Errorf("unknown refresh token")
+	}
+
+	return token, nil
+}
+
+func (s *PostgreTokenStore) UseRefreshToken(ctx context.Context, id int64) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE refresh_token SET used_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL`,
+		time.Now().UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+// Inserts a “*types.PasswordResetToken“ and mutates the “ID“ property to that of the ID from Postgre.
+func (s *PostgreTokenStore) CreatePasswordResetToken(ctx context.Context, t *types.PasswordResetToken) (*types.PasswordResetToken, error) {
+	query := `INSERT INTO password_reset_token(user_id, token_hash, created, expires_at)
+				VALUES                        ($1,      $2,         $3,      $4) RETURNING id`
+	err := s.db.QueryRowContext(ctx, query, t.UserID, t.TokenHash, t.Created.UTC(), t.ExpiresAt.UTC()).Scan(&t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (s *PostgreTokenStore) GetPasswordResetTokenByHash(ctx context.Context, hash string) (*types.PasswordResetToken, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+passwordResetTokenColumns+` FROM password_reset_token WHERE token_hash = $1`, hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var token *types.PasswordResetToken
+	for rows.Next() {
+		token, err = scanPasswordResetToken(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if token == nil {
+		return nil, fmt.Errorf("unknown password reset token")
+	}
+
+	return token, nil
+}
+
+func (s *PostgreTokenStore) UsePasswordResetToken(ctx context.Context, id int64) (bool, error) {
+	now := time.Now().UTC()
+	used := false
+	err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+		var userID int
+		err := tx.QueryRowContext(ctx, `UPDATE password_reset_token SET used_at = $1
+				WHERE id = $2 AND used_at IS NULL AND expires_at > $1 RETURNING user_id`, now, id).Scan(&userID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		used = true
+
+		_, err = tx.ExecContext(ctx, `UPDATE password_reset_token SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`, now, userID)
+		return err
+	})
+
+	return used, err
+}
+
+func scanRefreshToken(rows *sql.Rows) (*types.RefreshToken, error) {
+	var token types.RefreshToken
+	err := rows.Scan(&token.ID, &token.UserID, &token.SessionID, &token.TokenHash, &token.Created, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt)
+	return &token, err
+}
+
+func scanPasswordResetToken(rows *sql.Rows) (*types.PasswordResetToken, error) {
+	var token types.PasswordResetToken
+	err := rows.Scan(&token.ID, &token.UserID, &token.TokenHash, &token.Created, &token.ExpiresAt, &token.UsedAt)
+	return &token, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/thimc/go-svelte-todo/backend/types"
 )
@@ -15,39 +16,40 @@ type UserStorer interface {
 	DeleteUserByID(context.Context, int64) error
 	CreateUser(context.Context, *types.User) (*types.User, error)
 	UpdateUserPasswordByID(context.Context, string, int64) error
-
-	init() error
+	SetUserVerifiedByID(context.Context, int64) error
+	// Records that a verification mail is sent now, returns false if one was
+	// sent within the interval already.
+	MarkVerificationSent(context.Context, int64, time.Duration) (bool, error)
+	// Records that a password reset mail is sent now, returns false if one was
+	// sent within the interval already.
+	MarkPasswordResetSent(context.Context, int64, time.Duration) (bool, error)
+	SetUserRoleByID(context.Context, int64, string) error
+	SetUserDisabledByID(context.Context, int64, bool) error
+	// Marks the user to be deleted at the given time, until then the deletion
+	// can be cancelled.
+	ScheduleUserDeletion(context.Context, int64, time.Time) error
+	CancelUserDeletion(context.Context, int64) error
+	// Deletes the users whose deletion is due at the given time and returns how many were deleted.
+	PurgeDeletedUsers(context.Context, time.Time) (int, error)
 }
 
+// The columns scanned by scanUser, in order.
+const userColumns = `id, email, encrypted_password, verified, role, disabled, delete_at`
+
 type PostgreUserStore struct {
 	db *sql.DB
 }
 
-func NewPostgreUserStore(s *PostgreTodoStore) (*PostgreUserStore, error) {
-	store := &PostgreUserStore{
+func NewPostgreUserStore(s *PostgreTodoStore) *PostgreUserStore {
+	return &PostgreUserStore{
 		db: s.db,
 	}
-	err := store.init()
-
-	return store, err
-}
-
-func (s *PostgreUserStore) init() error {
-	query := `
-		CREATE TABLE IF NOT EXISTS todo_user (
-		id SERIAL PRIMARY KEY,
-		email VARCHAR(100) UNIQUE,
-		encrypted_password VARCHAR(100)
-	);`
-	_, err := s.db.Query(query)
-
-	return err
 }
 
 func (s *PostgreUserStore) GetUsers(ctx context.Context) ([]*types.User, error) {
 	users := []*types.User{}
 
-	rows, err := s.db.QueryContext(ctx, `SELECT * FROM todo_user`)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM todo_user`)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +67,7 @@ func (s *PostgreUserStore) GetUsers(ctx context.Context) ([]*types.User, error)
 func (s *PostgreUserStore) GetUserByID(ctx context.Context, id int64) (*types.User, error) {
 	var user *types.User
 
-	rows, err := s.db.QueryContext(ctx, `SELECT * FROM todo_user WHERE id = $1 LIMIT 1`, id)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM todo_user WHERE id = $1 LIMIT 1`, id)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +89,7 @@ func (s *PostgreUserStore) GetUserByID(ctx context.Context, id int64) (*types.Us
 func (s *PostgreUserStore) GetUserByEmail(ctx context.Context, email string) (*types.User, error) {
 	var user *types.User
 
-	rows, err := s.db.QueryContext(ctx, `SELECT * FROM todo_user WHERE email = $1 LIMIT 1`, email)
+	rows, err := s.db.QueryContext(ctx, `SELECT `+userColumns+` FROM todo_user WHERE email = $1 LIMIT 1`, email)
 	if err != nil {
 		return nil, err
 	}
@@ -115,9 +117,9 @@ func (s *PostgreUserStore) CreateUser(ctx context.Context, u *types.User) (*type
 		return nil, fmt.Errorf("user exists already")
 	}
 
-	query := `INSERT INTO todo_user(email, encrypted_password)
-				VALUES($1, $2) RETURNING id;`
-	rows, err := s.db.QueryContext(ctx, query, u.Email, u.EncryptedPassword)
+	query := `INSERT INTO todo_user(email, encrypted_password, verified, role)
+				VALUES($1, $2, $3, $4) RETURNING id;`
+	rows, err := s.db.QueryContext(ctx, query, u.Email, u.EncryptedPassword, u.Verified, u.Role)
 	if err != nil {
 		return nil, err
 	}
@@ -150,16 +152,170 @@ func (s *PostgreUserStore) UpdateUserPasswordByID(ctx context.Context, password
 	return nil
 }
 
+func (s *PostgreUserStore) SetUserVerifiedByID(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE todo_user SET verified = true WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
 
-func (s *PostgreUserStore) DeleteUserByID(ctx context.Context, id int64) error {
-	_, err := s.db.QueryContext(ctx, `DELETE FROM todo_user WHERE id = $1`, id)
+	return nil
+}
+
+func (s *PostgreUserStore) SetUserRoleByID(ctx context.Context, id int64, role string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE todo_user SET role = $1 WHERE id = $2`, role, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+
+	return nil
+}
+
+func (s *PostgreUserStore) SetUserDisabledByID(ctx context.Context, id int64, disabled bool) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE todo_user SET disabled = $1 WHERE id = $2`, disabled, id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+
+	return nil
+}
+
+func (s *PostgreUserStore) MarkVerificationSent(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	query := `UPDATE todo_user SET verification_sent_at = $1
+				WHERE id = $2 AND (verification_sent_at IS NULL OR verification_sent_at <= $3)`
+	res, err := s.db.ExecContext(ctx, query, now, id, now.Add(-interval))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *PostgreUserStore) MarkPasswordResetSent(ctx context.Context, id int64, interval time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	query := `UPDATE todo_user SET password_reset_sent_at = $1
+				WHERE id = $2 AND (password_reset_sent_at IS NULL OR password_reset_sent_at <= $3)`
+	res, err := s.db.ExecContext(ctx, query, now, id, now.Add(-interval))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *PostgreUserStore) ScheduleUserDeletion(ctx context.Context, id int64, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE todo_user SET delete_at = $1 WHERE id = $2`, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("unknown ID: %d", id)
+	}
+
+	return nil
+}
+
+func (s *PostgreUserStore) CancelUserDeletion(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE todo_user SET delete_at = NULL WHERE id = $1`, id)
 
 	return err
 }
 
+func (s *PostgreUserStore) DeleteUserByID(ctx context.Context, id int64) error {
+	return withTx(ctx, s.db, func(tx *sql.Tx) error {
+		return deleteUser(ctx, tx, id)
+	})
+}
+
+func (s *PostgreUserStore) PurgeDeletedUsers(ctx context.Context, now time.Time) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM todo_user WHERE delete_at <= $1`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Every user is deleted in a transaction of its own, so one failure doesn't
+	// keep the others around.
+	purged := 0
+	for _, id := range ids {
+		err := withTx(ctx, s.db, func(tx *sql.Tx) error {
+			return deleteUser(ctx, tx, id)
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// deleteUser deletes the user with everything the user owns. The todos, tags
+// and lists have no foreign key to the user so they are deleted first, the
+// rows of the other tables cascade.
+func deleteUser(ctx context.Context, tx *sql.Tx, id int64) error {
+	for _, query := range []string{
+		`DELETE FROM todo WHERE created_by = $1`,
+		`DELETE FROM tag WHERE created_by = $1`,
+		`DELETE FROM todo_list WHERE created_by = $1`,
+		`DELETE FROM todo_user WHERE id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func scanUser(rows *sql.Rows) (*types.User, error) {
 	user := new(types.User)
-	_ = rows.Scan(&user.ID, &user.Email, &user.EncryptedPassword)
+	_ = rows.Scan(&user.ID, &user.Email, &user.EncryptedPassword, &user.Verified, &user.Role, &user.Disabled, &user.DeleteAt)
 
 	return user, nil
 }
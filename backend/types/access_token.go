@@ -0,0 +1,144 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The scopes of a personal access token, write includes read.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// AccessTokenPrefix starts every personal access token so that the middleware
+// can tell them apart from JWT tokens.
+const AccessTokenPrefix = "pat_"
+
+// AccessToken is a personal access token, only the hash of the token is kept.
+type AccessToken struct {
+	// ID
+	ID int64 `json:"id" example:"1"`
+	// User ID
+	UserID int `json:"-"`
+	// The name of the token
+	Name string `json:"name" example:"CI"`
+	// The scopes of the token, read or write
+	Scopes []string `json:"scopes" example:"read,write"`
+	// The first characters of the token to recognize it by
+	Prefix string `json:"prefix" example:"pat_x3Jf"`
+	// The SHA-256 hash of the token
+	TokenHash string `json:"-"`
+	// PostgreSQL uses a ISO 8601-format
+	Created time.Time `json:"created" example:"2006-01-02 15:04:05.000-07"`
+	// The token never expires if this is null
+	ExpiresAt *time.Time `json:"expiresAt" example:"2006-01-02 15:04:05.000-07"`
+	// The last time the token was used
+	LastUsed *time.Time `json:"lastUsed" example:"2006-01-02 15:04:05.000-07"`
+} // @name AccessToken
+
+type AccessTokenParams struct {
+	// The name of the token
+	Name string `json:"name" example:"CI" validate:"required"`
+	// The scopes of the token, read or write
+	Scopes []string `json:"scopes" example:"read,write" validate:"required"`
+	// The token never expires if this is null
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" example:"2006-01-02T15:04:05Z"`
+} // @name AccessTokenParams
+
+type AccessTokenGetAllResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// Array of the tokens
+	Result []*AccessToken `json:"result"`
+} // @name AccessTokenGetAllResponse
+
+type AccessTokenCreatedResponse struct {
+	*AccessToken
+	// The token, it is only shown once
+	Token string `json:"token" example:"pat_x3JfUqVb2dC8kLr0sTn4yWm6aPe9hGi1oZx7Bv5cQ"`
+} // @name AccessTokenCreatedResponse
+
+// NewAccessToken returns a personal access token and the token to hand out.
+func NewAccessToken(params AccessTokenParams, userID int) (*AccessToken, string, error) {
+	random, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+	token := AccessTokenPrefix + random
+
+	var expiresAt *time.Time
+	if params.ExpiresAt != nil {
+		t := params.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+	return &AccessToken{
+		UserID:    userID,
+		Name:      params.Name,
+		Scopes:    uniqueScopes(params.Scopes),
+		Prefix:    token[:len(AccessTokenPrefix)+4],
+		TokenHash: HashToken(token),
+		Created:   time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}, token, nil
+}
+
+func NewAccessTokenGetAllResponse(tokens []*AccessToken) *AccessTokenGetAllResponse {
+	return &AccessTokenGetAllResponse{
+		Count:  len(tokens),
+		Result: tokens,
+	}
+}
+
+func (p *AccessTokenParams) Validate() error {
+	if len(p.Name) < 1 || len(p.Name) > 100 {
+		return fmt.Errorf("name needs to be between 1 and 100 characters")
+	}
+	if len(p.Scopes) == 0 {
+		return fmt.Errorf("the token needs at least one scope")
+	}
+	for _, scope := range p.Scopes {
+		if scope != ScopeRead && scope != ScopeWrite {
+			return fmt.Errorf("scopes need to be %s or %s", ScopeRead, ScopeWrite)
+		}
+	}
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expiresAt needs to be in the future")
+	}
+	return nil
+}
+
+// Expired reports whether the token can't be used anymore.
+func (t *AccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Allows reports whether the scopes of the token allow a request with the method,
+// reads need the read or write scope and everything else the write scope.
+func (t *AccessToken) Allows(method string) bool {
+	for _, scope := range t.Scopes {
+		if scope == ScopeWrite || (scope == ScopeRead && IsReadMethod(method)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReadMethod reports whether requests with the method only read.
+func IsReadMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func uniqueScopes(scopes []string) []string {
+	unique := []string{}
+	for _, scope := range []string{ScopeRead, ScopeWrite} {
+		for _, s := range scopes {
+			if s == scope {
+				unique = append(unique, scope)
+				break
+			}
+		}
+	}
+	return unique
+}
@@ -0,0 +1,114 @@
+package types
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The events recorded in the auth event log.
+const (
+	AuthEventLoginSucceeded = "login_succeeded"
+	AuthEventLoginFailed    = "login_failed"
+	// A login that was rejected without checking the password
+	AuthEventLoginLocked = "login_locked"
+	// The password was right, the login continues with a two-factor code
+	AuthEventMFAChallenged = "mfa_challenged"
+	AuthEventMFAFailed     = "mfa_failed"
+)
+
+// Failed logins are tracked per email address and per IP address. Once either
+// reaches its limit the next login has to wait, twice as long after every
+// further failure. A successful login clears the failures of the account.
+const (
+	LoginFailureWindow  = time.Hour
+	AccountFailureLimit = 5
+	IPFailureLimit      = 20
+	minLoginBackoff     = 30 * time.Second
+	maxLoginBackoff     = 15 * time.Minute
+)
+
+// The number of events returned by the auth event log.
+const AuthEventLimit = 50
+
+type AuthEvent struct {
+	// ID
+	ID int64 `json:"id" example:"1"`
+	// The user, unset for logins with an unknown email address
+	UserID *int `json:"-"`
+	// The email address that was used to log in
+	Email string `json:"email" example:"user@domain.com"`
+	// One of login_succeeded, login_failed, login_locked, mfa_challenged and mfa_failed
+	Event string `json:"event" example:"login_failed"`
+	// The IP address of the client
+	IP string `json:"ip" example:"192.0.2.1"`
+	// The user agent of the client
+	UserAgent string `json:"userAgent" example:"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0"`
+	// PostgreSQL uses a ISO 8601-format
+	Created time.Time `json:"created" example:"2006-01-02 15:04:05.000-07"`
+} // @name AuthEvent
+
+type AuthEventGetAllResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// Array of the most recent events, newest first
+	Result []*AuthEvent `json:"result"`
+} // @name AuthEventGetAllResponse
+
+// LoginFailures are the recent failed logins of an account or an IP address.
+type LoginFailures struct {
+	Count int
+	Last  time.Time
+}
+
+func NewAuthEvent(event, email string, userID *int, ip, userAgent string) *AuthEvent {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	return &AuthEvent{
+		UserID:    userID,
+		Email:     email,
+		Event:     event,
+		IP:        ip,
+		UserAgent: userAgent,
+		Created:   time.Now().UTC(),
+	}
+}
+
+func NewAuthEventGetAllResponse(events []*AuthEvent) *AuthEventGetAllResponse {
+	return &AuthEventGetAllResponse{
+		Count:  len(events),
+		Result: events,
+	}
+}
+
+// LockedUntil returns when the next login is allowed, the zero time if it is
+// allowed right away.
+func (f *LoginFailures) LockedUntil(limit int) time.Time {
+	if f.Count < limit {
+		return time.Time{}
+	}
+	backoff := minLoginBackoff
+	for i := limit; i < f.Count && backoff < maxLoginBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxLoginBackoff {
+		backoff = maxLoginBackoff
+	}
+	return f.Last.Add(backoff)
+}
+
+var (
+	dummyPasswordOnce sync.Once
+	dummyPassword     []byte
+)
+
+// CheckDummyPassword takes about as long as ValidPassword, so that a login with
+// an unknown email address can't be told apart by its response time.
+func CheckDummyPassword(password string) {
+	dummyPasswordOnce.Do(func() {
+		dummyPassword, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyPassword, []byte(password))
+}
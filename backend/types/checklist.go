@@ -0,0 +1,61 @@
+package types
+
+import "fmt"
+
+type ChecklistItem struct {
+	// ID
+	ID int64 `json:"id" example:"0"`
+	// The todo the item belongs to
+	TodoID int64 `json:"todoId" example:"0"`
+	// The title of the item
+	Title string `json:"title" example:"Buy milk"`
+	// This boolean determines if the item has been checked
+	Done bool `json:"done" example:"false"`
+	// Items are sorted by their position in ascending order
+	Position int `json:"position" example:"0"`
+} // @name ChecklistItem
+
+type ChecklistItemParams struct {
+	// The title of the item
+	Title string `json:"title" example:"Buy milk" validate:"required"`
+	// This boolean determines if the item has been checked
+	Done bool `json:"done" example:"false"`
+	// Items are sorted by their position in ascending order
+	Position int `json:"position" example:"0"`
+} // @name ChecklistItemParams
+
+type UpdateChecklistItemParams struct {
+	// The title of the item
+	Title *string `json:"title,omitempty" example:"Buy milk"`
+	// This boolean determines if the item has been checked
+	Done *bool `json:"done,omitempty" example:"true"`
+	// Items are sorted by their position in ascending order
+	Position *int `json:"position,omitempty" example:"0"`
+} // @name UpdateChecklistItemParams
+
+func NewChecklistItemFromParams(params ChecklistItemParams, todoID int64) *ChecklistItem {
+	return &ChecklistItem{
+		TodoID:   todoID,
+		Title:    params.Title,
+		Done:     params.Done,
+		Position: params.Position,
+	}
+}
+
+func (i *ChecklistItem) Validate() error {
+	return validateChecklistTitle(&i.Title)
+}
+
+func (p UpdateChecklistItemParams) Validate() error {
+	if p.Title == nil && p.Done == nil && p.Position == nil {
+		return fmt.Errorf("the patch needs to contain at least one field")
+	}
+	return validateChecklistTitle(p.Title)
+}
+
+func validateChecklistTitle(title *string) error {
+	if title != nil && (len(*title) < 1 || len(*title) > 200) {
+		return fmt.Errorf("title needs to be between 1 and 200 characters")
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package types
+
+import "time"
+
+// The states of an export.
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// Accounts with more todos than this are exported in the background.
+const ExportSyncLimit = 500
+
+// A pending export that isn't done after this long has been interrupted,
+// by a restart of the API for instance.
+const ExportTimeout = 10 * time.Minute
+
+// Exports can be downloaded for this long before they are purged.
+const ExportTTL = 24 * time.Hour
+
+// The number of auth events in an export, the newest first.
+const ExportAuthEventLimit = 10000
+
+// Export is a ZIP file with the personal data of a user.
+type Export struct {
+	// ID
+	ID int64 `json:"id" example:"1"`
+	// User ID
+	UserID int `json:"-"`
+	// One of pending, ready and failed
+	Status string `json:"status" example:"ready"`
+	// Why the export failed
+	Error string `json:"error,omitempty"`
+	// PostgreSQL uses a ISO 8601-format
+	Created time.Time `json:"created" example:"2006-01-02 15:04:05.000-07"`
+	// When the export was ready or failed
+	Finished *time.Time `json:"finished" example:"2006-01-02 15:04:05.000-07"`
+	// The size of the ZIP file in bytes
+	Size int `json:"size" example:"2048"`
+	// The ZIP file, only loaded for the download
+	Data []byte `json:"-"`
+} // @name Export
+
+func NewExport(userID int) *Export {
+	return &Export{
+		UserID:  userID,
+		Status:  ExportPending,
+		Created: time.Now().UTC(),
+	}
+}
+
+// Interrupted reports whether the export is still pending after ExportTimeout.
+func (e *Export) Interrupted(now time.Time) bool {
+	return e.Status == ExportPending && now.Sub(e.Created) > ExportTimeout
+}
+
+// ExportData is everything the API keeps about a user, data.json in the ZIP file.
+type ExportData struct {
+	// When the export was made
+	ExportedAt time.Time `json:"exportedAt"`
+	// The profile of the user
+	User *User `json:"user"`
+	// Whether two-factor authentication is enabled
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
+	// The lists, archived ones included
+	Lists []*List `json:"lists"`
+	// The tags
+	Tags []*Tag `json:"tags"`
+	// Every todo, subtasks included, oldest first
+	Todos []*Todo `json:"todos"`
+	// Every todo in the trash, subtasks trashed with their parent included
+	Trash []*Todo `json:"trash"`
+	// The sessions that haven't been signed out
+	Sessions []*Session `json:"sessions"`
+	// The personal access tokens, without the tokens themselves
+	AccessTokens []*AccessToken `json:"accessTokens"`
+	// The accounts at single sign-on providers that log in as the user
+	Identities []*ExportIdentity `json:"identities"`
+	// The auth event log, newest first
+	AuthEvents []*AuthEvent `json:"authEvents"`
+}
+
+type ExportIdentity struct {
+	// The name of the provider
+	Provider string `json:"provider" example:"google"`
+	// The email address the provider reported when the identity was linked
+	Email string `json:"email" example:"user@domain.com"`
+	// PostgreSQL uses a ISO 8601-format
+	Created time.Time `json:"created" example:"2006-01-02 15:04:05.000-07"`
+}
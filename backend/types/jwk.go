@@ -0,0 +1,83 @@
+package types
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// JWK is the public key that JWT tokens are verified with, see RFC 7517.
+type JWK struct {
+	// The key type, RSA or OKP
+	Kty string `json:"kty" example:"OKP"`
+	// The key ID that the tokens name in their header
+	Kid string `json:"kid" example:"Yp4t0rXnnqVmQhzQFtgN3c_hEPn0kAxqhb0Mt6TfZSE"`
+	// The keys are only used for signatures
+	Use string `json:"use" example:"sig"`
+	// The algorithm of the key, RS256 or EdDSA
+	Alg string `json:"alg" example:"EdDSA"`
+	// The modulus of an RSA key
+	N string `json:"n,omitempty"`
+	// The exponent of an RSA key
+	E string `json:"e,omitempty"`
+	// The curve of an OKP key
+	Crv string `json:"crv,omitempty" example:"Ed25519"`
+	// The public key of an OKP key
+	X string `json:"x,omitempty" example:"11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"`
+} // @name JWK
+
+type JWKSResponse struct {
+	// The keys that the tokens of the API are signed with
+	Keys []*JWK `json:"keys"`
+} // @name JWKSResponse
+
+// NewJWK returns the JWK of an RSA or Ed25519 public key, its ID is the
+// thumbprint of the key.
+func NewJWK(key crypto.PublicKey, alg string) (*JWK, error) {
+	jwk := &JWK{Use: "sig", Alg: alg}
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, expected an RSA or Ed25519 key", key)
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, err
+	}
+	jwk.Kid = thumbprint
+	return jwk, nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key, the SHA-256 hash of its
+// required members in lexicographic order.
+func (k *JWK) Thumbprint() (string, error) {
+	members := map[string]string{"kty": k.Kty}
+	switch k.Kty {
+	case "RSA":
+		members["n"], members["e"] = k.N, k.E
+	case "OKP":
+		members["crv"], members["x"] = k.Crv, k.X
+	default:
+		return "", fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+	// Maps are encoded with sorted keys and the values need no escaping.
+	b, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
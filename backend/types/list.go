@@ -0,0 +1,100 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+const InboxListName = "Inbox"
+
+type List struct {
+	// ID
+	ID int64 `json:"id" example:"0"`
+	// The name of the list
+	Name string `json:"name" example:"Groceries"`
+	// The description of the list
+	Description string `json:"description" example:"Things to buy"`
+	// Archived lists are hidden from the list overview
+	Archived bool `json:"archived" example:"false"`
+	// Lists are sorted by their position in ascending order
+	Position int `json:"position" example:"0"`
+	// Every user has one inbox that new todos end up in, it can't be archived or deleted
+	Inbox bool `json:"inbox" example:"false"`
+	// PostgreSQL uses a ISO 8601-format
+	Created time.Time `json:"created" example:"2006-01-02 15:04:05.000-07"`
+	// User ID
+	CreatedBy int `json:"-"`
+} // @name List
+
+type ListParams struct {
+	// The name of the list
+	Name string `json:"name" example:"Groceries" validate:"required"`
+	// The description of the list
+	Description string `json:"description" example:"Things to buy"`
+	// Lists are sorted by their position in ascending order
+	Position int `json:"position" example:"0"`
+} // @name ListParams
+
+type UpdateListParams struct {
+	// The name of the list
+	Name *string `json:"name,omitempty" example:"Groceries"`
+	// The description of the list
+	Description *string `json:"description,omitempty" example:"Things to buy"`
+	// Archived lists are hidden from the list overview
+	Archived *bool `json:"archived,omitempty" example:"false"`
+	// Lists are sorted by their position in ascending order
+	Position *int `json:"position,omitempty" example:"0"`
+} // @name UpdateListParams
+
+type ListGetAllResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// Array of the lists
+	Result []*List `json:"result"`
+} // @name ListGetAllResponse
+
+func NewListFromParams(params ListParams, userID int) *List {
+	return &List{
+		Name:        params.Name,
+		Description: params.Description,
+		Position:    params.Position,
+		CreatedBy:   userID,
+	}
+}
+
+// NewInboxList returns the default list of a new user.
+func NewInboxList(userID int) *List {
+	return &List{
+		Name:      InboxListName,
+		Inbox:     true,
+		CreatedBy: userID,
+	}
+}
+
+func NewListGetAllResponse(lists []*List) *ListGetAllResponse {
+	return &ListGetAllResponse{
+		Count:  len(lists),
+		Result: lists,
+	}
+}
+
+func (l *List) Validate() error {
+	return validateList(&l.Name, &l.Description)
+}
+
+func (p UpdateListParams) Validate() error {
+	if p.Name == nil && p.Description == nil && p.Archived == nil && p.Position == nil {
+		return fmt.Errorf("the patch needs to contain at least one field")
+	}
+	return validateList(p.Name, p.Description)
+}
+
+func validateList(name, description *string) error {
+	if name != nil && (len(*name) < 1 || len(*name) > 100) {
+		return fmt.Errorf("name needs to be between 1 and 100 characters")
+	}
+	if description != nil && len(*description) > 1000 {
+		return fmt.Errorf("description can't be longer than 1000 characters")
+	}
+	return nil
+}
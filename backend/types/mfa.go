@@ -0,0 +1,108 @@
+package types
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The number of recovery codes a user gets when enabling two-factor authentication.
+const RecoveryCodeCount = 10
+
+// MFA challenges need to be answered within this time.
+const MFAChallengeTTL = 5 * time.Minute
+
+// MFA is the TOTP two-factor authentication of a user, it is pending until the
+// user confirms it with a code.
+type MFA struct {
+	UserID  int
+	Secret  string
+	Enabled bool
+	// The last time step a code was used for, codes can't be used twice
+	LastCounter int64
+	Created     time.Time
+}
+
+type MFAStatusResponse struct {
+	Enabled bool `json:"enabled" example:"true"`
+	// The number of recovery codes that haven't been used
+	RecoveryCodes int `json:"recoveryCodes" example:"10"`
+} // @name MFAStatusResponse
+
+type MFAEnrollResponse struct {
+	// The base32 encoded secret for authenticator apps that can't read the URI
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"`
+	// The otpauth URI to show as a QR code
+	URI string `json:"uri" example:"otpauth://totp/Todo:user@domain.com?secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP&issuer=Todo"`
+} // @name MFAEnrollResponse
+
+type MFACodeParams struct {
+	// A code from the authenticator app or a recovery code
+	Code string `json:"code" example:"123456" validate:"required"`
+} // @name MFACodeParams
+
+type MFARecoveryCodesResponse struct {
+	// One-time codes that replace a code from the authenticator app, they are only shown once
+	RecoveryCodes []string `json:"recoveryCodes" example:"k3x9p-2mqrt"`
+} // @name MFARecoveryCodesResponse
+
+// MFAChallengeResponse is returned by the login instead of a LoginResponse if
+// the user has two-factor authentication enabled.
+type MFAChallengeResponse struct {
+	MFARequired bool `json:"mfaRequired" example:"true"`
+	// Exchanged together with a code for a LoginResponse at /api/login/mfa
+	ChallengeToken string `json:"challengeToken"`
+	// Unix timestamp for when the challenge expires
+	ExpiresAt int64 `json:"expiresAt" example:"1688751625"`
+} // @name MFAChallengeResponse
+
+type MFALoginParams struct {
+	// The challenge token from the login
+	ChallengeToken string `json:"challengeToken" validate:"required"`
+	// A code from the authenticator app or a recovery code
+	Code string `json:"code" example:"123456" validate:"required"`
+} // @name MFALoginParams
+
+func (p *MFACodeParams) Validate() error {
+	if p.Code == "" {
+		return fmt.Errorf("the code can't be empty")
+	}
+	return nil
+}
+
+func (p *MFALoginParams) Validate() error {
+	if p.ChallengeToken == "" {
+		return fmt.Errorf("the challenge token can't be empty")
+	}
+	return (&MFACodeParams{Code: p.Code}).Validate()
+}
+
+// The alphabet of the recovery codes, without characters that are easily confused.
+const recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+
+// NewRecoveryCodes returns n random codes like "k3x9p-2mqrt".
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := []string{}
+	for i := 0; i < n; i++ {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		var code strings.Builder
+		for j, c := range b {
+			if j == 5 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(c)%len(recoveryCodeAlphabet)])
+		}
+		codes = append(codes, code.String())
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash that is stored instead of the code, the
+// code is normalized so that it can be typed without the dash and in upper case.
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", "")))
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// The login at the provider needs to be completed within this time.
+const OAuthFlowTTL = 10 * time.Minute
+
+// Identity links the account of a user at an OpenID Connect provider to the user.
+type Identity struct {
+	ID     int64
+	UserID int
+	// The name of the provider
+	Provider string
+	// The "sub" claim, which identifies the user at the provider
+	Subject string
+	// The email address the provider reported when the identity was linked
+	Email   string
+	Created time.Time
+}
+
+// OAuthFlow is what the callback needs to know about the authorization request.
+// It is kept by the client in the signed flow token, not by the API.
+type OAuthFlow struct {
+	Provider string
+	State    string
+	Nonce    string
+	// The PKCE code verifier, only its challenge is sent to the provider
+	Verifier string
+}
+
+type OAuthProvidersResponse struct {
+	// The names of the providers users can log in with
+	Providers []string `json:"providers" example:"company"`
+} // @name OAuthProvidersResponse
+
+type OAuthStartResponse struct {
+	// Where to send the user to log in at the provider
+	AuthorizationURL string `json:"authorizationUrl" example:"https://sso.domain.com/authorize?response_type=code&client_id=todo"`
+	// Passed to the callback together with the code, keep it for example in a cookie
+	FlowToken string `json:"flowToken"`
+	// Unix timestamp for when the flow expires
+	ExpiresAt int64 `json:"expiresAt" example:"1688751625"`
+} // @name OAuthStartResponse
+
+type OAuthCallbackParams struct {
+	// The code the provider redirected back with
+	Code string `json:"code" validate:"required"`
+	// The state the provider redirected back with
+	State string `json:"state" validate:"required"`
+	// The flow token of the start
+	FlowToken string `json:"flowToken" validate:"required"`
+} // @name OAuthCallbackParams
+
+func (p *OAuthCallbackParams) Validate() error {
+	if p.Code == "" {
+		return fmt.Errorf("the code can't be empty")
+	}
+	if p.State == "" {
+		return fmt.Errorf("the state can't be empty")
+	}
+	if p.FlowToken == "" {
+		return fmt.Errorf("the flow token can't be empty")
+	}
+	return nil
+}
+
+// NewOAuthFlow returns a flow with a random state, nonce and PKCE verifier.
+func NewOAuthFlow(provider string) (*OAuthFlow, error) {
+	flow := &OAuthFlow{Provider: provider}
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := randomString(32)
+		if err != nil {
+			return nil, err
+		}
+		*value = random
+	}
+	return flow, nil
+}
+
+func NewIdentity(userID int, provider, subject, email string) *Identity {
+	return &Identity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+		Created:  time.Now().UTC(),
+	}
+}
+
+// NewExternalUser returns a verified user for an email address that a provider
+// has verified. Its password is random, the user can set one with a password reset.
+func NewExternalUser(email string) (*User, error) {
+	password, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	user, err := NewUser(email, password)
+	if err != nil {
+		return nil, err
+	}
+	user.Verified = true
+	return user, nil
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password reset links are valid for this long.
+const PasswordResetTokenTTL = time.Hour
+
+// A user asking to reset the password again within this interval gets no new mail.
+const PasswordResetMailInterval = 5 * time.Minute
+
+// PasswordResetToken is the stored form of a password reset token, only the hash of the token is kept.
+type PasswordResetToken struct {
+	ID        int64
+	UserID    int
+	TokenHash string
+	Created   time.Time
+	ExpiresAt time.Time
+	// Set once the token has been used, every token can only be used once
+	UsedAt *time.Time
+}
+
+type ForgotPasswordParams struct {
+	// The email address of the account
+	Email string `json:"email" example:"user@domain.com" validate:"required"`
+} // @name ForgotPasswordParams
+
+type ResetPasswordParams struct {
+	// The token from the reset link
+	Token string `json:"token" validate:"required"`
+	// The new password in plain text
+	Password string `json:"password" example:"12345abcdefgh" validate:"required"`
+} // @name ResetPasswordParams
+
+// NewPasswordResetToken returns a reset token for the user and the token to send.
+func NewPasswordResetToken(userID int) (*PasswordResetToken, string, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	return &PasswordResetToken{
+		UserID:    userID,
+		TokenHash: HashToken(token),
+		Created:   now,
+		ExpiresAt: now.Add(PasswordResetTokenTTL),
+	}, token, nil
+}
+
+func (p *ForgotPasswordParams) Validate() error {
+	if p.Email == "" {
+		return fmt.Errorf("the email can't be empty")
+	}
+	return nil
+}
+
+func (p *ResetPasswordParams) Validate() error {
+	if p.Token == "" {
+		return fmt.Errorf("the token can't be empty")
+	}
+	return (&UserPutPasswordParams{Password: p.Password}).Validate()
+}
+
+// NewRandomPassword returns a random password in its encrypted format, nobody
+// knows the password so the user needs a reset link to log in with one again.
+func NewRandomPassword() (string, error) {
+	password, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	encrypted, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(encrypted), nil
+}
@@ -0,0 +1,373 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The frequencies of a recurrence rule.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+const (
+	DefaultOccurrenceCount = 5
+	MaxOccurrenceCount     = 50
+)
+
+// Rules that never produce another occurrence, like BYMONTHDAY=31 with
+// INTERVAL=2 starting in a short month, stop after this many periods.
+const maxRecurrencePeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// The BYDAY names indexed by time.Weekday.
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Recurrence is the supported subset of an RFC 5545 RRULE: FREQ (DAILY, WEEKLY
+// or MONTHLY), INTERVAL, BYDAY for weekly rules, BYMONTHDAY for monthly rules
+// and either COUNT or UNTIL. The due date of the todo is the start of the rule
+// and counts as its first occurrence. Occurrences are computed in UTC.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+// ParseRecurrence parses an RRULE with an optional "RRULE:" prefix, or one of
+// the shorthands daily, weekly and monthly.
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	switch rule {
+	case FreqDaily, FreqWeekly, FreqMonthly:
+		return &Recurrence{Freq: rule, Interval: 1}, nil
+	}
+
+	r := &Recurrence{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid recurrence rule part: %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("recurrence rule part %s is repeated", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			if value != FreqDaily && value != FreqWeekly && value != FreqMonthly {
+				return nil, fmt.Errorf("FREQ needs to be one of DAILY, WEEKLY or MONTHLY")
+			}
+			r.Freq = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 || interval > 1000 {
+				return nil, fmt.Errorf("INTERVAL needs to be between 1 and 1000")
+			}
+			r.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("COUNT needs to be a positive number")
+			}
+			r.Count = count
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("BYDAY needs to be a list of MO, TU, WE, TH, FR, SA or SU")
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				d, err := strconv.Atoi(day)
+				if err != nil || d == 0 || d < -31 || d > 31 {
+					return nil, fmt.Errorf("BYMONTHDAY needs to be a list of days between 1 and 31 or -31 and -1")
+				}
+				r.ByMonthDay = append(r.ByMonthDay, d)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported recurrence rule part: %s", name)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("the recurrence rule needs a FREQ")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL can't be combined")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqWeekly {
+		return nil, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != FreqMonthly {
+		return nil, fmt.Errorf("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+
+	return r, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if until, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// A date includes the whole day.
+				until = until.Add(24*time.Hour - time.Nanosecond)
+			}
+			return until, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("UNTIL needs to be a date like 20060102 or a UTC time like 20060102T150405Z")
+}
+
+// String returns the rule as an RRULE without the "RRULE:" prefix.
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := []string{}
+		for _, weekday := range r.ByDay {
+			days = append(days, weekdayNames[weekday])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := []string{}
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Occurrences returns at most n occurrences of the rule starting at start that
+// are after the given time.
+func (r *Recurrence) Occurrences(start, after time.Time, n int) []time.Time {
+	occurrences := []time.Time{}
+	r.each(start, func(_ int, t time.Time) bool {
+		if t.After(after) {
+			occurrences = append(occurrences, t)
+		}
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+// Next returns the first occurrence after the given time and the rule that
+// continues from it, false if the rule has ended.
+func (r *Recurrence) Next(start, after time.Time) (time.Time, *Recurrence, bool) {
+	var (
+		next  time.Time
+		index int
+		found bool
+	)
+	r.each(start, func(i int, t time.Time) bool {
+		if t.After(after) {
+			next, index, found = t, i, true
+		}
+		return !found
+	})
+	if !found {
+		return time.Time{}, nil, false
+	}
+
+	rest := *r
+	if r.Count > 0 {
+		// The skipped occurrences count towards COUNT.
+		rest.Count = r.Count - index
+	}
+	return next, &rest, true
+}
+
+// each calls fn with the index and time of every occurrence in order until fn returns false.
+func (r *Recurrence) each(start time.Time, fn func(int, time.Time) bool) {
+	start = start.UTC()
+	i := 0
+	if !fn(i, start) {
+		return
+	}
+	for period := 0; period < maxRecurrencePeriods; period++ {
+		for _, t := range r.period(start, period) {
+			if !t.After(start) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return
+			}
+			i++
+			if r.Count > 0 && i >= r.Count {
+				return
+			}
+			if !fn(i, t) {
+				return
+			}
+		}
+	}
+}
+
+// period returns the candidate occurrences of the nth period after start, sorted.
+func (r *Recurrence) period(start time.Time, n int) []time.Time {
+	switch r.Freq {
+	case FreqDaily:
+		return []time.Time{start.AddDate(0, 0, n*r.Interval)}
+	case FreqWeekly:
+		week := start.AddDate(0, 0, 7*n*r.Interval)
+		if len(r.ByDay) == 0 {
+			return []time.Time{week}
+		}
+		monday := week.AddDate(0, 0, -((int(week.Weekday()) + 6) % 7))
+		days := []time.Time{}
+		for _, weekday := range r.ByDay {
+			days = append(days, monday.AddDate(0, 0, (int(weekday)+6)%7))
+		}
+		return sortedTimes(days)
+	default:
+		year, month := start.Year(), start.Month()+time.Month(n*r.Interval)
+		daysIn := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		monthDays := r.ByMonthDay
+		if len(monthDays) == 0 {
+			monthDays = []int{start.Day()}
+		}
+		days := []time.Time{}
+		for _, d := range monthDays {
+			if d < 0 {
+				d = daysIn + d + 1
+			}
+			// Like RFC 5545, days that don't exist in the month are skipped.
+			if d < 1 || d > daysIn {
+				continue
+			}
+			days = append(days, time.Date(year, month, d, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC))
+		}
+		return sortedTimes(days)
+	}
+}
+
+// sortedTimes sorts the times and removes duplicates, such as BYMONTHDAY=31,-1 in a long month.
+func sortedTimes(times []time.Time) []time.Time {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	unique := []time.Time{}
+	for i, t := range times {
+		if i == 0 || !t.Equal(times[i-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+func validRecurrence(rule string) error {
+	if rule == "" {
+		return nil
+	}
+	_, err := ParseRecurrence(rule)
+	return err
+}
+
+// recurrenceStart returns the start of the todos recurrence, its due date or now.
+func (t *Todo) recurrenceStart(now time.Time) time.Time {
+	if t.DueAt != nil {
+		return *t.DueAt
+	}
+	return now
+}
+
+// NextOccurrence returns the next todo of a recurring todo, due at the first
+// occurrence after both its due date and now. It returns nil if the todo
+// doesn't recur or its rule has ended.
+func (t *Todo) NextOccurrence(now time.Time) (*Todo, error) {
+	if t.Recurrence == "" {
+		return nil, nil
+	}
+	rule, err := ParseRecurrence(t.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	start := t.recurrenceStart(now)
+	after := start
+	if now.After(after) {
+		after = now
+	}
+	due, rest, ok := rule.Next(start, after)
+	if !ok {
+		return nil, nil
+	}
+
+	return &Todo{
+		Title:        t.Title,
+		Content:      t.Content,
+		Created:      now.UTC(),
+		CreatedBy:    t.CreatedBy,
+		DueAt:        &due,
+		Priority:     t.Priority,
+		Tags:         tagsFromIDs(t.TagIDs()),
+		ListID:       t.ListID,
+		ParentID:     t.ParentID,
+		AutoComplete: t.AutoComplete,
+		Recurrence:   rest.String(),
+	}, nil
+}
+
+// UpcomingOccurrences returns at most n due dates of the todos that follow this one.
+func (t *Todo) UpcomingOccurrences(now time.Time, n int) ([]time.Time, error) {
+	if t.Recurrence == "" {
+		return []time.Time{}, nil
+	}
+	rule, err := ParseRecurrence(t.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	start := t.recurrenceStart(now)
+	after := start
+	if now.After(after) {
+		after = now
+	}
+	return rule.Occurrences(start, after, n), nil
+}
+
+type TodoOccurrencesResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// The upcoming due dates
+	Result []time.Time `json:"result" example:"2006-01-09T15:04:05Z"`
+} // @name TodoOccurrencesResponse
+
+func NewTodoOccurrencesResponse(occurrences []time.Time) *TodoOccurrencesResponse {
+	return &TodoOccurrencesResponse{
+		Count:  len(occurrences),
+		Result: occurrences,
+	}
+}
@@ -0,0 +1,117 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+type TodoRevision struct {
+	// ID
+	ID int64 `json:"id" example:"1"`
+	// The todo that was changed
+	TodoID int64 `json:"todoId" example:"1"`
+	// User ID of the user that made the change
+	ChangedBy int `json:"changedBy" example:"1"`
+	// When the change was made
+	Created time.Time `json:"created" example:"2006-01-02T15:04:05Z"`
+	// The fields that changed, every field for the revision that created the todo
+	Changes []*TodoChange `json:"changes"`
+} // @name TodoRevision
+
+type TodoChange struct {
+	// One of title, content, done, dueAt, priority, tagIds, listId, parentId, autoComplete or recurrence
+	Field string `json:"field" example:"title"`
+	// The value before the change, null for the revision that created the todo
+	Old json.RawMessage `json:"old" swaggertype:"string" example:"\"My title\""`
+	// The value after the change
+	New json.RawMessage `json:"new" swaggertype:"string" example:"\"My new title\""`
+} // @name TodoChange
+
+type TodoHistoryResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// The revisions of the todo, newest first
+	Result []*TodoRevision `json:"result"`
+} // @name TodoHistoryResponse
+
+// todoRevisionFields are the fields of a todo that its revisions keep track
+// of, with their value and the matching field of UpdateTodoParams.
+var todoRevisionFields = []struct {
+	name  string
+	value func(*Todo) any
+	param func(*UpdateTodoParams) any
+}{
+	{"title", func(t *Todo) any { return t.Title }, func(p *UpdateTodoParams) any { return &p.Title }},
+	{"content", func(t *Todo) any { return t.Content }, func(p *UpdateTodoParams) any { return &p.Content }},
+	{"done", func(t *Todo) any { return t.Done }, func(p *UpdateTodoParams) any { return &p.Done }},
+	{"dueAt", func(t *Todo) any { return t.DueAt }, func(p *UpdateTodoParams) any { return &p.DueAt }},
+	{"priority", func(t *Todo) any { return t.Priority }, func(p *UpdateTodoParams) any { return &p.Priority }},
+	{"tagIds", func(t *Todo) any { return t.TagIDs() }, func(p *UpdateTodoParams) any { return &p.TagIDs }},
+	{"listId", func(t *Todo) any { return t.ListID }, func(p *UpdateTodoParams) any { return &p.ListID }},
+	{"parentId", func(t *Todo) any { return t.ParentID }, func(p *UpdateTodoParams) any { return &p.ParentID }},
+	{"autoComplete", func(t *Todo) any { return t.AutoComplete }, func(p *UpdateTodoParams) any { return &p.AutoComplete }},
+	{"recurrence", func(t *Todo) any { return t.Recurrence }, func(p *UpdateTodoParams) any { return &p.Recurrence }},
+}
+
+// DiffTodos returns the tracked fields that differ between the todo before and
+// after a change, every field if before is nil because the todo was created.
+func DiffTodos(before, after *Todo) ([]*TodoChange, error) {
+	changes := []*TodoChange{}
+	for _, field := range todoRevisionFields {
+		change := &TodoChange{Field: field.name}
+		var err error
+		if change.New, err = json.Marshal(field.value(after)); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			if change.Old, err = json.Marshal(field.value(before)); err != nil {
+				return nil, err
+			}
+			if bytes.Equal(change.Old, change.New) {
+				continue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// RevertTodoParams returns the params that replace the todo with its state
+// right after a revision by undoing the revisions made since then, which are
+// given newest first.
+func RevertTodoParams(todo *Todo, since []*TodoRevision) (UpdateTodoParams, error) {
+	tagIDs := todo.TagIDs()
+	params := UpdateTodoParams{
+		Title:        &todo.Title,
+		Content:      &todo.Content,
+		Created:      &todo.Created,
+		CreatedBy:    &todo.CreatedBy,
+		Done:         &todo.Done,
+		DueAt:        todo.DueAt,
+		Priority:     &todo.Priority,
+		TagIDs:       &tagIDs,
+		ListID:       todo.ListID,
+		ParentID:     todo.ParentID,
+		AutoComplete: &todo.AutoComplete,
+		Recurrence:   &todo.Recurrence,
+	}
+	for _, revision := range since {
+		for _, change := range revision.Changes {
+			for _, field := range todoRevisionFields {
+				if field.name != change.Field {
+					continue
+				}
+				// Decodes into a new value, the params point into the todo.
+				param := field.param(&params)
+				target := reflect.ValueOf(param).Elem()
+				target.Set(reflect.Zero(target.Type()))
+				if err := json.Unmarshal(change.Old, param); err != nil {
+					return params, err
+				}
+			}
+		}
+	}
+	return params, nil
+}
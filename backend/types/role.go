@@ -0,0 +1,53 @@
+package types
+
+import "fmt"
+
+// The roles of a user, admins manage the other users.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permission is something that only some roles are allowed to do.
+type Permission string
+
+const (
+	// List, disable, delete and promote users and reset their passwords
+	PermissionManageUsers Permission = "users:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:  {},
+	RoleAdmin: {PermissionManageUsers},
+}
+
+func validRole(role string) error {
+	if _, ok := rolePermissions[role]; !ok {
+		return fmt.Errorf("the role needs to be %s or %s", RoleUser, RoleAdmin)
+	}
+	return nil
+}
+
+// Can reports whether the role of the user grants the permission.
+func (u *User) Can(permission Permission) bool {
+	for _, p := range rolePermissions[u.Role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type UserRoleParams struct {
+	// The new role of the user, user or admin
+	Role string `json:"role" example:"admin" validate:"required"`
+} // @name UserRoleParams
+
+func (p *UserRoleParams) Validate() error {
+	return validRole(p.Role)
+}
+
+type UserDisableParams struct {
+	// Whether the user is disabled
+	Disabled bool `json:"disabled" example:"true"`
+} // @name UserDisableParams
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"time"
+)
+
+// User agents are cut to this many characters before they are stored.
+const maxUserAgentLength = 500
+
+// Session is created by a login and lives until it is revoked or its refresh tokens expire.
+type Session struct {
+	// ID
+	ID int64 `json:"id" example:"1"`
+	// User ID
+	UserID int `json:"-"`
+	// The user agent that logged in
+	UserAgent string `json:"userAgent" example:"Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0"`
+	// The IP address that logged in
+	IP string `json:"ip" example:"192.0.2.1"`
+	// PostgreSQL uses a ISO 8601-format
+	Created time.Time `json:"created" example:"2006-01-02 15:04:05.000-07"`
+	// The last time a token of the session was used
+	LastSeen time.Time `json:"lastSeen" example:"2006-01-02 15:04:05.000-07"`
+	// Set once the session has been signed out
+	RevokedAt *time.Time `json:"-"`
+	// Whether the request was made with a token of this session
+	Current bool `json:"current" example:"true"`
+} // @name Session
+
+type SessionGetAllResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// Array of the active sessions
+	Result []*Session `json:"result"`
+} // @name SessionGetAllResponse
+
+func NewSession(userID int, userAgent, ip string) *Session {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now().UTC()
+	return &Session{
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+		Created:   now,
+		LastSeen:  now,
+	}
+}
+
+func NewSessionGetAllResponse(sessions []*Session) *SessionGetAllResponse {
+	return &SessionGetAllResponse{
+		Count:  len(sessions),
+		Result: sessions,
+	}
+}
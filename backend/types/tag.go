@@ -0,0 +1,82 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+)
+
+const DefaultTagColor = "#808080"
+
+var tagColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+type Tag struct {
+	// ID
+	ID int64 `json:"id" example:"0"`
+	// The name of the tag, unique per user
+	Name string `json:"name" example:"work"`
+	// Hex color code
+	Color string `json:"color" example:"#808080"`
+	// User ID
+	CreatedBy int `json:"-"`
+} // @name Tag
+
+type TagParams struct {
+	// The name of the tag, unique per user
+	Name string `json:"name" example:"work" validate:"required"`
+	// Hex color code, defaults to #808080
+	Color string `json:"color,omitempty" example:"#808080"`
+} // @name TagParams
+
+type UpdateTagParams struct {
+	// The name of the tag, unique per user
+	Name *string `json:"name,omitempty" example:"work"`
+	// Hex color code
+	Color *string `json:"color,omitempty" example:"#808080"`
+} // @name UpdateTagParams
+
+type TagGetAllResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// Array of the tags
+	Result []*Tag `json:"result"`
+} // @name TagGetAllResponse
+
+func NewTagFromParams(params TagParams, userID int) *Tag {
+	color := params.Color
+	if color == "" {
+		color = DefaultTagColor
+	}
+	return &Tag{
+		Name:      params.Name,
+		Color:     color,
+		CreatedBy: userID,
+	}
+}
+
+func NewTagGetAllResponse(tags []*Tag) *TagGetAllResponse {
+	return &TagGetAllResponse{
+		Count:  len(tags),
+		Result: tags,
+	}
+}
+
+func (t *Tag) Validate() error {
+	return validateTag(&t.Name, &t.Color)
+}
+
+func (p UpdateTagParams) Validate() error {
+	if p.Name == nil && p.Color == nil {
+		return fmt.Errorf("the patch needs to contain at least one field")
+	}
+	return validateTag(p.Name, p.Color)
+}
+
+func validateTag(name, color *string) error {
+	if name != nil && (len(*name) < 1 || len(*name) > 50) {
+		return fmt.Errorf("name needs to be between 1 and 50 characters")
+	}
+	if color != nil && !tagColorRegex.MatchString(*color) {
+		return fmt.Errorf("color needs to be a hex color code such as #808080")
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"reflect"
 	"time"
 )
 
@@ -22,8 +23,68 @@ type Todo struct {
 	UpdatedBy *int64 `json:"updatedBy" example:"0"`
 	// This boolean determines if the todo has been completed
 	Done bool `json:"done" example:"false"`
+	// When the todo is due, null if it has no due date
+	DueAt *time.Time `json:"dueAt" example:"2006-01-02T15:04:05Z"`
+	// One of low, normal, high or urgent
+	Priority string `json:"priority" example:"normal"`
+	// The tags of the todo
+	Tags []*Tag `json:"tags"`
+	// The list the todo belongs to
+	ListID *int64 `json:"listId" example:"0"`
+	// The todo this is a subtask of, null for top level todos
+	ParentID *int64 `json:"parentId" example:"0"`
+	// Marks the todo as done once every subtask and checklist item is done
+	AutoComplete bool `json:"autoComplete" example:"false"`
+	// The checklist items of the todo
+	Checklist []*ChecklistItem `json:"checklist"`
+	// How many of the subtasks and checklist items are done, omitted if there are none
+	Progress *TodoProgress `json:"progress,omitempty"`
+	// RRULE or daily, weekly or monthly, marking the todo as done creates the next occurrence
+	Recurrence string `json:"recurrence" example:"FREQ=WEEKLY;BYDAY=MO,TH"`
+	// When the todo was moved to the trash, omitted for todos that aren't trashed
+	DeletedAt *time.Time `json:"deletedAt,omitempty" example:"2006-01-02T15:04:05Z"`
 } // @name Todo
 
+// Todos can be nested up to this many levels, the top level todo included.
+const MaxTodoDepth = 3
+
+type TodoProgress struct {
+	// Number of done subtasks and checklist items
+	Done int `json:"done" example:"3"`
+	// Number of subtasks and checklist items
+	Total int `json:"total" example:"5"`
+	// Human readable progress
+	Label string `json:"label" example:"3/5 done"`
+} // @name TodoProgress
+
+// NewTodoProgress returns nil if the todo has neither subtasks nor checklist items.
+func NewTodoProgress(done, total int) *TodoProgress {
+	if total == 0 {
+		return nil
+	}
+	return &TodoProgress{
+		Done:  done,
+		Total: total,
+		Label: fmt.Sprintf("%d/%d done", done, total),
+	}
+}
+
+// The priorities of a todo.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+func validPriority(priority string) bool {
+	switch priority {
+	case PriorityLow, PriorityNormal, PriorityHigh, PriorityUrgent:
+		return true
+	}
+	return false
+}
+
 type InsertTodoParams struct {
 	// The title of the Todo
 	Title string `json:"title" example:"My new title" validate:"required"`
@@ -31,10 +92,24 @@ type InsertTodoParams struct {
 	Content string `json:"content" example:"My new content" validate:"required"`
 	// PostgreSQL uses a ISO 8601-format
 	Created time.Time `json:"-" example:"2006-01-02T15:04:05Z" validate:"required"`
-	// User ID
-	CreatedBy int `json:"createdBy" example:"0" validate:"required"`
+	// User ID, always taken from the token of the authenticated user
+	CreatedBy int `json:"-"`
 	// This boolean determines if the todo has been completed
 	Done bool `json:"done" example:"false" validate:"required"`
+	// When the todo is due
+	DueAt *time.Time `json:"dueAt,omitempty" example:"2006-01-02T15:04:05Z"`
+	// One of low, normal, high or urgent, defaults to normal
+	Priority string `json:"priority,omitempty" example:"normal"`
+	// IDs of the users tags to put on the todo
+	TagIDs []int64 `json:"tagIds,omitempty" example:"1"`
+	// The list to put the todo in, defaults to the users inbox
+	ListID *int64 `json:"listId,omitempty" example:"0"`
+	// Creates the todo as a subtask of this todo
+	ParentID *int64 `json:"parentId,omitempty" example:"0"`
+	// Marks the todo as done once every subtask and checklist item is done
+	AutoComplete bool `json:"autoComplete,omitempty" example:"false"`
+	// RRULE or daily, weekly or monthly, starting at the due date
+	Recurrence string `json:"recurrence,omitempty" example:"FREQ=WEEKLY;BYDAY=MO,TH"`
 } // @name InsertTodoParams
 
 type UpdateTodoParams struct {
@@ -52,29 +127,128 @@ type UpdateTodoParams struct {
 	UpdatedBy *int `json:"updatedBy,omitempty" sql:"updated_by" example:"0" validate:"required"`
 	// This boolean determines if the todo has been completed
 	Done *bool `json:"done,omitempty" sql:"done" example:"false" validate:"required"`
+	// When the todo is due
+	DueAt *time.Time `json:"dueAt,omitempty" sql:"due_at" example:"2006-01-02T15:04:05Z"`
+	// One of low, normal, high or urgent
+	Priority *string `json:"priority,omitempty" sql:"priority" example:"normal"`
+	// IDs of the users tags, replaces the current tags
+	TagIDs *[]int64 `json:"tagIds,omitempty" example:"1"`
+	// The list to move the todo to
+	ListID *int64 `json:"listId,omitempty" sql:"list_id" example:"0"`
+	// Moves the todo below another todo, only a PUT without it makes it a top level todo again
+	ParentID *int64 `json:"parentId,omitempty" sql:"parent_id" example:"0"`
+	// Marks the todo as done once every subtask and checklist item is done
+	AutoComplete *bool `json:"autoComplete,omitempty" sql:"auto_complete" example:"false"`
+	// RRULE or daily, weekly or monthly, an empty string stops the recurrence
+	Recurrence *string `json:"recurrence,omitempty" sql:"recurrence" example:"FREQ=WEEKLY;BYDAY=MO,TH"`
 } // @name UpdateTodoParams
 
+func (p UpdateTodoParams) Validate() error {
+	if p.Title != nil && len(*p.Title) < 3 {
+		return fmt.Errorf("title needs to be at least 3 characters")
+	}
+	if p.Content != nil && len(*p.Content) < 3 {
+		return fmt.Errorf("content needs to be at least 3 characters")
+	}
+	if p.Priority != nil && !validPriority(*p.Priority) {
+		return fmt.Errorf("priority needs to be one of low, normal, high or urgent")
+	}
+	if p.Recurrence != nil {
+		if err := validRecurrence(*p.Recurrence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Empty reports whether none of the fields are set.
+func (p UpdateTodoParams) Empty() bool {
+	ref := reflect.ValueOf(p)
+	for i := 0; i < ref.NumField(); i++ {
+		if field := ref.Field(i); field.Kind() == reflect.Pointer && !field.IsNil() {
+			return false
+		}
+	}
+	return true
+}
+
 type TodoGetAllResponse struct {
-	// The length of the `result` array
+	// The total number of todos matching the filters
 	Count int `json:"count" example:"1"`
-	// Array of the todos
+	// Array of the todos on this page
 	Result []*Todo `json:"result"`
+	// Cursor of the next page, omitted on the last page
+	Next string `json:"next,omitempty" example:"eyJzIjoiY3JlYXRlZCIsInYiOiIyMDA2LTAxLTAyVDE1OjA0OjA1WiIsImlkIjoxfQ"`
 } // @name TodoGetAllResponse
 
+type TodoSearchResult struct {
+	// The matching todo
+	Todo *Todo `json:"todo"`
+	// How well the todo matches the search query, higher is better
+	Rank float64 `json:"rank" example:"0.6"`
+	// HTML escaped excerpt of the title and content with the matches wrapped in <b></b>
+	Snippet string `json:"snippet" example:"Buy <b>milk</b> and eggs"`
+} // @name TodoSearchResult
+
+type TodoSearchResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// The matches, best match first
+	Result []*TodoSearchResult `json:"result"`
+} // @name TodoSearchResponse
+
 func NewTodoFromParams(params InsertTodoParams) *Todo {
+	priority := params.Priority
+	if priority == "" {
+		priority = PriorityNormal
+	}
 	return &Todo{
-		Title:     params.Title,
-		Content:   params.Content,
-		Created:   time.Now().UTC(),
-		CreatedBy: params.CreatedBy,
-		Done:      params.Done,
+		Title:        params.Title,
+		Content:      params.Content,
+		Created:      time.Now().UTC(),
+		CreatedBy:    params.CreatedBy,
+		Done:         params.Done,
+		DueAt:        params.DueAt,
+		Priority:     priority,
+		Tags:         tagsFromIDs(params.TagIDs),
+		ListID:       params.ListID,
+		ParentID:     params.ParentID,
+		AutoComplete: params.AutoComplete,
+		Recurrence:   params.Recurrence,
 	}
 }
 
-func NewTodoGetAllResponse(todos []*Todo) *TodoGetAllResponse {
+// tagsFromIDs returns placeholder tags that the store replaces with the stored tags.
+func tagsFromIDs(ids []int64) []*Tag {
+	tags := []*Tag{}
+	for _, id := range ids {
+		tags = append(tags, &Tag{ID: id})
+	}
+	return tags
+}
+
+// TagIDs returns the IDs of the todos tags.
+func (t *Todo) TagIDs() []int64 {
+	ids := []int64{}
+	for _, tag := range t.Tags {
+		ids = append(ids, tag.ID)
+	}
+	return ids
+}
+
+func NewTodoGetAllResponse(page *TodoPage) *TodoGetAllResponse {
 	return &TodoGetAllResponse{
-		Count:  len(todos),
-		Result: todos,
+		Count:  page.Total,
+		Result: page.Todos,
+		Next:   page.Next,
+	}
+}
+
+func NewTodoSearchResponse(results []*TodoSearchResult) *TodoSearchResponse {
+	return &TodoSearchResponse{
+		Count:  len(results),
+		Result: results,
 	}
 }
 
@@ -85,6 +259,12 @@ func (t *Todo) Validate() error {
 	if len(t.Content) < 3 {
 		return fmt.Errorf("content needs to be at least 3 characters")
 	}
+	if !validPriority(t.Priority) {
+		return fmt.Errorf("priority needs to be one of low, normal, high or urgent")
+	}
+	if err := validRecurrence(t.Recurrence); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -0,0 +1,347 @@
+package types
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTodoLimit = 50
+	MaxTodoLimit     = 100
+)
+
+// The fields todos can be sorted by.
+const (
+	TodoSortCreated = "created"
+	TodoSortUpdated = "updated"
+	TodoSortTitle   = "title"
+	TodoSortDone    = "done"
+)
+
+// TodoQuery describes which todos to list and in what order. Todos are always
+// ordered by the sort field first and the ID second so that the cursor of the
+// last todo on a page uniquely identifies where the next page starts.
+type TodoQuery struct {
+	// The owner, only todos created by this user are listed
+	UserID int
+	// The maximum number of todos on a page
+	Limit int
+	// Where the page starts, nil for the first page
+	Cursor *TodoCursor
+	// One of the TodoSort constants
+	Sort string
+	// Sort in descending order
+	Desc bool
+
+	// Filters, nil means the filter is not applied
+	Done         *bool
+	CreatedAfter *time.Time
+	CreatedBy    *int
+	// Only todos due at or after DueFrom and before DueBefore
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	// Only todos tagged with any (or all if TagsAll is set) of these tag names
+	Tags    []string
+	TagsAll bool
+	// Only todos in this list
+	ListID *int64
+	// Only the subtasks of this todo
+	ParentID *int64
+}
+
+// TodoCursor is the position of a todo in a sorted list.
+type TodoCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+type TodoPage struct {
+	// The todos on this page
+	Todos []*Todo
+	// The total number of todos matching the filters
+	Total int
+	// The cursor of the next page, empty if this is the last page
+	Next string
+}
+
+// ParseTodoQuery reads the “limit“, “cursor“, “sort“ and filter parameters of
+// GET /api/v1/todos. The sort parameter may be prefixed with “-“ to sort in
+// descending order. The due date filters are relative to the current time.
+func ParseTodoQuery(values url.Values) (TodoQuery, error) {
+	return parseTodoQuery(values, time.Now())
+}
+
+func parseTodoQuery(values url.Values, now time.Time) (TodoQuery, error) {
+	q := TodoQuery{
+		Limit: DefaultTodoLimit,
+		Sort:  TodoSortCreated,
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxTodoLimit {
+			return q, fmt.Errorf("limit needs to be a number between 1 and %d", MaxTodoLimit)
+		}
+		q.Limit = n
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		q.Desc = strings.HasPrefix(sort, "-")
+		q.Sort = strings.TrimPrefix(sort, "-")
+		switch q.Sort {
+		case TodoSortCreated, TodoSortUpdated, TodoSortTitle, TodoSortDone:
+		default:
+			return q, fmt.Errorf("unknown sort field: %s", q.Sort)
+		}
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		c, err := DecodeTodoCursor(cursor)
+		if err != nil {
+			return q, err
+		}
+		if c.Sort != q.Sort || c.Desc != q.Desc {
+			return q, fmt.Errorf("the cursor does not match the sort order")
+		}
+		q.Cursor = c
+	}
+
+	if done := values.Get("done"); done != "" {
+		b, err := strconv.ParseBool(done)
+		if err != nil {
+			return q, fmt.Errorf("done needs to be a boolean")
+		}
+		q.Done = &b
+	}
+
+	if after := values.Get("created_after"); after != "" {
+		t, err := time.Parse(time.RFC3339, after)
+		if err != nil {
+			return q, fmt.Errorf("created_after needs to be a RFC 3339 timestamp")
+		}
+		t = t.UTC()
+		q.CreatedAfter = &t
+	}
+
+	if createdBy := values.Get("created_by"); createdBy != "" {
+		id, err := strconv.Atoi(createdBy)
+		if err != nil {
+			return q, fmt.Errorf("created_by needs to be a user ID")
+		}
+		q.CreatedBy = &id
+	}
+
+	if listID := values.Get("list_id"); listID != "" {
+		id, err := strconv.ParseInt(listID, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("list_id needs to be a list ID")
+		}
+		q.ListID = &id
+	}
+
+	if parentID := values.Get("parent_id"); parentID != "" {
+		id, err := strconv.ParseInt(parentID, 10, 64)
+		if err != nil {
+			return q, fmt.Errorf("parent_id needs to be a todo ID")
+		}
+		q.ParentID = &id
+	}
+
+	for _, tags := range values["tag"] {
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				q.Tags = append(q.Tags, tag)
+			}
+		}
+	}
+	switch mode := values.Get("tag_mode"); mode {
+	case "", "any":
+	case "all":
+		q.TagsAll = true
+	default:
+		return q, fmt.Errorf("tag_mode needs to be any or all")
+	}
+
+	if err := q.parseDueFilters(values, now); err != nil {
+		return q, err
+	}
+
+	return q, nil
+}
+
+// parseDueFilters narrows the due date range with the “overdue“, “due_today“ and
+// “due_within“ parameters. Today is the current day in the “tz“ time zone, UTC by default.
+func (q *TodoQuery) parseDueFilters(values url.Values, now time.Time) error {
+	if overdue := values.Get("overdue"); overdue != "" {
+		b, err := strconv.ParseBool(overdue)
+		if err != nil {
+			return fmt.Errorf("overdue needs to be a boolean")
+		}
+		if b {
+			if q.Done != nil && *q.Done {
+				return fmt.Errorf("done todos can't be overdue")
+			}
+			done := false
+			q.Done = &done
+			q.narrowDue(nil, &now)
+		}
+	}
+
+	if dueToday := values.Get("due_today"); dueToday != "" {
+		b, err := strconv.ParseBool(dueToday)
+		if err != nil {
+			return fmt.Errorf("due_today needs to be a boolean")
+		}
+		if b {
+			loc := time.UTC
+			if tz := values.Get("tz"); tz != "" {
+				if loc, err = time.LoadLocation(tz); err != nil {
+					return fmt.Errorf("unknown time zone: %s", tz)
+				}
+			}
+			local := now.In(loc)
+			start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+			end := start.AddDate(0, 0, 1)
+			q.narrowDue(&start, &end)
+		}
+	}
+
+	if dueWithin := values.Get("due_within"); dueWithin != "" {
+		d, err := parseDays(dueWithin)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("due_within needs to be a positive duration such as 7d or 12h")
+		}
+		end := now.Add(d)
+		q.narrowDue(&now, &end)
+	}
+
+	return nil
+}
+
+// narrowDue intersects the due date range with [from, before).
+func (q *TodoQuery) narrowDue(from, before *time.Time) {
+	if from != nil {
+		t := from.UTC()
+		if q.DueFrom == nil || t.After(*q.DueFrom) {
+			q.DueFrom = &t
+		}
+	}
+	if before != nil {
+		t := before.UTC()
+		if q.DueBefore == nil || t.Before(*q.DueBefore) {
+			q.DueBefore = &t
+		}
+	}
+}
+
+// parseDays extends “time.ParseDuration“ with a “d“ unit for whole days.
+func parseDays(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// SortValue returns the value the todo is sorted by, formatted like it's stored in a cursor.
+func (q TodoQuery) SortValue(t *Todo) string {
+	switch q.Sort {
+	case TodoSortUpdated:
+		if t.Updated != nil {
+			return t.Updated.UTC().Format(time.RFC3339Nano)
+		}
+		return t.Created.UTC().Format(time.RFC3339Nano)
+	case TodoSortTitle:
+		return t.Title
+	case TodoSortDone:
+		return strconv.FormatBool(t.Done)
+	default:
+		return t.Created.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// CursorFor returns the encoded cursor pointing right after the todo.
+func (q TodoQuery) CursorFor(t *Todo) string {
+	b, _ := json.Marshal(TodoCursor{
+		Sort:  q.Sort,
+		Desc:  q.Desc,
+		Value: q.SortValue(t),
+		ID:    t.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Less reports whether todo a is listed before todo b.
+func (q TodoQuery) Less(a, b *Todo) bool {
+	if q.Desc {
+		a, b = b, a
+	}
+	va, vb := q.SortValue(a), q.SortValue(b)
+	if va == vb {
+		return a.ID < b.ID
+	}
+	switch q.Sort {
+	case TodoSortCreated, TodoSortUpdated:
+		return q.sortTime(a).Before(q.sortTime(b))
+	default:
+		return va < vb
+	}
+}
+
+// AfterCursor reports whether the todo is listed after the cursor.
+func (q TodoQuery) AfterCursor(t *Todo) bool {
+	if q.Cursor == nil {
+		return true
+	}
+	return q.Less(&Todo{
+		ID:      q.Cursor.ID,
+		Title:   q.Cursor.Value,
+		Created: q.cursorTime(),
+		Done:    q.Cursor.Value == "true",
+	}, t)
+}
+
+// cursorTime parses the cursor value of the created and updated sort fields.
+func (q TodoQuery) cursorTime() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, q.Cursor.Value)
+	return t
+}
+
+func (q TodoQuery) sortTime(t *Todo) time.Time {
+	if q.Sort == TodoSortUpdated && t.Updated != nil {
+		return *t.Updated
+	}
+	return t.Created
+}
+
+func DecodeTodoCursor(s string) (*TodoCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	var c TodoCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	switch c.Sort {
+	case TodoSortCreated, TodoSortUpdated:
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, fmt.Errorf("malformed cursor")
+		}
+	case TodoSortDone:
+		if _, err := strconv.ParseBool(c.Value); err != nil {
+			return nil, fmt.Errorf("malformed cursor")
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Refresh tokens are valid for this long after they are issued, every refresh issues a new one.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+// RefreshToken is the stored form of a refresh token, only the hash of the token is kept.
+// Every refresh rotates the token within its session, the session starts at a login.
+type RefreshToken struct {
+	ID        int64
+	UserID    int
+	SessionID int64
+	TokenHash string
+	Created   time.Time
+	ExpiresAt time.Time
+	// Set once the token has been exchanged for a new one
+	UsedAt *time.Time
+	// Set once the session of the token has been revoked
+	RevokedAt *time.Time
+}
+
+type RefreshTokenParams struct {
+	// The refresh token from the login or the last refresh
+	RefreshToken string `json:"refreshToken" validate:"required"`
+} // @name RefreshTokenParams
+
+func (p *RefreshTokenParams) Validate() error {
+	if p.RefreshToken == "" {
+		return fmt.Errorf("the refresh token can't be empty")
+	}
+	return nil
+}
+
+// NewRefreshToken returns a refresh token of the session and the token to hand out.
+func NewRefreshToken(userID int, sessionID int64) (*RefreshToken, string, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now().UTC()
+	return &RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: HashToken(token),
+		Created:   now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}, token, nil
+}
+
+// HashToken returns the hex encoded SHA-256 hash that is stored instead of the token.
+// The tokens are random, so they don't need a slow password hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
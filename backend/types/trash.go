@@ -0,0 +1,31 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Trashed todos are purged this long after they were deleted unless
+// TRASH_RETENTION is set.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// ParseTrashRetention parses a duration like "720h", an empty string is
+// DefaultTrashRetention.
+func ParseTrashRetention(retention string) (time.Duration, error) {
+	d, err := parsePeriod("trash retention", retention, DefaultTrashRetention)
+	if err != nil {
+		return 0, err
+	}
+	if d == 0 {
+		return 0, fmt.Errorf("the trash retention needs to be positive")
+	}
+	return d, nil
+}
+
+type TrashGetAllResponse struct {
+	// The length of the `result` array
+	Count int `json:"count" example:"1"`
+	// The trashed todos, most recently deleted first. The subtasks of trashed
+	// todos aren't listed, they are restored and deleted along with them.
+	Result []*Todo `json:"result"`
+} // @name TrashGetAllResponse
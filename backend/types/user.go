@@ -3,6 +3,7 @@ package types
 import (
 	"fmt"
 	"regexp"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -14,6 +15,14 @@ type User struct {
 	Email string `json:"email" example:"user@domain.com"`
 	// The users password in a encrypted format
 	EncryptedPassword string `json:"-"`
+	// Whether the user has confirmed the email address
+	Verified bool `json:"verified" example:"true"`
+	// The role of the user, user or admin
+	Role string `json:"role" example:"user"`
+	// Disabled users can't log in and their tokens are rejected
+	Disabled bool `json:"disabled" example:"false"`
+	// When the account is deleted, set while the deletion can still be cancelled
+	DeleteAt *time.Time `json:"deleteAt,omitempty" example:"2023-07-14T18:00:25Z"`
 } // @name User
 
 // UserParams is used when logging in and when we're creating a new user
@@ -49,6 +58,10 @@ type LoginResponse struct {
 	Token string `json:"token"`
 	// Unix timestamp for when the token expires
 	ExpiresAt int64 `json:"expiresAt" example:"1688751625"`
+	// Exchanged for a new token and refresh token at /api/token/refresh
+	RefreshToken string `json:"refreshToken"`
+	// Unix timestamp for when the refresh token expires
+	RefreshExpiresAt int64 `json:"refreshExpiresAt" example:"1691343625"`
 } // @name LoginResponse
 
 type UserPutPasswordParams struct {
@@ -73,6 +86,7 @@ func NewUser(email, password string) (*User, error) {
 	return &User{
 		Email:             email,
 		EncryptedPassword: string(encrypted),
+		Role:              RoleUser,
 	}, nil
 }
 
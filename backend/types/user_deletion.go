@@ -0,0 +1,50 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Accounts are deleted this long after the user asked for it unless
+// ACCOUNT_DELETION_GRACE_PERIOD is set.
+const DefaultDeletionGracePeriod = 7 * 24 * time.Hour
+
+// ParseDeletionGracePeriod parses a duration like "72h", an empty string is
+// DefaultDeletionGracePeriod and zero deletes the accounts right away.
+func ParseDeletionGracePeriod(period string) (time.Duration, error) {
+	return parsePeriod("grace period", period, DefaultDeletionGracePeriod)
+}
+
+// parsePeriod parses a duration that can't be negative, an empty string is the default.
+func parsePeriod(name, period string, def time.Duration) (time.Duration, error) {
+	if period == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, period, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("the %s can't be negative", name)
+	}
+	return d, nil
+}
+
+type DeleteAccountParams struct {
+	// The current password of the user
+	Password string `json:"password" validate:"required"`
+} // @name DeleteAccountParams
+
+func (p *DeleteAccountParams) Validate() error {
+	if p.Password == "" {
+		return fmt.Errorf("the password can't be empty")
+	}
+	return nil
+}
+
+type DeleteAccountResponse struct {
+	// Whether the account has been deleted already
+	Deleted bool `json:"deleted" example:"false"`
+	// When the account is deleted, logging in before then cancels the deletion
+	DeleteAt *time.Time `json:"deleteAt,omitempty" example:"2023-07-14T18:00:25Z"`
+} // @name DeleteAccountResponse
@@ -0,0 +1,60 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Verification links are valid for this long.
+const EmailVerificationTTL = 48 * time.Hour
+
+// Verification mails are sent at most once per interval and user.
+const VerificationMailInterval = 5 * time.Minute
+
+// UnverifiedPolicy decides what users that haven't verified their email address can do.
+type UnverifiedPolicy string
+
+const (
+	// Unverified users can do everything
+	UnverifiedAllow UnverifiedPolicy = "allow"
+	// Unverified users can log in but only make read requests
+	UnverifiedReadOnly UnverifiedPolicy = "read-only"
+	// Unverified users can't log in
+	UnverifiedBlock UnverifiedPolicy = "block"
+)
+
+// ParseUnverifiedPolicy parses a policy, an empty string is UnverifiedAllow.
+func ParseUnverifiedPolicy(policy string) (UnverifiedPolicy, error) {
+	switch p := UnverifiedPolicy(policy); p {
+	case "":
+		return UnverifiedAllow, nil
+	case UnverifiedAllow, UnverifiedReadOnly, UnverifiedBlock:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown policy %q, expected %q, %q or %q", policy, UnverifiedAllow, UnverifiedReadOnly, UnverifiedBlock)
+	}
+}
+
+type VerifyEmailParams struct {
+	// The token from the verification link
+	Token string `json:"token" validate:"required"`
+} // @name VerifyEmailParams
+
+type ResendVerificationParams struct {
+	// The email address of the account
+	Email string `json:"email" example:"user@domain.com" validate:"required"`
+} // @name ResendVerificationParams
+
+func (p *VerifyEmailParams) Validate() error {
+	if p.Token == "" {
+		return fmt.Errorf("the token can't be empty")
+	}
+	return nil
+}
+
+func (p *ResendVerificationParams) Validate() error {
+	if p.Email == "" {
+		return fmt.Errorf("the email can't be empty")
+	}
+	return nil
+}
@@ -1,27 +1,78 @@
-import { API_URL } from '$env/static/private';
-import type { RequestEvent } from '@sveltejs/kit';
+import { API_URL, JWT_COOKIE } from '$env/static/private';
+import type { Cookies, RequestEvent } from '@sveltejs/kit';
 
-export const authenticateUser = async (event: RequestEvent): Promise<App.User | null> => {
-	const token = event.cookies.get('jwt');
-	if (token === undefined) return null;
+export const REFRESH_COOKIE = 'refresh';
+// Keeps the flow token of an OAuth login until the provider redirects back.
+export const OAUTH_FLOW_COOKIE = 'oauth_flow';
 
-	try {
-		const res = await fetch(`${API_URL}/api/check`, {
-			method: 'GET',
-			headers: { Authorization: `Bearer ${token}` }
-		});
+type LoginResult = {
+	token: string;
+	expiresAt: number;
+	refreshToken: string;
+	refreshExpiresAt: number;
+};
+
+export const setAuthCookies = (cookies: Cookies, result: LoginResult) => {
+	const now = Math.floor(Number(new Date()) / 1000);
+	const options = { path: '/', sameSite: 'strict', httpOnly: true, secure: false } as const;
+	cookies.set(JWT_COOKIE, result.token, { ...options, maxAge: result.expiresAt - now });
+	cookies.set(REFRESH_COOKIE, result.refreshToken, {
+		...options,
+		maxAge: result.refreshExpiresAt - now
+	});
+};
+
+export const deleteAuthCookies = (cookies: Cookies) => {
+	cookies.delete(JWT_COOKIE, { path: '/' });
+	cookies.delete(REFRESH_COOKIE, { path: '/' });
+};
+
+// refreshToken exchanges the refresh cookie for a new token, the refresh token rotates on every use.
+const refreshToken = async (cookies: Cookies): Promise<string | null> => {
+	const refresh = cookies.get(REFRESH_COOKIE);
+	if (refresh === undefined) return null;
 
-		const result = await res.json();
+	const res = await fetch(`${API_URL}/api/token/refresh`, {
+		method: 'POST',
+		body: JSON.stringify({ refreshToken: refresh })
+	});
+	const result = await res.json();
+	if (result.success === false) {
+		deleteAuthCookies(cookies);
+		return null;
+	}
+
+	setAuthCookies(cookies, result);
+	return result.token;
+};
+
+const checkToken = async (token: string) => {
+	const res = await fetch(`${API_URL}/api/check`, {
+		method: 'GET',
+		headers: { Authorization: `Bearer ${token}` }
+	});
+	return res.json();
+};
+
+export const authenticateUser = async (event: RequestEvent): Promise<App.User | null> => {
+	try {
+		let token = event.cookies.get(JWT_COOKIE);
+		let result = token === undefined ? null : await checkToken(token);
 
-		if (result.success === false) {
-			event.cookies.delete('jwt');
-			return null;
+		if (result === null || result.success === false) {
+			token = (await refreshToken(event.cookies)) ?? undefined;
+			if (token === undefined) {
+				event.cookies.delete(JWT_COOKIE, { path: '/' });
+				return null;
+			}
+			result = await checkToken(token);
+			if (result.success === false) return null;
 		}
 
 		const user: App.User = {
 			id: result.id,
 			email: result.email,
-			token: token
+			token: token as string
 		};
 		return user;
 	} catch (err) {
@@ -41,7 +41,6 @@ export const actions = {
 				},
 				body: JSON.stringify({
 					content,
-					createdBy: user?.id,
 					title: 'Todo'
 				})
 			});
@@ -0,0 +1,25 @@
+import { API_URL } from '$env/static/private';
+import { fail } from '@sveltejs/kit';
+
+export const actions = {
+	default: async ({ request }) => {
+		const formData = await request.formData();
+		const email = formData.get('email');
+
+		try {
+			const res = await fetch(`${API_URL}/api/password/forgot`, {
+				method: 'POST',
+				body: JSON.stringify({ email })
+			});
+			const result = await res.json();
+			return { success: result.success, message: result.message, email };
+		} catch (err) {
+			console.log('Error when connecting to the API', err);
+			return fail(400, {
+				success: false,
+				message: `the API is not responding`,
+				email
+			});
+		}
+	}
+};
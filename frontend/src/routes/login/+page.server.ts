@@ -1,24 +1,44 @@
 import { API_URL, JWT_COOKIE } from '$env/static/private';
 import { fail, redirect } from '@sveltejs/kit';
+import { setAuthCookies } from '$lib/server/auth';
 
 export const load = async ({ url, cookies }) => {
   const email = url.searchParams.get('registeredEmail');
+	// The login providers are optional, the password login works without them.
+	let providers: string[] = [];
+	try {
+		const res = await fetch(`${API_URL}/api/oauth/providers`);
+		providers = (await res.json()).providers ?? [];
+	} catch (err) {
+		console.log('Error when connecting to the API', err);
+	}
 	return {
     registeredEmail: email,
-		cookie: cookies.get(JWT_COOKIE)
+		cookie: cookies.get(JWT_COOKIE),
+		providers,
+		// Set when a provider login continues with a two-factor code
+		challengeToken: url.searchParams.get('challengeToken')
 	};
 };
 
 export const actions = {
-	default: async ({ request, cookies }) => {
+	default: async ({ request, cookies, getClientAddress }) => {
 		const formData = await request.formData();
 		const email = formData.get('email');
 		const password = formData.get('password');
+		// Set once the password was accepted for a user with two-factor authentication.
+		const challengeToken = formData.get('challengeToken');
+		const code = formData.get('code');
 
 		try {
-			const res = await fetch(`${API_URL}/api/login`, {
+			// The API records the browser of the session, not this server.
+			const res = await fetch(`${API_URL}/api/login${challengeToken ? '/mfa' : ''}`, {
 				method: 'POST',
-				body: JSON.stringify({ email, password })
+				headers: {
+					'User-Agent': request.headers.get('user-agent') ?? '',
+					'X-Forwarded-For': getClientAddress()
+				},
+				body: JSON.stringify(challengeToken ? { challengeToken, code } : { email, password })
 			});
 			const result = await res.json();
 			if (result.success == false) {
@@ -26,19 +46,19 @@ export const actions = {
 				return fail(405, {
 					success: false,
 					message: result.message,
-					email
+					email,
+					challengeToken
 				});
 			}
+			if (result.mfaRequired) {
+				return {
+					mfaRequired: true,
+					challengeToken: result.challengeToken,
+					email
+				};
+			}
 			console.log('Login success result:', result);
-			const now = Math.floor(Number(new Date()) / 1000);
-			console.log('Expire:', result.expiresAt - now);
-			cookies.set(JWT_COOKIE, result.token, {
-				path: '/',
-				sameSite: 'strict',
-				httpOnly: true,
-				secure: false,
-				maxAge: result.expiresAt - now
-			});
+			setAuthCookies(cookies, result);
 		} catch (err) {
 			console.log('Error when connecting to the API', err);
 			return fail(400, {
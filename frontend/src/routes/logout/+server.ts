@@ -1,7 +1,19 @@
-import { JWT_COOKIE } from '$env/static/private';
+import { API_URL } from '$env/static/private';
 import { redirect } from '@sveltejs/kit';
+import { deleteAuthCookies, REFRESH_COOKIE } from '$lib/server/auth';
 
 export const GET = async ({ cookies }) => {
-	cookies.delete(JWT_COOKIE);
+	const refreshToken = cookies.get(REFRESH_COOKIE);
+	if (refreshToken !== undefined) {
+		try {
+			await fetch(`${API_URL}/api/logout`, {
+				method: 'POST',
+				body: JSON.stringify({ refreshToken })
+			});
+		} catch (err) {
+			console.log('Error when connecting to the API', err);
+		}
+	}
+	deleteAuthCookies(cookies);
 	throw redirect(303, '/login');
 };
@@ -0,0 +1,23 @@
+import { API_URL } from '$env/static/private';
+import { error, redirect } from '@sveltejs/kit';
+import { OAUTH_FLOW_COOKIE } from '$lib/server/auth';
+
+// Starts the login at the provider, which redirects back to ./callback.
+export const GET = async ({ params, cookies }) => {
+	const res = await fetch(`${API_URL}/api/oauth/${encodeURIComponent(params.provider)}/start`);
+	const result = await res.json();
+	if (result.success === false) {
+		throw error(res.status, result.message);
+	}
+
+	// The provider redirects back from another site, so the cookie can't be strict.
+	const now = Math.floor(Number(new Date()) / 1000);
+	cookies.set(OAUTH_FLOW_COOKIE, result.flowToken, {
+		path: '/oauth',
+		sameSite: 'lax',
+		httpOnly: true,
+		secure: false,
+		maxAge: result.expiresAt - now
+	});
+	throw redirect(302, result.authorizationUrl);
+};
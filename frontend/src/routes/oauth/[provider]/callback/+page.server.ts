@@ -0,0 +1,37 @@
+import { API_URL } from '$env/static/private';
+import { redirect } from '@sveltejs/kit';
+import { OAUTH_FLOW_COOKIE, setAuthCookies } from '$lib/server/auth';
+
+export const load = async ({ params, url, cookies, request, getClientAddress }) => {
+	const flowToken = cookies.get(OAUTH_FLOW_COOKIE) ?? '';
+	cookies.delete(OAUTH_FLOW_COOKIE, { path: '/oauth' });
+
+	let result;
+	try {
+		const res = await fetch(`${API_URL}/api/oauth/${encodeURIComponent(params.provider)}/callback`, {
+			method: 'POST',
+			headers: {
+				'User-Agent': request.headers.get('user-agent') ?? '',
+				'X-Forwarded-For': getClientAddress()
+			},
+			body: JSON.stringify({
+				code: url.searchParams.get('code') ?? '',
+				state: url.searchParams.get('state') ?? '',
+				flowToken
+			})
+		});
+		result = await res.json();
+	} catch (err) {
+		console.log('Error when connecting to the API', err);
+		return { success: false, message: 'the API is not responding' };
+	}
+
+	if (result.success === false) {
+		return { success: false, message: result.message };
+	}
+	if (result.mfaRequired) {
+		throw redirect(302, `/login?challengeToken=${encodeURIComponent(result.challengeToken)}`);
+	}
+	setAuthCookies(cookies, result);
+	throw redirect(302, '/');
+};
@@ -0,0 +1,46 @@
+import { API_URL } from '$env/static/private';
+import { fail, redirect } from '@sveltejs/kit';
+
+export const load = async ({ url }) => {
+	return {
+		token: url.searchParams.get('token') ?? ''
+	};
+};
+
+export const actions = {
+	default: async ({ request }) => {
+		const formData = await request.formData();
+		const token = formData.get('token');
+		const password = formData.get('password');
+		const passwordConfirm = formData.get('passwordConfirm');
+
+		if (password !== passwordConfirm) {
+			return fail(405, {
+				success: false,
+				message: 'the passwords does not match!'
+			});
+		}
+
+		try {
+			const res = await fetch(`${API_URL}/api/password/reset`, {
+				method: 'POST',
+				body: JSON.stringify({ token, password })
+			});
+			const result = await res.json();
+			if (result.success == false) {
+				return fail(405, {
+					success: false,
+					message: result.message
+				});
+			}
+		} catch (err) {
+			console.log('Error when connecting to the API', err);
+			return fail(400, {
+				success: false,
+				message: `the API is not responding`
+			});
+		}
+
+		throw redirect(303, '/login');
+	}
+};
@@ -0,0 +1,17 @@
+import { API_URL } from '$env/static/private';
+
+export const load = async ({ url }) => {
+	const token = url.searchParams.get('token') ?? '';
+
+	try {
+		const res = await fetch(`${API_URL}/api/verify-email`, {
+			method: 'POST',
+			body: JSON.stringify({ token })
+		});
+		const result = await res.json();
+		return { success: result.success, message: result.message };
+	} catch (err) {
+		console.log('Error when connecting to the API', err);
+		return { success: false, message: 'the API is not responding' };
+	}
+};